@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"os"
+	"os/user"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"security-gateway/pkg/util"
+	"strings"
+	"time"
+)
+
+// command 命令行子命令，name可以包含多级，如config export
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []*command{
+	{"serve", "启动网关(默认)", func([]string) error { startApp(); return nil }},
+	{"migrate", "执行数据库迁移", runMigrate},
+	{"config export", "导出声明式配置: config export [-format yaml|json] [-o 文件]", runConfigExport},
+	{"config import", "导入声明式配置: config import [-format yaml|json] [-dry-run] 文件|-", runConfigImport},
+	{"config validate", "校验声明式配置: config validate [-format yaml|json] 文件|-", runConfigValidate},
+	{"user import", "导入用户(CSV: username,uniKey,secLevel): user import [-dry-run] 文件|-", runUserImport},
+	{"route test", "测试请求匹配的路由: route test [-X 方法] [-H '名称: 值'] URL", runRouteTest},
+	{"cert inspect", "查看证书信息及私钥是否匹配: cert inspect [-key 私钥文件] [-id 证书ID | -name 证书名称 | 证书文件]", runCertInspect},
+	{"token revoke", "撤销用户的token: token revoke -user 用户名 [-id tokenID]...", runTokenRevoke},
+	{"secret encrypt", "使用主密钥加密配置文件中的值(如database.password): secret encrypt [值|-]", runSecretEncrypt},
+	{"secret rotate", "使用当前的主密钥重新加密证书私钥等敏感字段及配置版本中的密钥: secret rotate [-dry-run]", runSecretRotate},
+	{"logs query", "查询日志: logs query [-type proxy|main|audit] [-level 级别] [-since 时间] [-until 时间] [-grep 关键字] [-field 名称=值] [-limit 数量]", runLogsQuery},
+}
+
+// errUsage 参数错误，输出命令用法
+var errUsage = errors.New("参数错误")
+
+// runCommand 执行命令行子命令，没有子命令时启动网关，返回进程退出码。
+// 全局参数-config指定配置文件，默认为./conf/config.toml
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("security-gateway", flag.ContinueOnError)
+	configFile := fs.String("config", "", "配置文件")
+	fs.Usage = printUsage
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *configFile != "" {
+		config.DefaultInstance.SetConfigFile(*configFile)
+		if err := config.DefaultInstance.ReadInConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, "读取配置文件失败:", err)
+			return 1
+		}
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		startApp()
+		return 0
+	}
+	if args[0] == "help" {
+		printUsage()
+		return 0
+	}
+
+	var cmd *command
+	var rest []string
+	for _, c := range commands {
+		parts := strings.Fields(c.name)
+		if len(args) >= len(parts) && strings.Join(args[:len(parts)], " ") == c.name {
+			cmd, rest = c, args[len(parts):]
+			break
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintln(os.Stderr, "未知的命令:", strings.Join(args, " "))
+		printUsage()
+		return 2
+	}
+	if err := cmd.run(rest); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "用法:", cmd.usage)
+			return 2
+		}
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "用法: security-gateway [-config 配置文件] [命令] [参数]")
+	fmt.Fprintln(os.Stderr, "命令:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.usage)
+	}
+}
+
+// newFlagSet 子命令的参数，参数错误时返回errUsage
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// openDatabase 初始化命令行使用的日志及数据库，日志只输出警告及以上级别，返回关闭函数
+func openDatabase() (closeFunc func(), err error) {
+	config.InitialLogger()
+	if logger.GetLevel() > logger.WarnLevel {
+		logger.SetLevel(logger.WarnLevel)
+	}
+	if err = secret.Initial(); err != nil {
+		return nil, fmt.Errorf("加载主密钥失败: %w", err)
+	}
+	if err = database.Initial(); err != nil {
+		return nil, fmt.Errorf("数据库初始化失败: %w", err)
+	}
+	return database.Close, nil
+}
+
+// withNode 修改数据的命令注册为临时集群节点，获取不与运行中的网关冲突的雪花算法工作机器ID，结束时注销
+func withNode(fn func() error) error {
+	hostname, _ := os.Hostname()
+	node, err := service.ClusterService.RegisterNode(-1, hostname, "cli", time.Minute)
+	if err != nil {
+		return fmt.Errorf("初始化节点失败: %w", err)
+	}
+	defer service.ClusterService.UnregisterNode(node.ID)
+	if err = util.InitNode(node.ID); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// cliActor 命令行操作记录审计日志时的操作人
+func cliActor() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	return "cli:" + name
+}
+
+// recordCliAudit 记录命令行修改的审计日志，记录失败只输出日志
+func recordCliAudit(resource, action string, after interface{}) {
+	instance := &model.AuditLog{
+		Actor:    cliActor(),
+		Action:   action,
+		Resource: resource,
+		After:    toJSON(after),
+	}
+	if err := service.AuditLogService.Append(instance); err != nil {
+		logger.Errorf("记录审计日志失败: %s %s, %v", action, resource, err)
+	}
+}
+
+// notifyConfigChanged 网关配置修改后保存配置版本并递增配置版本，运行中的网关定时检查版本后同步
+func notifyConfigChanged(summary string) {
+	if _, _, err := service.ConfigSnapshotService.Snapshot(cliActor(), "", summary); err != nil {
+		logger.Errorf("保存配置版本失败: %s, %v", summary, err)
+	}
+	if _, err := service.ClusterService.IncreaseConfigVersion(); err != nil {
+		logger.Error("更新配置版本失败: ", err)
+	}
+}
+
+func runMigrate(args []string) error {
+	if err := parseFlags(newFlagSet("migrate"), args); err != nil {
+		return err
+	}
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	if err = database.AutoMigrate(model.Models...); err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	fmt.Println("数据库迁移完成")
+	return nil
+}
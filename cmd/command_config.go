@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"strings"
+)
+
+func runConfigExport(args []string) error {
+	fs := newFlagSet("config export")
+	format := fs.String("format", domain.ConfigFormatYaml, "格式, yaml或json")
+	output := fs.String("o", "", "输出文件，默认输出到标准输出")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	doc, err := service.ConfigDeclarativeService.Export()
+	if err != nil {
+		return err
+	}
+	data, err := domain.MarshalDeclarativeConfig(doc, *format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(*output, data, 0600)
+}
+
+func runConfigImport(args []string) error {
+	fs := newFlagSet("config import")
+	format := fs.String("format", "", "格式, yaml或json，默认按文件扩展名判断")
+	dryRun := fs.Bool("dry-run", false, "只输出计划的变化，不修改配置")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	doc, err := readDeclarativeConfig(fs.Args(), *format)
+	if err != nil {
+		return err
+	}
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return withNode(func() error {
+		result, err := service.ConfigDeclarativeService.Import(doc, *dryRun, "")
+		if err != nil {
+			return validationError(err)
+		}
+		for _, item := range result.Items {
+			if item.Action == service.ConfigPlanUnchanged {
+				continue
+			}
+			fmt.Printf("%-8s %-14s %s", item.Action, item.Resource, item.Key)
+			if len(item.Fields) > 0 {
+				fmt.Printf(" (%s)", strings.Join(item.Fields, ", "))
+			}
+			fmt.Println()
+		}
+		prefix := "已"
+		if *dryRun {
+			prefix = "计划"
+		}
+		fmt.Printf("%s新增%d项，修改%d项，%d项无变化\n", prefix, result.Created, result.Updated, result.Unchanged)
+
+		if !*dryRun && result.Changed() {
+			recordCliAudit("config", model.AuditActionImport, map[string]interface{}{
+				"created": result.Created,
+				"updated": result.Updated,
+			})
+			notifyConfigChanged("config import")
+		}
+		return nil
+	})
+}
+
+func runConfigValidate(args []string) error {
+	fs := newFlagSet("config validate")
+	format := fs.String("format", "", "格式, yaml或json，默认按文件扩展名判断")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	doc, err := readDeclarativeConfig(fs.Args(), *format)
+	if err != nil {
+		return err
+	}
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if err = service.ConfigDeclarativeService.Validate(doc); err != nil {
+		return validationError(err)
+	}
+	fmt.Println("配置文档校验通过")
+	return nil
+}
+
+// readDeclarativeConfig 读取配置文档，文件为-时从标准输入读取
+func readDeclarativeConfig(args []string, format string) (*domain.DeclarativeConfig, error) {
+	if len(args) != 1 {
+		return nil, errUsage
+	}
+	data, err := readInput(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if format == "" && strings.EqualFold(filepath.Ext(args[0]), ".json") {
+		format = domain.ConfigFormatJson
+	}
+	doc, err := domain.ParseDeclarativeConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文档失败: %w", err)
+	}
+	return doc, nil
+}
+
+// readInput 读取文件内容，文件为-时从标准输入读取
+func readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
+
+// validationError 配置文档校验失败时每行输出一个错误
+func validationError(err error) error {
+	var validationErr *service.ConfigValidationError
+	if errors.As(err, &validationErr) {
+		return errors.New("配置文档校验失败:\n  " + strings.Join(validationErr.Errors, "\n  "))
+	}
+	return err
+}
+
+func toJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
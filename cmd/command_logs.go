@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日志类型
+const (
+	logTypeProxy = "proxy" // 代理请求日志
+	logTypeMain  = "main"  // 网关运行日志
+	logTypeAudit = "audit" // 审计日志，保存在数据库中
+)
+
+// logEntry 一行日志，fields为logfmt格式解析出的字段
+type logEntry struct {
+	time   time.Time
+	line   string
+	fields map[string]string
+}
+
+func runLogsQuery(args []string) error {
+	fs := newFlagSet("logs query")
+	logType := fs.String("type", logTypeProxy, "日志类型: proxy、main或audit")
+	level := fs.String("level", "", "最低日志级别，如warn")
+	since := fs.String("since", "", "开始时间，格式为2006-01-02 15:04:05、2006-01-02或相对时间如1h")
+	until := fs.String("until", "", "结束时间，格式同since")
+	keyword := fs.String("grep", "", "包含的关键字")
+	var fieldFlags multiFlag
+	fs.Var(&fieldFlags, "field", "字段条件，格式为名称=值，如username=alice，可以指定多个")
+	limit := fs.Int("limit", 100, "最多返回的条数，返回最新的记录")
+	jsonOutput := fs.Bool("json", false, "以json格式输出")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	start, err := parseQueryTime(*since)
+	if err != nil {
+		return err
+	}
+	end, err := parseQueryTime(*until)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]string, len(fieldFlags))
+	for _, f := range fieldFlags {
+		name, value, ok := strings.Cut(f, "=")
+		if !ok {
+			return fmt.Errorf("字段条件格式错误: %s", f)
+		}
+		fields[name] = value
+	}
+
+	switch *logType {
+	case logTypeAudit:
+		return queryAuditLogs(fields, start, end, *limit)
+	case logTypeProxy, logTypeMain:
+	default:
+		return errUsage
+	}
+
+	minLevel := logger.TraceLevel
+	if *level != "" {
+		if minLevel, err = logger.ParseLevel(*level); err != nil {
+			return err
+		}
+	}
+	dir := config.GetString("logger.dir")
+	if dir == "" {
+		dir = "logs"
+	}
+	if *logType == logTypeProxy {
+		dir = filepath.Join(dir, "proxy_trace")
+	} else {
+		dir = filepath.Join(dir, config.GetString("moduleName", "main"))
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.log*"))
+	if err != nil {
+		return err
+	}
+
+	var entries []*logEntry
+	for _, file := range files {
+		err = scanLogFile(file, func(e *logEntry) {
+			if l, e2 := logger.ParseLevel(e.fields["level"]); e2 == nil && l > minLevel {
+				return
+			}
+			if (!start.IsZero() && e.time.Before(start)) || (!end.IsZero() && e.time.After(end)) {
+				return
+			}
+			if *keyword != "" && !strings.Contains(e.line, *keyword) {
+				return
+			}
+			for name, value := range fields {
+				if e.fields[name] != value {
+					return
+				}
+			}
+			entries = append(entries, e)
+		})
+		if err != nil {
+			return fmt.Errorf("读取日志文件%s失败: %w", file, err)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.Before(entries[j].time) })
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	if *jsonOutput {
+		list := make([]map[string]string, 0, len(entries))
+		for _, e := range entries {
+			list = append(list, e.fields)
+		}
+		return printJSON(list)
+	}
+	for _, e := range entries {
+		fmt.Println(e.line)
+	}
+	return nil
+}
+
+// queryAuditLogs 查询审计日志，字段条件支持actor、action、resource及resourceId
+func queryAuditLogs(fields map[string]string, start, end time.Time, limit int) error {
+	condition := &model.AuditLog{
+		Actor:    fields["actor"],
+		Action:   fields["action"],
+		Resource: fields["resource"],
+	}
+	condition.ResourceID, _ = strconv.ParseUint(fields["resourceId"], 10, 64)
+	var startTime, endTime int64
+	if !start.IsZero() {
+		startTime = start.UnixMilli()
+	}
+	if !end.IsZero() {
+		endTime = end.UnixMilli()
+	}
+
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	instances, _, err := service.AuditLogService.List(1, limit, condition, startTime, endTime)
+	if err != nil {
+		return err
+	}
+	if instances == nil {
+		instances = []*model.AuditLog{}
+	}
+	return printJSON(instances)
+}
+
+// parseQueryTime 解析时间条件，支持绝对时间及相对当前的时长
+func parseQueryTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{config.TimeStampFormat, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("时间格式错误: %s", s)
+}
+
+// scanLogFile 逐行读取日志文件，支持轮转后压缩的.gz文件
+func scanLogFile(file string, fn func(e *logEntry)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		e := &logEntry{line: line, fields: parseLogfmt(line)}
+		e.time, _ = time.ParseInLocation(config.TimeStampFormat, e.fields["time"], time.Local)
+		fn(e)
+	}
+	return scanner.Err()
+}
+
+// parseLogfmt 解析logrus文本格式的一行日志: key=value key="带空格的值"
+func parseLogfmt(line string) map[string]string {
+	fields := make(map[string]string)
+	for i := 0; i < len(line); {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 {
+			break
+		}
+		key := line[i : i+eq]
+		i += eq + 1
+		var value string
+		if i < len(line) && line[i] == '"' {
+			// 找到未转义的结束引号
+			j := i + 1
+			for j < len(line) && line[j] != '"' {
+				if line[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(line) {
+				// 缺少结束引号
+				fields[key] = line[i+1:]
+				break
+			}
+			if unquoted, err := strconv.Unquote(line[i : j+1]); err == nil {
+				value = unquoted
+			} else {
+				value = line[i+1 : j]
+			}
+			i = j + 1
+		} else {
+			j := strings.IndexByte(line[i:], ' ')
+			if j < 0 {
+				j = len(line) - i
+			}
+			value = line[i : i+j]
+			i += j
+		}
+		fields[key] = value
+	}
+	return fields
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/cache"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"security-gateway/pkg/util"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// multiFlag 可以重复指定的参数
+type multiFlag []string
+
+func (f *multiFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *multiFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func runUserImport(args []string) error {
+	fs := newFlagSet("user import")
+	dryRun := fs.Bool("dry-run", false, "只输出计划的变化，不修改用户")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	data, err := readInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return fmt.Errorf("解析CSV失败: %w", err)
+	}
+	// 第一行为表头时跳过
+	if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "username") {
+		records = records[1:]
+	}
+	users := make([]*model.User, 0, len(records))
+	for i, record := range records {
+		if len(record) < 2 || strings.TrimSpace(record[0]) == "" || strings.TrimSpace(record[1]) == "" {
+			return fmt.Errorf("第%d行: 用户名及唯一标识不能为空", i+1)
+		}
+		u := &model.User{Username: strings.TrimSpace(record[0]), UniKey: strings.TrimSpace(record[1])}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			if u.SecLevel, err = strconv.Atoi(strings.TrimSpace(record[2])); err != nil {
+				return fmt.Errorf("第%d行: 密级格式错误: %s", i+1, record[2])
+			}
+		}
+		users = append(users, u)
+	}
+
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return withNode(func() error {
+		var created, updated int
+		for _, u := range users {
+			var existing []*model.User
+			if err := database.DB.Where("username = ? and uni_key = ?", u.Username, u.UniKey).Limit(1).Find(&existing).Error; err != nil {
+				return err
+			}
+			switch {
+			case len(existing) == 0:
+				created++
+				fmt.Printf("create %s %s %d\n", u.Username, u.UniKey, u.SecLevel)
+				if !*dryRun {
+					if _, _, err := service.UserService.Add(u); err != nil {
+						return err
+					}
+				}
+			case existing[0].SecLevel != u.SecLevel:
+				updated++
+				fmt.Printf("update %s %s %d -> %d\n", u.Username, u.UniKey, existing[0].SecLevel, u.SecLevel)
+				if !*dryRun {
+					if err := database.DB.Model(existing[0]).Update("sec_level", u.SecLevel).Error; err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if *dryRun {
+			fmt.Printf("计划新增%d个用户，修改%d个用户的密级\n", created, updated)
+			return nil
+		}
+		fmt.Printf("已新增%d个用户，修改%d个用户的密级\n", created, updated)
+		if created+updated > 0 {
+			recordCliAudit("user", model.AuditActionImport, map[string]interface{}{
+				"created": created,
+				"updated": updated,
+			})
+		}
+		if updated > 0 {
+			fmt.Println("已登录用户的token密级在重新登录后生效")
+		}
+		return nil
+	})
+}
+
+func runRouteTest(args []string) error {
+	fs := newFlagSet("route test")
+	method := fs.String("X", http.MethodGet, "请求方法")
+	var headers multiFlag
+	fs.Var(&headers, "H", "请求头，格式为'名称: 值'，可以指定多个")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	header := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("请求头格式错误: %s", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	port, r, err := proxy.NewResolveRequest(*method, fs.Arg(0), header)
+	if err != nil {
+		return err
+	}
+
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	match, err := proxy.ResolveRoute(port, r)
+	if err != nil {
+		return err
+	}
+	if match == nil {
+		return errors.New("未匹配到路由，网关将返回404")
+	}
+	return printJSON(match)
+}
+
+// certInspection 证书及私钥的检查结果
+type certInspection struct {
+	Type         string                  `json:"type"`
+	Certificates []*util.CertificateInfo `json:"certificates"`
+	DaysLeft     int                     `json:"daysLeft"` // 第一个证书的剩余天数
+	KeyMatch     *bool                   `json:"keyMatch,omitempty"`
+	KeyError     string                  `json:"keyError,omitempty"`
+}
+
+func runCertInspect(args []string) error {
+	fs := newFlagSet("cert inspect")
+	id := fs.Uint64("id", 0, "证书ID")
+	name := fs.String("name", "", "证书名称")
+	keyFile := fs.String("key", "", "私钥文件，检查与证书文件是否匹配")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	type pair struct {
+		typ       string
+		cert, key string
+	}
+	var pairs []pair
+	switch {
+	case *id != 0 || *name != "":
+		closeDB, err := openDatabase()
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		cert := new(model.Certificate)
+		query := database.DB.Where("id = ?", *id)
+		if *name != "" {
+			query = database.DB.Where("cert_name = ?", *name)
+		}
+		if err = query.Limit(1).Find(cert).Error; err != nil {
+			return err
+		}
+		if cert.ID == 0 {
+			return errors.New("证书不存在")
+		}
+		pairs = []pair{{"cert", cert.CertPem, cert.KeyPem}, {"signCert", cert.SignCertPem, cert.SignKeyPem}, {"encCert", cert.EncCertPem, cert.EncKeyPem}}
+	case fs.NArg() == 1:
+		data, err := readInput(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		p := pair{typ: "cert", cert: string(data)}
+		if *keyFile != "" {
+			key, err := os.ReadFile(*keyFile)
+			if err != nil {
+				return err
+			}
+			p.key = string(key)
+		}
+		pairs = []pair{p}
+	default:
+		return errUsage
+	}
+
+	var result []*certInspection
+	for _, p := range pairs {
+		if p.cert == "" {
+			continue
+		}
+		infos, err := util.ParseCertificatePem([]byte(p.cert))
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.typ, err)
+		}
+		inspection := &certInspection{
+			Type:         p.typ,
+			Certificates: infos,
+			DaysLeft:     int(time.Until(time.UnixMilli(infos[0].NotAfter)).Hours() / 24),
+		}
+		if p.key != "" {
+			match := true
+			if err = util.CheckKeyPair([]byte(p.cert), []byte(p.key)); err != nil {
+				match, inspection.KeyError = false, err.Error()
+			}
+			inspection.KeyMatch = &match
+		}
+		result = append(result, inspection)
+	}
+	return printJSON(result)
+}
+
+func runTokenRevoke(args []string) error {
+	fs := newFlagSet("token revoke")
+	username := fs.String("user", "", "用户名")
+	var ids multiFlag
+	fs.Var(&ids, "id", "要撤销的token ID(token的SHA-256摘要)，可以指定多个，为空时撤销用户所有token")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errUsage
+	}
+	if store := config.GetString("token.store", proxy.TokenStoreRedis); store == proxy.TokenStoreMemory {
+		return errors.New("token存储为memory时token只保存在网关进程中，请通过管理接口撤销: POST /api/v1/token/revoke")
+	}
+
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	cache.Initial()
+	defer cache.Close()
+	if err = proxy.InitTokenStore(); err != nil {
+		return fmt.Errorf("初始化token存储失败: %w", err)
+	}
+
+	return withNode(func() error {
+		revoked, err := proxy.RevokeUserTokens(*username, ids)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("已撤销用户%s的token: %d个\n", *username, revoked)
+		if revoked > 0 {
+			recordCliAudit("token", model.AuditActionDelete, map[string]interface{}{
+				"username": *username,
+				"ids":      []string(ids),
+				"revoked":  revoked,
+			})
+			fmt.Println("其他节点的token本地缓存最迟在token.localTTL后失效")
+		}
+		return nil
+	})
+}
+
+func runSecretEncrypt(args []string) error {
+	fs := newFlagSet("secret encrypt")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errUsage
+	}
+	value := fs.Arg(0)
+	if fs.NArg() == 0 || value == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimRight(string(data), "\r\n")
+	}
+	if value == "" {
+		return errUsage
+	}
+	if err := secret.Initial(); err != nil {
+		return fmt.Errorf("加载主密钥失败: %w", err)
+	}
+	if !secret.Enabled() {
+		return errors.New("未配置主密钥，请设置环境变量SG_MASTER_KEY或secret.masterKeyFile")
+	}
+	encrypted, err := secret.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encrypted)
+	return nil
+}
+
+func runSecretRotate(args []string) error {
+	fs := newFlagSet("secret rotate")
+	dryRun := fs.Bool("dry-run", false, "只统计需要重新加密的记录，不修改数据")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	closeDB, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return withNode(func() error {
+		result, err := service.SecretService.Rotate(*dryRun)
+		if err != nil {
+			return err
+		}
+		if err = printJSON(result); err != nil {
+			return err
+		}
+		if *dryRun || len(result.Rotated) == 0 {
+			return nil
+		}
+		recordCliAudit("secret", model.AuditActionRotate, result)
+		fmt.Printf("已使用主密钥%s重新加密，旧的主密钥仍用于校验审计日志的摘要，需保留在主密钥配置中\n", result.KeyID)
+		return nil
+	})
+}
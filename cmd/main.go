@@ -7,31 +7,36 @@ import (
 	"github.com/panjf2000/ants/v2"
 	logger "github.com/sirupsen/logrus"
 	"net/http"
+	"os"
 	"security-gateway/internal/controller"
 	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
+	"security-gateway/internal/service"
 	"security-gateway/internal/task"
+	"security-gateway/pkg/cache"
 	"security-gateway/pkg/config"
 	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
 	"security-gateway/pkg/util"
+	"time"
 )
 
 //go:embed dist/*
 var embedPage embed.FS
 
 func main() {
-	startApp()
+	os.Exit(runCommand(os.Args[1:]))
 }
 
 func startApp() {
 	defer ants.Release()
 	config.InitialLogger()
 
-	err := util.InitNode(config.GetUint64("server.node"))
+	err := secret.Initial()
 	if err != nil {
-		logger.Errorln("初始化节点失败: ", err)
+		logger.Errorln("加载主密钥失败: ", err)
 		return
 	}
-
 	err = database.Initial()
 	if err != nil {
 		logger.Errorln("数据库初始化失败: ", err)
@@ -39,11 +44,28 @@ func startApp() {
 	}
 	defer database.Close()
 
-	err = database.AutoMigrate(model.Models...)
+	// 关闭自动迁移时需先执行migrate命令
+	if !config.IsSet("database.autoMigrate") || config.GetBool("database.autoMigrate") {
+		err = database.AutoMigrate(model.Models...)
+		if err != nil {
+			logger.Errorln("数据库迁移失败: ", err)
+			return
+		}
+	}
+
+	cache.Initial()
+	defer cache.Close()
+	if err = proxy.InitTokenStore(); err != nil {
+		logger.Errorln("初始化token存储失败: ", err)
+		return
+	}
+
+	node, err := registerNode()
 	if err != nil {
-		logger.Errorln("数据库迁移失败: ", err)
+		logger.Errorln("初始化节点失败: ", err)
 		return
 	}
+	defer service.ClusterService.UnregisterNode(node.ID)
 
 	if config.GetBool("task.checkHealth") {
 		err = task.StartHealthCheckTask()
@@ -51,9 +73,25 @@ func startApp() {
 			logger.Errorln(err)
 		}
 	}
+	if err = task.StartReconcileTask(); err != nil {
+		logger.Errorln(err)
+	}
+	if err = task.StartClusterTask(); err != nil {
+		logger.Errorln(err)
+	}
+	if err = task.StartTokenCleanupTask(); err != nil {
+		logger.Errorln(err)
+	}
+	if err = task.StartCertificateExpiryTask(); err != nil {
+		logger.Errorln(err)
+	}
 	task.Start()
 	defer task.Stop()
 
+	if err = controller.InitAuth(); err != nil {
+		logger.Errorln("初始化管理接口认证失败: ", err)
+		return
+	}
 	controller.InitProxyManager()
 	controller.InitRouter()
 
@@ -69,3 +107,24 @@ func startApp() {
 		return
 	}
 }
+
+// registerNode 注册集群节点并初始化雪花算法，未配置server.node时自动分配工作机器ID
+func registerNode() (node *model.Node, err error) {
+	workerId := int64(-1)
+	if config.IsSet("server.node") {
+		workerId = int64(config.GetUint64("server.node"))
+	}
+	hostname, _ := os.Hostname()
+	addr := config.GetString("cluster.addr", fmt.Sprintf("%s:%d", hostname, config.GetInt("server.port", 8080)))
+	expire := time.Duration(config.GetInt("cluster.nodeExpire", 30)) * time.Second
+
+	node, err = service.ClusterService.RegisterNode(workerId, hostname, addr, expire)
+	if err != nil {
+		return
+	}
+	if err = util.InitNode(node.ID); err != nil {
+		return
+	}
+	logger.Infof("节点注册成功, 节点ID: %d", node.ID)
+	return
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"strconv"
+)
+
+var AdminController = &adminController{}
+
+type adminController struct{}
+
+func (c *adminController) Add(ctx *fiber.Ctx) error {
+	param := new(struct {
+		Username string `json:"username"`
+		Name     string `json:"name"`
+		Role     string `json:"role"`
+		Password string `json:"password"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if param.Username == "" || !model.ValidAdminRole(param.Role) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough,
+		})
+	}
+	if !checkPasswordStrength(param.Password) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgPasswordWeak,
+		})
+	}
+	hashed, err := hashPassword(param.Password)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+
+	instance := &model.Admin{
+		Username: param.Username,
+		Name:     param.Name,
+		Role:     param.Role,
+		Password: hashed,
+	}
+	duplicated, success, err := service.AdminService.Add(instance)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	if duplicated {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDuplicated,
+			Msg:  ResponseMsgDuplicated,
+		})
+	}
+	if !success {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError,
+		})
+	}
+	recordAudit(ctx, auditResourceAdmin, model.AuditActionCreate, instance.ID, nil, instance)
+
+	return ctx.JSON(&CommonResponse{
+		Data: instance,
+	})
+}
+
+// Update 修改管理员的姓名、角色及是否禁用
+func (c *adminController) Update(ctx *fiber.Ctx) error {
+	instance := new(model.Admin)
+	if err := ctx.BodyParser(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if instance.ID == 0 || !model.ValidAdminRole(instance.Role) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough,
+		})
+	}
+
+	before := auditSnapshot(service.AdminService.Get, instance.ID)
+	success, err := service.AdminService.Update(instance)
+	if err != nil {
+		return adminErrorResponse(ctx, err)
+	}
+	if !success {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError,
+		})
+	}
+	recordAudit(ctx, auditResourceAdmin, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.AdminService.Get, instance.ID))
+
+	return ctx.JSON(&CommonResponse{
+		Data: success,
+	})
+}
+
+// ResetPassword 重置管理员密码，管理员之前的登录失效
+func (c *adminController) ResetPassword(ctx *fiber.Ctx) error {
+	param := new(struct {
+		ID       uint64 `json:"id,string"`
+		Password string `json:"password"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if param.ID == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " id",
+		})
+	}
+	if !checkPasswordStrength(param.Password) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgPasswordWeak,
+		})
+	}
+	hashed, err := hashPassword(param.Password)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	before := auditSnapshot(service.AdminService.Get, param.ID)
+	success, err := service.AdminService.UpdatePassword(param.ID, hashed)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	if success {
+		recordAudit(ctx, auditResourceAdmin, model.AuditActionUpdate, param.ID, before, auditSnapshot(service.AdminService.Get, param.ID))
+	}
+
+	return ctx.JSON(&CommonResponse{
+		Data: success,
+	})
+}
+
+func (c *adminController) Delete(ctx *fiber.Ctx) error {
+	id, err := strconv.ParseUint(ctx.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " id",
+		})
+	}
+	if admin := currentAdmin(ctx); admin != nil && admin.ID == id {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeForbidden,
+			Msg:  "不能删除当前登录的管理员",
+		})
+	}
+
+	before := auditSnapshot(service.AdminService.Get, id)
+	success, err := service.AdminService.Delete(id)
+	if err != nil {
+		return adminErrorResponse(ctx, err)
+	}
+	if !success {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError,
+		})
+	}
+	recordAudit(ctx, auditResourceAdmin, model.AuditActionDelete, id, before, nil)
+
+	return ctx.JSON(&CommonResponse{
+		Data: success,
+	})
+}
+
+func (c *adminController) List(ctx *fiber.Ctx) error {
+	condition := &model.Admin{
+		Username: ctx.Query("username"),
+		Role:     ctx.Query("role"),
+	}
+	page, err := strconv.Atoi(ctx.Query("page"))
+	if err != nil {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.Query("pageSize"))
+	if err != nil {
+		pageSize = 10
+	}
+
+	instances, total, err := service.AdminService.List(page, pageSize, condition)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"total": total,
+			"items": instances,
+		},
+	})
+}
+
+func adminErrorResponse(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrLastSystemAdmin) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeForbidden,
+			Msg:  err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Code: ResponseCodeDatabase,
+		Msg:  ResponseMsgDatabase + err.Error(),
+	})
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"strconv"
+	"time"
+)
+
+var AuditController = &auditController{}
+
+type auditController struct{}
+
+// auditQuery 审计日志的查询条件
+func (c *auditController) auditQuery(ctx *fiber.Ctx) (condition *model.AuditLog, startTime, endTime int64) {
+	condition = &model.AuditLog{
+		Actor:    ctx.Query("actor"),
+		Action:   ctx.Query("action"),
+		Resource: ctx.Query("resource"),
+	}
+	condition.ResourceID, _ = strconv.ParseUint(ctx.Query("resourceId"), 10, 64)
+	startTime, _ = strconv.ParseInt(ctx.Query("startTime"), 10, 64)
+	endTime, _ = strconv.ParseInt(ctx.Query("endTime"), 10, 64)
+	return
+}
+
+func (c *auditController) List(ctx *fiber.Ctx) error {
+	condition, startTime, endTime := c.auditQuery(ctx)
+	page, err := strconv.Atoi(ctx.Query("page"))
+	if err != nil {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.Query("pageSize"))
+	if err != nil {
+		pageSize = 10
+	}
+
+	instances, total, err := service.AuditLogService.List(page, pageSize, condition, startTime, endTime)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"total": total,
+			"items": instances,
+		},
+	})
+}
+
+// Verify 校验审计日志哈希链是否完整
+func (c *auditController) Verify(ctx *fiber.Ctx) error {
+	result, err := service.AuditLogService.Verify()
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: result,
+	})
+}
+
+// Export 导出审计日志文件，format为json(默认)或csv，包含摘要，使用主密钥的日志需持有主密钥才能离线校验
+func (c *auditController) Export(ctx *fiber.Ctx) error {
+	condition, startTime, endTime := c.auditQuery(ctx)
+	instances, err := service.AuditLogService.Export(condition, startTime, endTime)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+
+	var data []byte
+	format := ctx.Query("format", "json")
+	if format == "csv" {
+		data, err = auditCSV(instances)
+		ctx.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	} else {
+		format = "json"
+		data, err = json.Marshal(instances)
+		ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	}
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	ctx.Attachment("audit-" + time.Now().Format("20060102150405") + "." + format)
+	return ctx.Send(data)
+}
+
+func auditCSV(instances []*model.AuditLog) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	// BOM，Excel打开时正确识别UTF-8
+	buf.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"seq", "time", "actor", "actorRole", "ip", "action", "resource", "resourceId", "before", "after", "prevHash", "hash", "keyId"})
+	for _, l := range instances {
+		_ = w.Write([]string{
+			strconv.FormatUint(l.Seq, 10),
+			strconv.FormatInt(l.Time, 10),
+			l.Actor,
+			l.ActorRole,
+			l.Ip,
+			l.Action,
+			l.Resource,
+			strconv.FormatUint(l.ResourceID, 10),
+			l.Before,
+			l.After,
+			l.PrevHash,
+			l.Hash,
+			l.KeyID,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
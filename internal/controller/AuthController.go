@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/util"
+	"time"
+)
+
+var AuthController = &authController{}
+
+type authController struct{}
+
+type loginResult struct {
+	Token      string       `json:"token"`
+	ExpireTime int64        `json:"expireTime"`
+	Admin      *model.Admin `json:"admin"`
+}
+
+// Login 管理员登录，连续失败admin.maxLoginFailures次后锁定admin.lockMinutes分钟
+func (c *authController) Login(ctx *fiber.Ctx) error {
+	param := new(struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if param.Username == "" || param.Password == "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough,
+		})
+	}
+
+	admin, err := service.AdminService.GetByUsername(param.Username)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	now := time.Now()
+	if admin != nil && admin.LockedUntil > now.UnixMilli() {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnauthorized,
+			Msg:  "登录失败次数过多，账号已锁定，请稍后再试",
+		})
+	}
+	if admin == nil || admin.Disabled || !util.VerifyPassword(admin.Password, param.Password) {
+		if admin != nil {
+			failures := admin.LoginFailures + 1
+			lockedUntil := int64(0)
+			if failures >= config.GetInt("admin.maxLoginFailures", 5) {
+				lockedUntil = now.Add(time.Duration(config.GetInt("admin.lockMinutes", 15)) * time.Minute).UnixMilli()
+				failures = 0
+			}
+			service.AdminService.LoginFailed(admin.ID, failures, lockedUntil)
+		}
+		logger.Warnf("管理员登录失败: %s, IP: %s", param.Username, ctx.IP())
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnauthorized,
+			Msg:  "用户名或密码错误",
+		})
+	}
+
+	service.AdminService.LoginSucceeded(admin.ID, ctx.IP())
+	token, expireTime, err := newSession(admin)
+	if err != nil {
+		logger.Error(err)
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	logger.Infof("管理员登录: %s, IP: %s", admin.Username, ctx.IP())
+	return ctx.JSON(&CommonResponse{
+		Data: &loginResult{Token: token, ExpireTime: expireTime, Admin: admin},
+	})
+}
+
+// Profile 当前登录的管理员，未启用认证时admin为空
+func (c *authController) Profile(ctx *fiber.Ctx) error {
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"authEnabled": authEnabled,
+			"admin":       currentAdmin(ctx),
+		},
+	})
+}
+
+// Password 修改当前管理员的密码，修改后返回新的登录token
+func (c *authController) Password(ctx *fiber.Ctx) error {
+	admin := currentAdmin(ctx)
+	if admin == nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnauthorized,
+			Msg:  ResponseMsgUnauthorized,
+		})
+	}
+	param := new(struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if !util.VerifyPassword(admin.Password, param.OldPassword) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  "原密码错误",
+		})
+	}
+	if !checkPasswordStrength(param.NewPassword) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgPasswordWeak,
+		})
+	}
+	hashed, err := hashPassword(param.NewPassword)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	if _, err = service.AdminService.UpdatePassword(admin.ID, hashed); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+
+	if admin, err = service.AdminService.Get(admin.ID); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	token, expireTime, err := newSession(admin)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: &loginResult{Token: token, ExpireTime: expireTime, Admin: admin},
+	})
+}
@@ -1,10 +1,7 @@
 package controller
 
 import (
-	"crypto/tls"
 	"github.com/gofiber/fiber/v2"
-	logger "github.com/sirupsen/logrus"
-	"github.com/tjfoc/gmsm/gmtls"
 	"security-gateway/internal/model"
 	"security-gateway/internal/proxy"
 	"security-gateway/internal/service"
@@ -25,36 +22,12 @@ func (c *certificateController) Add(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// 检查证书是否有效
-	if instance.CertPem != "" || instance.KeyPem != "" {
-		pass := false
-		if instance.KeyPem != "" {
-			_, err := tls.X509KeyPair([]byte(instance.CertPem), []byte(instance.KeyPem))
-			pass = err == nil
-		}
-		if !pass {
-			return ctx.JSON(&CommonResponse{
-				Code: ResponseCodeParamParseError,
-				Msg:  ResponseMsgParamParseError + " RSA证书无效",
-			})
-		}
-	}
-	if instance.SignKeyPem != "" || instance.SignCertPem != "" || instance.EncCertPem != "" || instance.EncKeyPem != "" {
-		pass := false
-		if instance.SignCertPem != "" && instance.EncCertPem != "" && instance.EncKeyPem != "" {
-			_, err := gmtls.X509KeyPair([]byte(instance.SignCertPem), []byte(instance.SignKeyPem))
-			pass = err == nil
-			if pass {
-				_, err = gmtls.X509KeyPair([]byte(instance.EncCertPem), []byte(instance.EncKeyPem))
-				pass = err == nil
-			}
-		}
-		if !pass {
-			return ctx.JSON(&CommonResponse{
-				Code: ResponseCodeParamParseError,
-				Msg:  ResponseMsgParamParseError + " SM证书无效",
-			})
-		}
+	// 解析证书并检查私钥是否匹配
+	if err := service.CertificateService.Parse(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + err.Error(),
+		})
 	}
 
 	duplicated, success, err := service.CertificateService.Add(instance)
@@ -76,8 +49,11 @@ func (c *certificateController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceCertificate, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
-		Data: instance,
+		Data: instance.WithoutPrivateKeys(),
 	})
 }
 
@@ -90,37 +66,33 @@ func (c *certificateController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	// 检查证书是否有效
-	if instance.CertPem != "" || instance.KeyPem != "" {
-		pass := false
-		if instance.KeyPem != "" {
-			_, err := tls.X509KeyPair([]byte(instance.CertPem), []byte(instance.KeyPem))
-			pass = err == nil
+	// 接口不返回私钥，修改时未填写的证书及私钥使用原有的内容解析证书并检查私钥是否匹配
+	before := auditSnapshot(service.CertificateService.Get, instance.ID)
+	checked := *instance
+	if before != nil {
+		if checked.CertType == 0 {
+			checked.CertType = before.CertType
 		}
-		if !pass {
-			return ctx.JSON(&CommonResponse{
-				Code: ResponseCodeParamParseError,
-				Msg:  ResponseMsgParamParseError + " RSA证书无效",
-			})
-		}
-	}
-	if instance.SignKeyPem != "" || instance.SignCertPem != "" || instance.EncCertPem != "" || instance.EncKeyPem != "" {
-		pass := false
-		if instance.SignCertPem != "" && instance.EncCertPem != "" && instance.EncKeyPem != "" {
-			_, err := gmtls.X509KeyPair([]byte(instance.SignCertPem), []byte(instance.SignKeyPem))
-			pass = err == nil
-			if pass {
-				_, err = gmtls.X509KeyPair([]byte(instance.EncCertPem), []byte(instance.EncKeyPem))
-				pass = err == nil
+		for _, v := range []struct{ value, old *string }{
+			{&checked.CertPem, &before.CertPem},
+			{&checked.KeyPem, &before.KeyPem},
+			{&checked.SignCertPem, &before.SignCertPem},
+			{&checked.SignKeyPem, &before.SignKeyPem},
+			{&checked.EncCertPem, &before.EncCertPem},
+			{&checked.EncKeyPem, &before.EncKeyPem},
+		} {
+			if *v.value == "" {
+				*v.value = *v.old
 			}
 		}
-		if !pass {
-			return ctx.JSON(&CommonResponse{
-				Code: ResponseCodeParamParseError,
-				Msg:  ResponseMsgParamParseError + " SM证书无效",
-			})
-		}
 	}
+	if err := service.CertificateService.Parse(&checked); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + err.Error(),
+		})
+	}
+	instance.CertificateMeta = checked.CertificateMeta
 
 	duplicated, success, err := service.CertificateService.Update(instance)
 	if err != nil {
@@ -142,10 +114,13 @@ func (c *certificateController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.certificateUpdated(instance.ID)
+	// 证书变化后，更新引用该证书的服务及上游
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceCertificate, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.CertificateService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
-		Data: instance,
+		Data: instance.WithoutPrivateKeys(),
 	})
 }
 
@@ -160,6 +135,7 @@ func (c *certificateController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
+	before := auditSnapshot(service.CertificateService.Get, id)
 	success, err := service.CertificateService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -173,6 +149,11 @@ func (c *certificateController) Delete(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceCertificate, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
@@ -203,7 +184,7 @@ func (c *certificateController) Get(ctx *fiber.Ctx) error {
 		})
 	}
 	return ctx.JSON(&CommonResponse{
-		Data: instance,
+		Data: instance.WithoutPrivateKeys(),
 	})
 }
 
@@ -275,6 +256,12 @@ func (c *certificateController) AddServiceCertificate(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	// 服务的证书变化后，更新反向代理的证书
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceServiceCertificate, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -297,6 +284,7 @@ func (c *certificateController) DeleteServiceCertificate(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.CertificateService.GetServiceCertificate, id)
 	var success bool
 	success, err = service.CertificateService.DeleteServiceCertificate(id)
 	if err != nil {
@@ -311,6 +299,9 @@ func (c *certificateController) DeleteServiceCertificate(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+	proxy.Manager.NotifyChanged()
+	recordAudit(ctx, auditResourceServiceCertificate, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
@@ -336,18 +327,3 @@ func (c *certificateController) ListByDomain(ctx *fiber.Ctx) error {
 		Data: instances,
 	})
 }
-
-func (c *certificateController) certificateUpdated(id uint64) {
-	if id == 0 {
-		return
-	}
-	// 找到所有服务
-	services, _, err := service.ServiceService.List(1, 100, &model.Service{CertificateID: &id})
-	if err != nil {
-		logger.Error(err)
-		return
-	}
-	for _, serv := range services {
-		proxy.Manager.UpdateServiceCertificate(serv.ID)
-	}
-}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/util"
+	"time"
+)
+
+var ClusterController = &clusterController{}
+
+type clusterController struct{}
+
+// Nodes 集群节点及其已应用的配置版本
+func (c *clusterController) Nodes(ctx *fiber.Ctx) error {
+	version, err := service.ClusterService.GetConfigVersion()
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	nodes, err := service.ClusterService.ListNodes()
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+
+	aliveAfter := time.Now().Add(-time.Duration(config.GetInt("cluster.nodeExpire", 30)) * time.Second).UnixMilli()
+	result := make([]*domain.NodeStatus, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, &domain.NodeStatus{
+			Node:    node,
+			Alive:   node.HeartbeatTime >= aliveAfter,
+			Synced:  node.ConfigVersion >= version,
+			Current: node.ID == util.NodeId(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"version": version,
+			"nodes":   result,
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
+	"security-gateway/internal/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ConfigController = &configController{}
+
+type configController struct{}
+
+// Versions 分页查询配置版本
+func (c *configController) Versions(ctx *fiber.Ctx) error {
+	page, err := strconv.Atoi(ctx.Query("page"))
+	if err != nil {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(ctx.Query("pageSize"))
+	if err != nil {
+		pageSize = 10
+	}
+
+	instances, total, err := service.ConfigSnapshotService.List(page, pageSize)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"total": total,
+			"items": instances,
+		},
+	})
+}
+
+// Version 获取指定版本的配置内容，敏感字段只返回摘要
+func (c *configController) Version(ctx *fiber.Ctx) error {
+	version, err := strconv.ParseUint(ctx.Params("version"), 10, 64)
+	if err != nil || version == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " version",
+		})
+	}
+	instance, err := service.ConfigSnapshotService.GetByVersion(version)
+	if err != nil {
+		return configErrorResponse(ctx, err)
+	}
+	instance.Content = auditJSON(json.RawMessage(instance.Content))
+	return ctx.JSON(&CommonResponse{
+		Data: instance,
+	})
+}
+
+// Diff 比较两个版本，to为空时与当前配置比较，敏感字段只返回摘要
+func (c *configController) Diff(ctx *fiber.Ctx) error {
+	from, err := strconv.ParseUint(ctx.Query("from"), 10, 64)
+	if err != nil || from == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " from",
+		})
+	}
+	to, _ := strconv.ParseUint(ctx.Query("to"), 10, 64)
+
+	diff, err := service.ConfigSnapshotService.Diff(from, to)
+	if err != nil {
+		return configErrorResponse(ctx, err)
+	}
+	for _, change := range diff.Changes {
+		maskAuditValue(change.Before)
+		maskAuditValue(change.After)
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: diff,
+	})
+}
+
+// Rollback 回滚到指定版本并立即同步反向代理，回滚本身保存为新的版本。
+// 按三员分立，与当前配置相比有变化的配置中存在当前角色无权修改的配置时不回滚
+func (c *configController) Rollback(ctx *fiber.Ctx) error {
+	param := new(struct {
+		Version uint64 `json:"version"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if param.Version == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " version",
+		})
+	}
+
+	actor, role := auditActor(ctx)
+	instance, skipped, err := service.ConfigSnapshotService.Rollback(param.Version, actor, role, ctx.IP())
+	if err != nil {
+		return configErrorResponse(ctx, err)
+	}
+	recordAudit(ctx, auditResourceConfig, model.AuditActionRollback, param.Version, nil, map[string]interface{}{
+		"version":                    instance.Version,
+		"skippedServiceCertificates": skipped,
+	})
+
+	// 通知其他节点，并在本节点立即同步
+	proxy.Manager.NotifyChanged()
+	result, err := proxy.Manager.Reconcile()
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  "配置已回滚，同步反向代理失败: " + err.Error(),
+		})
+	}
+	instance.Content = ""
+	return ctx.JSON(&CommonResponse{
+		Data: map[string]interface{}{
+			"version":                    instance,
+			"skippedServiceCertificates": skipped,
+			"reconcile":                  result,
+		},
+	})
+}
+
+// Export 导出声明式配置文档，format为yaml(默认)或json，不包含证书及密钥
+func (c *configController) Export(ctx *fiber.Ctx) error {
+	format := configFormat(ctx)
+	doc, err := service.ConfigDeclarativeService.Export()
+	if err != nil {
+		return configErrorResponse(ctx, err)
+	}
+	data, err := domain.MarshalDeclarativeConfig(doc, format)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  err.Error(),
+		})
+	}
+	ctx.Attachment("gateway-" + time.Now().Format("20060102150405") + "." + format)
+	return ctx.Send(data)
+}
+
+// Import 导入声明式配置文档，按自然键新增或更新；dryRun=true时只返回计划的变化，不修改配置。
+// 按三员分立，有变化的配置中存在当前角色无权修改的配置时整体不导入
+func (c *configController) Import(ctx *fiber.Ctx) error {
+	doc, err := domain.ParseDeclarativeConfig(ctx.Body(), configFormat(ctx))
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + ": " + err.Error(),
+		})
+	}
+	dryRun := ctx.QueryBool("dryRun")
+	_, role := auditActor(ctx)
+	result, err := service.ConfigDeclarativeService.Import(doc, dryRun, role)
+	if err != nil {
+		var validationErr *service.ConfigValidationError
+		if errors.As(err, &validationErr) {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  validationErr.Error(),
+				Data: validationErr.Errors,
+			})
+		}
+		return configErrorResponse(ctx, err)
+	}
+	if dryRun || !result.Changed() {
+		return ctx.JSON(&CommonResponse{
+			Data: result,
+		})
+	}
+
+	recordAudit(ctx, auditResourceConfig, model.AuditActionImport, 0, nil, map[string]interface{}{
+		"created": result.Created,
+		"updated": result.Updated,
+	})
+	// config不属于configResources，导入后单独保存配置版本
+	snapshotConfig(ctx, auditResourceConfig+" "+model.AuditActionImport)
+	proxy.Manager.NotifyChanged()
+	return ctx.JSON(&CommonResponse{
+		Data: result,
+	})
+}
+
+// configFormat 配置文档的格式，未指定format时按Content-Type判断
+func configFormat(ctx *fiber.Ctx) string {
+	format := strings.ToLower(ctx.Query("format"))
+	if format == "" && strings.Contains(ctx.Get(fiber.HeaderContentType), "json") {
+		format = domain.ConfigFormatJson
+	}
+	if format != domain.ConfigFormatJson {
+		format = domain.ConfigFormatYaml
+	}
+	return format
+}
+
+func configErrorResponse(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrConfigSnapshotNotFound) {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDataNotExists,
+			Msg:  ResponseMsgDataNotExists,
+		})
+	}
+	var forbiddenErr *service.ConfigForbiddenError
+	if errors.As(err, &forbiddenErr) {
+		return ctx.Status(fiber.StatusForbidden).JSON(&CommonResponse{
+			Code: ResponseCodeForbidden,
+			Msg:  ResponseMsgForbidden + ": " + forbiddenErr.Error() + "，脱敏字段及用户信息路由需要安全管理员，其他配置需要系统管理员",
+			Data: forbiddenErr.Resources,
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Code: ResponseCodeDatabase,
+		Msg:  ResponseMsgDatabase + err.Error(),
+	})
+}
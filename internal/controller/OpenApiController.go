@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
+	"security-gateway/internal/service"
+	"strconv"
+)
+
+var OpenApiController = &openApiController{}
+
+type openApiController struct{}
+
+// Plan 上传服务的OpenAPI 3文档(YAML或JSON)，返回生成的路由及建议的脱敏字段，不修改配置。
+// 参数serviceId为服务ID，prefix为路由前缀，未传时使用文档第一个服务地址的路径
+func (c *openApiController) Plan(ctx *fiber.Ctx) error {
+	serviceID, err := strconv.ParseUint(ctx.Query("serviceId"), 10, 64)
+	if err != nil || serviceID == 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " serviceId",
+		})
+	}
+	var prefix *string
+	if ctx.Context().QueryArgs().Has("prefix") {
+		p := ctx.Query("prefix")
+		prefix = &p
+	}
+	plan, err := service.OpenApiService.Plan(serviceID, ctx.Body(), prefix)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: plan,
+	})
+}
+
+// Apply 应用审核后的路由及脱敏字段。按三员分立，新建路由需要系统管理员，新增脱敏字段需要安全管理员
+func (c *openApiController) Apply(ctx *fiber.Ctx) error {
+	req := new(domain.OpenApiApplyRequest)
+	if err := ctx.BodyParser(req); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if admin := currentAdmin(ctx); admin != nil {
+		for _, r := range req.Routes {
+			if (r.RouteID == 0 && admin.Role != model.AdminRoleSystem) || (len(r.Fields) > 0 && admin.Role != model.AdminRoleSecurity) {
+				return ctx.Status(fiber.StatusForbidden).JSON(&CommonResponse{
+					Code: ResponseCodeForbidden,
+					Msg:  ResponseMsgForbidden + ": 新建路由需要系统管理员，新增脱敏字段需要安全管理员",
+				})
+			}
+		}
+	}
+
+	result, err := service.OpenApiService.Apply(req)
+	if err != nil {
+		var validationErr *service.ConfigValidationError
+		if errors.As(err, &validationErr) {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " " + validationErr.Error(),
+				Data: validationErr.Errors,
+			})
+		}
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	if result.CreatedRoutes+result.CreatedFields > 0 {
+		recordAudit(ctx, auditResourceConfig, model.AuditActionImport, req.ServiceID, nil, map[string]interface{}{
+			"source":         "openapi",
+			"createdRoutes":  result.CreatedRoutes,
+			"createdFields":  result.CreatedFields,
+			"createdTargets": result.CreatedTargets,
+		})
+		// config不属于configResources，应用后单独保存配置版本
+		snapshotConfig(ctx, "openapi "+model.AuditActionImport+" "+strconv.FormatUint(req.ServiceID, 10))
+		proxy.Manager.NotifyChanged()
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: result,
+	})
+}
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	logger "github.com/sirupsen/logrus"
+	"net/http"
+	"security-gateway/internal/proxy"
+	"strings"
+)
+
+var ProxyController = &proxyController{}
+
+type proxyController struct{}
+
+// Resync 立即从数据库同步反向代理配置，返回本次同步的结果
+func (c *proxyController) Resync(ctx *fiber.Ctx) error {
+	result, err := proxy.Manager.Reconcile()
+	if err != nil {
+		logger.Error(err)
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: result,
+	})
+}
+
+// Status 最近一次同步的结果
+func (c *proxyController) Status(ctx *fiber.Ctx) error {
+	return ctx.JSON(&CommonResponse{
+		Data: proxy.Manager.LastReconcile(),
+	})
+}
+
+// Resolve 查看请求会由哪个路由处理：按运行中的反向代理匹配服务及路由，返回候选上游及健康状态、路径重写后的地址、
+// 脱敏字段及用户信息路由。参数url为完整的请求地址，method为请求方法，header为请求头(名称: 值)，可以指定多个
+func (c *proxyController) Resolve(ctx *fiber.Ctx) error {
+	rawUrl := ctx.Query("url")
+	if rawUrl == "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough + " url",
+		})
+	}
+	header := make(http.Header)
+	for _, h := range ctx.Context().QueryArgs().PeekMulti("header") {
+		name, value, ok := strings.Cut(string(h), ":")
+		if !ok {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " 请求头格式错误: " + string(h),
+			})
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	port, r, err := proxy.NewResolveRequest(ctx.Query("method"), rawUrl, header)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + err.Error(),
+		})
+	}
+	match, err := proxy.Manager.Resolve(port, r)
+	if err != nil {
+		logger.Error(err)
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	}
+	if match == nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDataNotExists,
+			Msg:  "未匹配到路由，网关将返回404",
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: match,
+	})
+}
+
+// CleanupTokens 立即清理已过期的token
+func (c *proxyController) CleanupTokens(ctx *fiber.Ctx) error {
+	result, err := proxy.CleanupTokens()
+	if err != nil {
+		logger.Error(err)
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+			Data: result,
+		})
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: result,
+	})
+}
+
+// TokenCleanupStatus 最近一次token清理的统计
+func (c *proxyController) TokenCleanupStatus(ctx *fiber.Ctx) error {
+	return ctx.JSON(&CommonResponse{
+		Data: proxy.LastTokenCleanup(),
+	})
+}
@@ -3,8 +3,11 @@ package controller
 import (
 	"github.com/gofiber/fiber/v2"
 	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
 	"security-gateway/internal/service"
+	"security-gateway/pkg/server"
 	"strconv"
+	"strings"
 )
 
 var RouteController = &routeController{}
@@ -20,6 +23,24 @@ func (c *routeController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
+	if msg := c.checkRewrite(instance); msg != "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + msg,
+		})
+	}
+	if errs, err := service.RouteService.Validate(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	} else if len(errs) > 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + strings.Join(errs, "; "),
+			Data: errs,
+		})
+	}
 
 	duplicated, success, err := service.RouteService.Add(instance)
 	if err != nil {
@@ -40,6 +61,11 @@ func (c *routeController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRoute, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -53,7 +79,26 @@ func (c *routeController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
+	if msg := c.checkRewrite(instance); msg != "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + msg,
+		})
+	}
+	if errs, err := service.RouteService.Validate(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeDatabase,
+			Msg:  ResponseMsgDatabase + err.Error(),
+		})
+	} else if len(errs) > 0 {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + " " + strings.Join(errs, "; "),
+			Data: errs,
+		})
+	}
 
+	before := auditSnapshot(service.RouteService.Get, instance.ID)
 	duplicated, success, err := service.RouteService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -73,6 +118,12 @@ func (c *routeController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	// 路由信息变化后，更新反向代理
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRoute, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.RouteService.Get, instance.ID))
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -89,6 +140,7 @@ func (c *routeController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
+	before := auditSnapshot(service.RouteService.Get, id)
 	success, err := service.RouteService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -102,6 +154,12 @@ func (c *routeController) Delete(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	// 删除成功后，将路由从反向代理中删除
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRoute, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
@@ -215,3 +273,22 @@ func (c *routeController) ListWithTargets(ctx *fiber.Ctx) error {
 		},
 	})
 }
+
+// checkRewrite 检查路径重写配置，返回错误提示
+func (c *routeController) checkRewrite(instance *model.Route) string {
+	switch instance.RewriteType {
+	case 0, model.RouteRewriteKeep, model.RouteRewriteStripPrefix:
+	case model.RouteRewriteReplacePrefix, model.RouteRewriteRegex:
+		if instance.RewriteValue == nil || *(instance.RewriteValue) == "" {
+			return " 缺少路径重写内容"
+		}
+	default:
+		return " 路径重写方式无效"
+	}
+	if instance.Uri != nil {
+		if _, err := server.CompilePathPattern(*(instance.Uri)); err != nil {
+			return " " + err.Error()
+		}
+	}
+	return ""
+}
@@ -42,7 +42,9 @@ func (c *routeFieldController) Add(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldAdded(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteField, model.AuditActionCreate, instance.ID, nil, instance)
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -58,6 +60,7 @@ func (c *routeFieldController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.RouteFieldService.Get, instance.ID)
 	duplicated, success, err := service.RouteFieldService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -78,7 +81,9 @@ func (c *routeFieldController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldUpdated(instance.ID)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteField, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.RouteFieldService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -96,14 +101,7 @@ func (c *routeFieldController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
-	oldInstance, err := service.RouteFieldService.Get(id)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-	}
-
+	before := auditSnapshot(service.RouteFieldService.Get, id)
 	success, err := service.RouteFieldService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -118,7 +116,9 @@ func (c *routeFieldController) Delete(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldDeleted(oldInstance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteField, model.AuditActionDelete, id, before, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -193,62 +193,3 @@ func (c *routeFieldController) List(ctx *fiber.Ctx) error {
 		},
 	})
 }
-
-func (c *routeFieldController) fieldDeleted(field *model.RouteField) {
-	if field == nil {
-		return
-	}
-	// 获取路由
-	route, err := service.RouteService.Get(field.RouteID)
-	if err != nil {
-		return
-	}
-	if route == nil {
-		return
-	}
-	// 获取服务
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil {
-		return
-	}
-	if serv == nil {
-		return
-	}
-
-	proxy.Manager.RemoveRouteField(serv, route, field.FieldName)
-}
-
-func (c *routeFieldController) fieldUpdated(id uint64) {
-	// 获取字段信息
-	instance, err := service.RouteFieldService.Get(id)
-	if err != nil || instance == nil {
-		return
-	}
-	// 获取路由
-	route, err := service.RouteService.Get(instance.RouteID)
-	if err != nil || route == nil {
-		return
-	}
-	// 获取服务
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-
-	proxy.Manager.UpdateRouteField(serv, route, instance)
-}
-
-func (c *routeFieldController) fieldAdded(instance *model.RouteField) {
-	// 获取路由
-	route, err := service.RouteService.Get(instance.RouteID)
-	if err != nil || route == nil {
-		return
-	}
-	// 获取服务
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-
-	proxy.Manager.UpdateRouteField(serv, route, instance)
-}
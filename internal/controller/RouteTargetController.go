@@ -2,7 +2,6 @@ package controller
 
 import (
 	"github.com/gofiber/fiber/v2"
-	logger "github.com/sirupsen/logrus"
 	"security-gateway/internal/model"
 	"security-gateway/internal/proxy"
 	"security-gateway/internal/service"
@@ -44,7 +43,9 @@ func (c *routeTargetController) Add(ctx *fiber.Ctx) error {
 	}
 
 	// 增加成功后，将路由添加到反向代理
-	go c.addProxy(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteTarget, model.AuditActionCreate, instance.ID, nil, instance)
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -60,6 +61,7 @@ func (c *routeTargetController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.RouteTargetService.Get, instance.ID)
 	duplicated, success, err := service.RouteTargetService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -79,6 +81,9 @@ func (c *routeTargetController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceRouteTarget, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.RouteTargetService.Get, instance.ID))
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -95,14 +100,7 @@ func (c *routeTargetController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
-	oldInstance, err := service.RouteTargetService.Get(id)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-	}
-
+	before := auditSnapshot(service.RouteTargetService.Get, id)
 	success, err := service.RouteTargetService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -118,7 +116,9 @@ func (c *routeTargetController) Delete(ctx *fiber.Ctx) error {
 	}
 
 	// 删除成功后，将路由从反向代理中删除
-	go c.removeProxy(oldInstance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteTarget, model.AuditActionDelete, id, before, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -162,7 +162,9 @@ func (c *routeTargetController) DeleteByRouteIDAndUpstreamID(ctx *fiber.Ctx) err
 	}
 
 	// 删除成功后，将路由从反向代理中删除
-	go c.removeProxy(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceRouteTarget, model.AuditActionDelete, instance.ID, instance, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -239,87 +241,6 @@ func (c *routeTargetController) List(ctx *fiber.Ctx) error {
 	})
 }
 
-func (c *routeTargetController) addProxy(instance *model.RouteTarget) {
-	// 1、获取路由信息
-	route, err := service.RouteService.Get(*instance.RouteID)
-	if err != nil {
-		logger.Error("获取路由信息失败", err)
-		return
-	}
-	if route == nil {
-		logger.Error("路由信息不存在")
-		return
-	}
-	// 2、获取路由对应的服务信息
-	if route.ServiceID == nil {
-		logger.Error("路由未绑定服务")
-		return
-	}
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil {
-		logger.Error("获取服务信息失败", err)
-		return
-	}
-	if serv == nil {
-		logger.Error("服务信息不存在")
-		return
-	}
-	// 3、获取服务对应的上游信息
-	upstream, err := service.UpstreamService.Get(*instance.UpstreamID)
-	if err != nil {
-		logger.Error("获取上游信息失败", err)
-		return
-	}
-	if upstream == nil {
-		logger.Error("上游信息不存在")
-		return
-	}
-
-	// 4、添加到反向代理
-	proxy.Manager.AddRoute(serv, route, upstream, instance.Weight)
-}
-
-func (c *routeTargetController) removeProxy(instance *model.RouteTarget) {
-	if instance == nil {
-		return
-	}
-
-	// 1、获取路由信息
-	route, err := service.RouteService.Get(*instance.RouteID)
-	if err != nil {
-		logger.Error("获取路由信息失败", err)
-		return
-	}
-	if route == nil {
-		logger.Error("路由信息不存在")
-		return
-	}
-	// 2、获取路由对应的服务信息
-	if route.ServiceID == nil {
-		logger.Error("路由未绑定服务")
-		return
-	}
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil {
-		logger.Error("获取服务信息失败", err)
-		return
-	}
-	if serv == nil {
-		logger.Error("服务信息不存在")
-		return
-	}
-
-	// 3、获取上游信息
-	upstream, err := service.UpstreamService.Get(*instance.UpstreamID)
-	if err != nil {
-		logger.Error("获取上游信息失败", err)
-		return
-	}
-
-	// 4、删除反向代理
-	proxy.Manager.RemoveRoute(*serv.Port, *serv.Domain, *route.Uri, *upstream.TargetUrl)
-}
-
 func (c *routeTargetController) Save(ctx *fiber.Ctx) error {
 	instance := new(model.RouteTarget)
 	if err := ctx.BodyParser(instance); err != nil {
@@ -329,6 +250,11 @@ func (c *routeTargetController) Save(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// 未指定ID时按路由和上游更新已有的记录
+	before := auditSnapshot(service.RouteTargetService.Get, instance.ID)
+	if before == nil && instance.RouteID != nil && instance.UpstreamID != nil {
+		before, _ = service.RouteTargetService.GetByRouteIDAndUpstreamID(*instance.RouteID, *instance.UpstreamID)
+	}
 	success, isAdd, err := service.RouteTargetService.Save(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -343,56 +269,16 @@ func (c *routeTargetController) Save(ctx *fiber.Ctx) error {
 		})
 	}
 
+	// 保存成功后，从数据库同步反向代理
+	proxy.Manager.NotifyChanged()
+
 	if isAdd {
-		// 增加成功后，将路由添加到反向代理
-		go c.addProxy(instance)
+		recordAudit(ctx, auditResourceRouteTarget, model.AuditActionCreate, instance.ID, nil, instance)
 	} else {
-		// 更新成功后，将路由从反向代理中更新
-		go c.updateProxy(instance)
+		recordAudit(ctx, auditResourceRouteTarget, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.RouteTargetService.Get, instance.ID))
 	}
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
 }
-
-func (c *routeTargetController) updateProxy(instance *model.RouteTarget) {
-	// 1、获取路由信息
-	route, err := service.RouteService.Get(*instance.RouteID)
-	if err != nil {
-		logger.Error("获取路由信息失败", err)
-		return
-	}
-	if route == nil {
-		logger.Error("路由信息不存在")
-		return
-	}
-	// 2、获取路由对应的服务信息
-	if route.ServiceID == nil {
-		logger.Error("路由未绑定服务")
-		return
-	}
-	serv, err := service.ServiceService.Get(*route.ServiceID)
-	if err != nil {
-		logger.Error("获取服务信息失败", err)
-		return
-	}
-	if serv == nil {
-		logger.Error("服务信息不存在")
-		return
-	}
-	// 3、获取服务对应的上游信息
-	upstream, err := service.UpstreamService.Get(*instance.UpstreamID)
-	if err != nil {
-		logger.Error("获取上游信息失败", err)
-		return
-	}
-	if upstream == nil {
-		logger.Error("上游信息不存在")
-		return
-	}
-
-	// 4、删除旧的反向代理
-	proxy.Manager.RemoveRoute(*serv.Port, *serv.Domain, *route.Uri, *upstream.TargetUrl)
-	// 5、添加新的反向代理
-	proxy.Manager.AddRoute(serv, route, upstream, instance.Weight)
-}
@@ -6,6 +6,7 @@ import (
 	"security-gateway/internal/model"
 	"security-gateway/internal/proxy"
 	"security-gateway/internal/service"
+	"security-gateway/pkg/server"
 	"strconv"
 )
 
@@ -22,6 +23,23 @@ func (c *serviceController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
+	// 检查域名，支持通配符域名(*.example.com)和正则域名({...})
+	if instance.Domain != nil {
+		if err := server.ValidateDomain(*(instance.Domain)); err != nil {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " " + err.Error(),
+			})
+		}
+	}
+	if instance.Port != nil {
+		if err := service.ServiceService.CheckPort(*(instance.Port)); err != nil {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " " + err.Error(),
+			})
+		}
+	}
 
 	duplicated, success, err := service.ServiceService.Add(instance)
 	if err != nil {
@@ -42,6 +60,9 @@ func (c *serviceController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+	proxy.Manager.NotifyChanged()
+	recordAudit(ctx, auditResourceService, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -55,15 +76,25 @@ func (c *serviceController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
-
-	oldInstance, err := service.ServiceService.Get(instance.ID)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
+	// 检查域名，支持通配符域名(*.example.com)和正则域名({...})
+	if instance.Domain != nil {
+		if err := server.ValidateDomain(*(instance.Domain)); err != nil {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " " + err.Error(),
+			})
+		}
+	}
+	if instance.Port != nil {
+		if err := service.ServiceService.CheckPort(*(instance.Port)); err != nil {
+			return ctx.JSON(&CommonResponse{
+				Code: ResponseCodeParamParseError,
+				Msg:  ResponseMsgParamParseError + " " + err.Error(),
+			})
+		}
 	}
 
+	before := auditSnapshot(service.ServiceService.Get, instance.ID)
 	duplicated, success, err := service.ServiceService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -84,15 +115,10 @@ func (c *serviceController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if oldInstance.Port != instance.Port || oldInstance.Domain != instance.Domain {
-		// 如果端口发生变化，需要更新manager中的端口
-		go proxy.Manager.UpdateService(oldInstance, instance)
-	}
+	// 端口、域名、证书等变化由反向代理从数据库同步
+	proxy.Manager.NotifyChanged()
 
-	if instance.CertificateID != nil && *(oldInstance.CertificateID) != *(instance.CertificateID) {
-		// 如果证书发生变化，需要更新manager中的证书
-		go proxy.Manager.UpdateServiceCertificate(instance.ID)
-	}
+	recordAudit(ctx, auditResourceService, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.ServiceService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -116,14 +142,7 @@ func (c *serviceController) Delete(ctx *fiber.Ctx) error {
 		})
 	}
 
-	instance, err := service.ServiceService.Get(id)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-	}
-
+	before := auditSnapshot(service.ServiceService.Get, id)
 	success, err := service.ServiceService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -138,7 +157,9 @@ func (c *serviceController) Delete(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go proxy.Manager.RemoveService(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceService, model.AuditActionDelete, id, before, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -250,14 +271,7 @@ func (c *serviceController) UpdateCert(ctx *fiber.Ctx) error {
 		})
 	}
 
-	oldInstance, err := service.ServiceService.Get(instance.ID)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-	}
-
+	before := auditSnapshot(service.ServiceService.Get, instance.ID)
 	success, err := service.ServiceService.UpdateCert(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -272,10 +286,9 @@ func (c *serviceController) UpdateCert(ctx *fiber.Ctx) error {
 		})
 	}
 
-	if instance.CertificateID != nil && oldInstance.CertificateID != instance.CertificateID {
-		// 如果证书发生变化，需要更新manager中的证书
-		go proxy.Manager.UpdateServiceCertificate(instance.ID)
-	}
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceService, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.ServiceService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
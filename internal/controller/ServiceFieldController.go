@@ -42,7 +42,9 @@ func (c *serviceFieldController) Add(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldAdded(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceServiceField, model.AuditActionCreate, instance.ID, nil, instance)
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -58,6 +60,7 @@ func (c *serviceFieldController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.ServiceFieldService.Get, instance.ID)
 	duplicated, success, err := service.ServiceFieldService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -78,7 +81,9 @@ func (c *serviceFieldController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldUpdated(instance.ID)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceServiceField, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.ServiceFieldService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -96,15 +101,7 @@ func (c *serviceFieldController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
-	oldInstance, err := service.ServiceFieldService.Get(id)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-
-	}
-
+	before := auditSnapshot(service.ServiceFieldService.Get, id)
 	success, err := service.ServiceFieldService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -119,7 +116,9 @@ func (c *serviceFieldController) Delete(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.fieldDeleted(oldInstance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceServiceField, model.AuditActionDelete, id, before, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -194,44 +193,3 @@ func (c *serviceFieldController) List(ctx *fiber.Ctx) error {
 		},
 	})
 }
-
-func (c *serviceFieldController) fieldDeleted(field *model.ServiceField) {
-	if field == nil {
-		return
-	}
-	// 获取服务
-	serv, err := service.ServiceService.Get(field.ServiceID)
-	if err != nil {
-		return
-	}
-	if serv == nil {
-		return
-	}
-
-	proxy.Manager.RemoveServiceField(*serv.Port, *serv.Domain, field.FieldName)
-}
-
-func (c *serviceFieldController) fieldUpdated(id uint64) {
-	// 获取字段信息
-	instance, err := service.ServiceFieldService.Get(id)
-	if err != nil || instance == nil {
-		return
-	}
-	// 获取服务
-	serv, err := service.ServiceService.Get(instance.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-
-	proxy.Manager.UpdateServiceField(serv, instance)
-}
-
-func (c *serviceFieldController) fieldAdded(instance *model.ServiceField) {
-	// 获取服务
-	serv, err := service.ServiceService.Get(instance.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-
-	proxy.Manager.UpdateServiceField(serv, instance)
-}
@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
+)
+
+var TokenController = &tokenController{}
+
+type tokenController struct{}
+
+// List 获取用户在所有服务下未过期的token
+func (c *tokenController) List(ctx *fiber.Ctx) error {
+	username := ctx.Query("username")
+	if username == "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough,
+		})
+	}
+	list, err := proxy.ListUserTokens(username)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	if list == nil {
+		list = []*proxy.TokenRecord{}
+	}
+	return ctx.JSON(&CommonResponse{
+		Data: list,
+	})
+}
+
+// Revoke 撤销用户的token，ids为空时撤销用户所有token
+func (c *tokenController) Revoke(ctx *fiber.Ctx) error {
+	param := new(struct {
+		Username string   `json:"username"`
+		Ids      []string `json:"ids"`
+	})
+	if err := ctx.BodyParser(param); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError,
+		})
+	}
+	if param.Username == "" {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamNotEnough,
+			Msg:  ResponseMsgParamNotEnough,
+		})
+	}
+	revoked, err := proxy.RevokeUserTokens(param.Username, param.Ids)
+	if err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeUnknownError,
+			Msg:  ResponseMsgUnknownError + err.Error(),
+		})
+	}
+	if revoked > 0 {
+		recordAudit(ctx, auditResourceToken, model.AuditActionDelete, 0, map[string]interface{}{
+			"username": param.Username,
+			"ids":      param.Ids,
+			"revoked":  revoked,
+		}, nil)
+	}
+
+	return ctx.JSON(&CommonResponse{
+		Data: revoked,
+	})
+}
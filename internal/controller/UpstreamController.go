@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	logger "github.com/sirupsen/logrus"
 	"security-gateway/internal/model"
+	"security-gateway/internal/proxy"
 	"security-gateway/internal/service"
 	"strconv"
 )
@@ -41,6 +42,9 @@ func (c *upstreamController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUpstream, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -55,6 +59,7 @@ func (c *upstreamController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.UpstreamService.Get, instance.ID)
 	duplicated, success, err := service.UpstreamService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -74,6 +79,12 @@ func (c *upstreamController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	// 上游信息变化后，更新反向代理
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceUpstream, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.UpstreamService.Get, instance.ID))
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -90,6 +101,7 @@ func (c *upstreamController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
+	before := auditSnapshot(service.UpstreamService.Get, id)
 	success, err := service.UpstreamService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -103,6 +115,11 @@ func (c *upstreamController) Delete(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceUpstream, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
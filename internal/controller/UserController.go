@@ -43,6 +43,9 @@ func (c *userController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUser, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -65,6 +68,7 @@ func (c *userController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.UserService.Get, instance.ID)
 	duplicated, success, err := service.UserService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -89,6 +93,8 @@ func (c *userController) Update(ctx *fiber.Ctx) error {
 		go c.updateUserLevel(instance.ID)
 	}
 
+	recordAudit(ctx, auditResourceUser, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.UserService.Get, instance.ID))
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -105,6 +111,7 @@ func (c *userController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
+	before := auditSnapshot(service.UserService.Get, id)
 	success, err := service.UserService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -118,6 +125,9 @@ func (c *userController) Delete(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUser, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
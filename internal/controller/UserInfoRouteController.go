@@ -21,6 +21,12 @@ func (c *userInfoRouteController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
+	if err := proxy.CheckUserInfoRoute(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + err.Error(),
+		})
+	}
 
 	duplicated, success, err := service.UserInfoRouteService.Add(instance)
 	if err != nil {
@@ -42,7 +48,9 @@ func (c *userInfoRouteController) Add(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.infoAdded(instance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceUserInfoRoute, model.AuditActionCreate, instance.ID, nil, instance)
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -57,7 +65,14 @@ func (c *userInfoRouteController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgParamParseError,
 		})
 	}
+	if err := proxy.CheckUserInfoRoute(instance); err != nil {
+		return ctx.JSON(&CommonResponse{
+			Code: ResponseCodeParamParseError,
+			Msg:  ResponseMsgParamParseError + err.Error(),
+		})
+	}
 
+	before := auditSnapshot(service.UserInfoRouteService.Get, instance.ID)
 	duplicated, success, err := service.UserInfoRouteService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -78,7 +93,9 @@ func (c *userInfoRouteController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.infoUpdated(instance.ID)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceUserInfoRoute, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.UserInfoRouteService.Get, instance.ID))
 
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
@@ -96,15 +113,7 @@ func (c *userInfoRouteController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
-	oldInstance, err := service.UserInfoRouteService.Get(id)
-	if err != nil {
-		return ctx.JSON(&CommonResponse{
-			Code: ResponseCodeDatabase,
-			Msg:  ResponseMsgDatabase + err.Error(),
-		})
-
-	}
-
+	before := auditSnapshot(service.UserInfoRouteService.Get, id)
 	success, err := service.UserInfoRouteService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -119,7 +128,9 @@ func (c *userInfoRouteController) Delete(ctx *fiber.Ctx) error {
 		})
 	}
 
-	go c.infoDeleted(oldInstance)
+	proxy.Manager.NotifyChanged()
+
+	recordAudit(ctx, auditResourceUserInfoRoute, model.AuditActionDelete, id, before, nil)
 
 	return ctx.JSON(&CommonResponse{
 		Data: success,
@@ -189,42 +200,6 @@ func (c *userInfoRouteController) List(ctx *fiber.Ctx) error {
 	})
 }
 
-func (c *userInfoRouteController) infoAdded(instance *model.UserInfoRoute) {
-	// 获取服务
-	serv, err := service.ServiceService.Get(instance.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-	// 添加到反向代理
-	proxy.Manager.AddUserRoute(serv, instance)
-}
-
-func (c *userInfoRouteController) infoUpdated(id uint64) {
-	// 获取服务
-	instance, err := service.UserInfoRouteService.Get(id)
-	if err != nil || instance == nil {
-		return
-	}
-	serv, err := service.ServiceService.Get(instance.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-	// 更新反向代理
-	proxy.Manager.UpdateUserRoute(serv, instance)
-}
-
-func (c *userInfoRouteController) infoDeleted(instance *model.UserInfoRoute) {
-	if instance == nil {
-		return
-	}
-	serv, err := service.ServiceService.Get(instance.ServiceID)
-	if err != nil || serv == nil {
-		return
-	}
-	// 删除反向代理
-	proxy.Manager.RemoveUserRoute(*serv.Port, *serv.Domain)
-}
-
 func (c *userInfoRouteController) GetByService(ctx *fiber.Ctx) error {
 	serviceIdStr := ctx.Params("serviceId")
 	if serviceIdStr == "" {
@@ -42,6 +42,9 @@ func (c *userServiceLevelController) Add(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUserServiceLevel, model.AuditActionCreate, instance.ID, nil, instance)
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -56,6 +59,7 @@ func (c *userServiceLevelController) Update(ctx *fiber.Ctx) error {
 		})
 	}
 
+	before := auditSnapshot(service.UserServiceLevelService.Get, instance.ID)
 	duplicated, success, err := service.UserServiceLevelService.Update(instance)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -75,6 +79,9 @@ func (c *userServiceLevelController) Update(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUserServiceLevel, model.AuditActionUpdate, instance.ID, before, auditSnapshot(service.UserServiceLevelService.Get, instance.ID))
+
 	return ctx.JSON(&CommonResponse{
 		Data: instance,
 	})
@@ -91,6 +98,7 @@ func (c *userServiceLevelController) Delete(ctx *fiber.Ctx) error {
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 
+	before := auditSnapshot(service.UserServiceLevelService.Get, id)
 	success, err := service.UserServiceLevelService.Delete(id)
 	if err != nil {
 		return ctx.JSON(&CommonResponse{
@@ -104,6 +112,9 @@ func (c *userServiceLevelController) Delete(ctx *fiber.Ctx) error {
 			Msg:  ResponseMsgUnknownError,
 		})
 	}
+
+	recordAudit(ctx, auditResourceUserServiceLevel, model.AuditActionDelete, id, before, nil)
+
 	return ctx.JSON(&CommonResponse{
 		Data: success,
 	})
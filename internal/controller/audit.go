@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tjfoc/gmsm/sm3"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"strconv"
+)
+
+// 审计日志中的资源类型
+const (
+	auditResourceService            = "service"
+	auditResourceRoute              = "route"
+	auditResourceRouteTarget        = "routeTarget"
+	auditResourceUpstream           = "upstream"
+	auditResourceServiceField       = "serviceField"
+	auditResourceRouteField         = "routeField"
+	auditResourceUser               = "user"
+	auditResourceUserServiceLevel   = "userServiceLevel"
+	auditResourceUserInfoRoute      = "userInfoRoute"
+	auditResourceCertificate        = "certificate"
+	auditResourceServiceCertificate = "serviceCertificate"
+	auditResourceAdmin              = "admin"
+	auditResourceToken              = "token"
+	auditResourceConfig             = "config"
+)
+
+// configResources 属于网关配置的资源，修改后保存配置版本
+var configResources = map[string]bool{
+	auditResourceService:            true,
+	auditResourceRoute:              true,
+	auditResourceRouteTarget:        true,
+	auditResourceUpstream:           true,
+	auditResourceServiceField:       true,
+	auditResourceRouteField:         true,
+	auditResourceUserInfoRoute:      true,
+	auditResourceServiceCertificate: true,
+}
+
+// auditSensitiveKeys 审计日志中不保存明文的字段，只保存其SM3摘要，可以判断是否修改
+var auditSensitiveKeys = map[string]bool{
+	"keyPem":       true,
+	"signKeyPem":   true,
+	"encKeyPem":    true,
+	"clientSecret": true,
+	"jwtKey":       true,
+	"password":     true,
+}
+
+// auditSnapshot 读取资源当前的数据，用于记录修改前后的内容，读取失败时为nil
+func auditSnapshot[T any](get func(uint64) (*T, error), id uint64) *T {
+	if id == 0 {
+		return nil
+	}
+	instance, err := get(id)
+	if err != nil {
+		return nil
+	}
+	return instance
+}
+
+// recordAudit 记录配置修改的审计日志，before、after为修改前后的数据，记录失败只输出日志，不影响修改结果；
+// 网关配置修改后同时保存配置版本
+func recordAudit(ctx *fiber.Ctx, resource, action string, resourceId uint64, before, after interface{}) {
+	instance := &model.AuditLog{
+		Ip:         ctx.IP(),
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceId,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+	}
+	instance.Actor, instance.ActorRole = auditActor(ctx)
+	if err := service.AuditLogService.Append(instance); err != nil {
+		logger.Errorf("记录审计日志失败: %s %s %d, %v", action, resource, resourceId, err)
+	}
+	if configResources[resource] {
+		snapshotConfig(ctx, resource+" "+action+" "+strconv.FormatUint(resourceId, 10))
+	}
+}
+
+// auditActor 当前操作人及角色，未启用认证时为anonymous
+func auditActor(ctx *fiber.Ctx) (actor, role string) {
+	if admin := currentAdmin(ctx); admin != nil {
+		return admin.Username, admin.Role
+	}
+	return "anonymous", ""
+}
+
+// snapshotConfig 网关配置修改后保存配置版本，配置未变化时不保存
+func snapshotConfig(ctx *fiber.Ctx, summary string) {
+	actor, _ := auditActor(ctx)
+	if _, _, err := service.ConfigSnapshotService.Snapshot(actor, ctx.IP(), summary); err != nil {
+		logger.Errorf("保存配置版本失败: %s, %v", summary, err)
+	}
+}
+
+// auditJSON 将数据转为json，敏感字段替换为摘要
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil || bytes.Equal(data, []byte("null")) {
+		return ""
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err = decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	if data, err = json.Marshal(maskAuditValue(value)); err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func maskAuditValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			if s, ok := item.(string); ok && auditSensitiveKeys[k] {
+				if s != "" {
+					digest := sm3.Sm3Sum([]byte(s))
+					value[k] = "sm3:" + hex.EncodeToString(digest)
+				}
+				continue
+			}
+			value[k] = maskAuditValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = maskAuditValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/database"
+	"strings"
+	"testing"
+)
+
+func TestAuditTrail(t *testing.T) {
+	initTestDatabase(t)
+
+	app := fiber.New()
+	app.Post("/cert", func(ctx *fiber.Ctx) error {
+		ctx.Locals(localsAdmin, &model.Admin{Username: "sysadmin", Role: model.AdminRoleSystem})
+		before := &model.Certificate{ID: 1, CertName: "a", KeyPem: "old-key"}
+		after := &model.Certificate{ID: 1, CertName: "b", KeyPem: "new-key"}
+		recordAudit(ctx, auditResourceCertificate, model.AuditActionUpdate, 1, before, after)
+		return ctx.SendString("ok")
+	})
+	for i := 0; i < 3; i++ {
+		if _, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/cert", nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, total, err := service.AuditLogService.List(1, 10, &model.AuditLog{}, 0, 0)
+	if err != nil || total != 3 {
+		t.Fatalf("审计日志数量错误: %d, %v", total, err)
+	}
+	l := logs[0]
+	if l.Seq != 3 || l.Actor != "sysadmin" || l.ActorRole != model.AdminRoleSystem || l.Ip == "" {
+		t.Errorf("审计日志内容错误: %+v", l)
+	}
+	if strings.Contains(l.Before+l.After, "-key") || !strings.Contains(l.After, `"keyPem":"sm3:`) {
+		t.Errorf("私钥未脱敏: %s %s", l.Before, l.After)
+	}
+
+	result, err := service.AuditLogService.Verify()
+	if err != nil || !result.Valid || result.Total != 3 || result.LastHash != l.Hash {
+		t.Fatalf("哈希链校验失败: %+v, %v", result, err)
+	}
+
+	// 篡改第2条日志的内容
+	database.DB.Model(&model.AuditLog{}).Where("seq = ?", 2).Update("after", "{}")
+	if result, _ = service.AuditLogService.Verify(); result.Valid || result.BrokenSeq != 2 {
+		t.Errorf("篡改内容未被发现: %+v", result)
+	}
+
+	// 删除第2条日志
+	database.DB.Where("seq = ?", 2).Delete(&model.AuditLog{})
+	if result, _ = service.AuditLogService.Verify(); result.Valid || result.BrokenSeq != 3 {
+		t.Errorf("删除日志未被发现: %+v", result)
+	}
+}
+
+func TestConfigImportSnapshot(t *testing.T) {
+	initTestDatabase(t)
+
+	app := fiber.New()
+	app.Post("/config/import", ConfigController.Import)
+	doc := `
+services:
+  - name: web
+    domain: example.com
+    port: 8443
+    routes:
+      - uri: /api
+`
+	for _, dryRun := range []string{"true", "false"} {
+		req := httptest.NewRequest(fiber.MethodPost, "/config/import?format=yaml&dryRun="+dryRun, strings.NewReader(doc))
+		if _, err := app.Test(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, total, err := service.ConfigSnapshotService.List(1, 10)
+	if err != nil || total != 1 {
+		t.Fatalf("导入后应保存一个配置版本: %d, %v", total, err)
+	}
+	if snapshot, _ := service.ConfigSnapshotService.GetByVersion(1); snapshot == nil || !strings.Contains(snapshot.Content, "example.com") {
+		t.Errorf("配置版本内容错误: %+v", snapshot)
+	}
+}
+
+func TestOpenApiApplySnapshot(t *testing.T) {
+	initTestDatabase(t)
+	name, domain, port := "web", "example.com", uint16(8443)
+	database.DB.Create(&model.Service{ID: 1, Name: &name, Domain: &domain, Port: &port})
+
+	app := fiber.New()
+	app.Post("/openapi/apply", OpenApiController.Apply)
+	req := httptest.NewRequest(fiber.MethodPost, "/openapi/apply", strings.NewReader(`{"serviceId":"1","routes":[{"uri":"/api/users","methods":"GET"}]}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if _, err := app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := service.ConfigSnapshotService.GetByVersion(1)
+	if err != nil || snapshot == nil || !strings.Contains(snapshot.Content, "/api/users") {
+		t.Errorf("应用后应保存配置版本: %+v, %v", snapshot, err)
+	}
+}
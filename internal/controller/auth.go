@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/jwt"
+	"security-gateway/pkg/util"
+	"strconv"
+	"time"
+)
+
+// sessionAlgorithm 管理员登录token的签名算法
+const sessionAlgorithm = "HS256"
+
+// localsAdmin 当前登录的管理员在fiber.Ctx.Locals中的key
+const localsAdmin = "admin"
+
+var (
+	// authEnabled 是否启用管理接口认证(admin.auth)，默认启用
+	authEnabled    = true
+	sessionSecret  []byte
+	sessionTTL     = 2 * time.Hour
+	sessionChecker *jwt.Verifier
+)
+
+var errSessionInvalid = errors.New("登录已失效")
+
+// InitAuth 读取管理接口认证配置，没有管理员时创建三员分立的初始账号
+func InitAuth() (err error) {
+	if config.IsSet("admin.auth") && !config.GetBool("admin.auth") {
+		authEnabled = false
+		logger.Warn("管理接口未启用认证(admin.auth)，仅应在受信任的网络中使用")
+		return
+	}
+	if ttl := config.GetInt("admin.sessionTTL", 7200); ttl > 0 {
+		sessionTTL = time.Duration(ttl) * time.Second
+	}
+	if secret := config.GetString("admin.jwtSecret"); secret != "" {
+		sessionSecret = []byte(secret)
+	} else {
+		sessionSecret = make([]byte, 32)
+		if _, err = rand.Read(sessionSecret); err != nil {
+			return
+		}
+		logger.Warn("未配置admin.jwtSecret，使用随机密钥，重启后需重新登录，多节点部署时需配置相同的密钥")
+	}
+	if sessionChecker, err = jwt.NewVerifier(sessionAlgorithm, string(sessionSecret), ""); err != nil {
+		return
+	}
+	return initAdmins()
+}
+
+// initAdmins 没有管理员时创建系统管理员、安全管理员及安全审计员，随机密码只在日志中输出一次
+func initAdmins() error {
+	count, err := service.AdminService.Count()
+	if err != nil || count > 0 {
+		return err
+	}
+	initial := []*model.Admin{
+		{Username: "sysadmin", Name: "系统管理员", Role: model.AdminRoleSystem},
+		{Username: "secadmin", Name: "安全管理员", Role: model.AdminRoleSecurity},
+		{Username: "auditor", Name: "安全审计员", Role: model.AdminRoleAudit},
+	}
+	for _, admin := range initial {
+		password, err := util.RandomPassword(16)
+		if err != nil {
+			return err
+		}
+		if admin.Password, err = hashPassword(password); err != nil {
+			return err
+		}
+		if _, _, err = service.AdminService.Add(admin); err != nil {
+			return err
+		}
+		logger.Warnf("已创建初始管理员 %s(%s)，密码: %s ，请登录后立即修改", admin.Username, admin.Name, password)
+	}
+	return nil
+}
+
+// hashPassword 按admin.passwordHash(sm3或bcrypt，默认sm3)计算密码摘要
+func hashPassword(password string) (string, error) {
+	return util.HashPassword(password, config.GetString("admin.passwordHash", util.PasswordHashSm3))
+}
+
+// checkPasswordStrength 校验密码强度，长度及包含的字符种类(数字、小写、大写、特殊字符)由admin.passwordMinLength、admin.passwordLevel配置
+func checkPasswordStrength(password string) bool {
+	return util.PasswordStrengthCheck(config.GetInt("admin.passwordMinLength", 8), 64, config.GetInt("admin.passwordLevel", 3), password)
+}
+
+// newSession 签发登录token，密码修改后之前签发的token失效
+func newSession(admin *model.Admin) (token string, expireTime int64, err error) {
+	now := time.Now()
+	expireAt := now.Add(sessionTTL)
+	claims, err := json.Marshal(map[string]interface{}{
+		"sub":  strconv.FormatUint(admin.ID, 10),
+		"name": admin.Username,
+		"role": admin.Role,
+		"ver":  admin.PasswordTime,
+		"iat":  now.Unix(),
+		"exp":  expireAt.Unix(),
+	})
+	if err != nil {
+		return
+	}
+	token, err = jwt.SignHMAC(sessionAlgorithm, sessionSecret, claims)
+	return token, expireAt.UnixMilli(), err
+}
+
+// parseSession 校验登录token，管理员被删除、禁用或修改密码后失效，角色以数据库为准
+func parseSession(token string) (*model.Admin, error) {
+	if token == "" {
+		return nil, errSessionInvalid
+	}
+	claims, err := sessionChecker.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	id := gjson.GetBytes(claims, "sub").Uint()
+	if id == 0 {
+		return nil, errSessionInvalid
+	}
+	admin, err := service.AdminService.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if admin.Disabled || admin.PasswordTime != gjson.GetBytes(claims, "ver").Int() {
+		return nil, errSessionInvalid
+	}
+	return admin, nil
+}
+
+// authenticate 校验管理接口的登录token，未启用认证时跳过
+func authenticate(ctx *fiber.Ctx) error {
+	if !authEnabled {
+		return ctx.Next()
+	}
+	admin, err := parseSession(ctx.Get(fiber.HeaderAuthorization))
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&CommonResponse{
+			Code: ResponseCodeUnauthorized,
+			Msg:  ResponseMsgUnauthorized,
+		})
+	}
+	ctx.Locals(localsAdmin, admin)
+	return ctx.Next()
+}
+
+// authorize 只允许指定角色访问，writeRole可访问所有方法，readRoles只能访问GET请求
+func authorize(writeRole string, readRoles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if !authEnabled {
+			return ctx.Next()
+		}
+		admin := currentAdmin(ctx)
+		if admin != nil {
+			if admin.Role == writeRole {
+				return ctx.Next()
+			}
+			if ctx.Method() == fiber.MethodGet {
+				for _, role := range readRoles {
+					if admin.Role == role {
+						return ctx.Next()
+					}
+				}
+			}
+		}
+		return ctx.Status(fiber.StatusForbidden).JSON(&CommonResponse{
+			Code: ResponseCodeForbidden,
+			Msg:  ResponseMsgForbidden,
+		})
+	}
+}
+
+// authorizeAny 允许多个角色访问所有方法，由接口按角色进一步校验
+func authorizeAny(roles ...string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if !authEnabled {
+			return ctx.Next()
+		}
+		if admin := currentAdmin(ctx); admin != nil {
+			for _, role := range roles {
+				if admin.Role == role {
+					return ctx.Next()
+				}
+			}
+		}
+		return ctx.Status(fiber.StatusForbidden).JSON(&CommonResponse{
+			Code: ResponseCodeForbidden,
+			Msg:  ResponseMsgForbidden,
+		})
+	}
+}
+
+// currentAdmin 当前登录的管理员，未启用认证时为nil
+func currentAdmin(ctx *fiber.Ctx) *model.Admin {
+	admin, _ := ctx.Locals(localsAdmin).(*model.Admin)
+	return admin
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/gofiber/fiber/v2"
+	"net/http/httptest"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		if role := ctx.Get("X-Role"); role != "" {
+			ctx.Locals(localsAdmin, &model.Admin{Role: role})
+		}
+		return ctx.Next()
+	})
+	group := app.Group("/upstream", authorize(model.AdminRoleSystem, model.AdminRoleSecurity))
+	ok := func(ctx *fiber.Ctx) error { return ctx.SendString("ok") }
+	group.Get("/list", ok)
+	group.Post("/add", ok)
+
+	cases := []struct {
+		method, path, role string
+		status             int
+	}{
+		{fiber.MethodGet, "/upstream/list", model.AdminRoleSystem, fiber.StatusOK},
+		{fiber.MethodPost, "/upstream/add", model.AdminRoleSystem, fiber.StatusOK},
+		{fiber.MethodGet, "/upstream/list", model.AdminRoleSecurity, fiber.StatusOK},
+		{fiber.MethodPost, "/upstream/add", model.AdminRoleSecurity, fiber.StatusForbidden},
+		{fiber.MethodGet, "/upstream/list", model.AdminRoleAudit, fiber.StatusForbidden},
+		{fiber.MethodGet, "/upstream/list", "", fiber.StatusForbidden},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("X-Role", c.role)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != c.status {
+			t.Errorf("%s %s %s: 状态码应为%d, 实际: %d", c.role, c.method, c.path, c.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestConfigImportRole(t *testing.T) {
+	initTestDatabase(t)
+
+	app := fiber.New()
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.Locals(localsAdmin, &model.Admin{Role: ctx.Get("X-Role")})
+		return ctx.Next()
+	})
+	app.Post("/config/import", ConfigController.Import)
+	importAs := func(role, doc string) int {
+		req := httptest.NewRequest(fiber.MethodPost, "/config/import?format=yaml", strings.NewReader(doc))
+		req.Header.Set("X-Role", role)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	doc := `
+services:
+  - name: web
+    domain: example.com
+    port: 8443
+`
+	if status := importAs(model.AdminRoleSecurity, doc); status != fiber.StatusForbidden {
+		t.Errorf("安全管理员不应导入服务: %d", status)
+	}
+	if status := importAs(model.AdminRoleSystem, doc); status != fiber.StatusOK {
+		t.Fatalf("系统管理员导入服务失败: %d", status)
+	}
+
+	// 服务无变化，只修改脱敏字段
+	doc += `    fields:
+      - fieldName: phone
+        level1: mobile
+`
+	if status := importAs(model.AdminRoleSystem, doc); status != fiber.StatusForbidden {
+		t.Errorf("系统管理员不应导入脱敏字段: %d", status)
+	}
+	if status := importAs(model.AdminRoleSecurity, doc); status != fiber.StatusOK {
+		t.Errorf("安全管理员导入脱敏字段失败: %d", status)
+	}
+	if fields, _ := service.ServiceFieldService.ListAll(); len(fields) != 1 {
+		t.Errorf("脱敏字段导入结果错误: %d", len(fields))
+	}
+}
+
+func TestLogin(t *testing.T) {
+	initTestDatabase(t)
+	if err := InitAuth(); err != nil {
+		t.Fatal(err)
+	}
+	// 初始的三员账号
+	if count, _ := service.AdminService.Count(); count != 3 {
+		t.Fatalf("初始管理员数量错误: %d", count)
+	}
+	hashed, _ := hashPassword("Audit@2024")
+	_, _ = service.AdminService.UpdatePassword(mustAdmin(t, "auditor").ID, hashed)
+
+	app := fiber.New()
+	api := app.Group("/api/v1")
+	api.Post("/auth/login", AuthController.Login)
+	api.Use(authenticate)
+	api.Get("/auth/profile", AuthController.Profile)
+	api.Get("/log/count", authorize(model.AdminRoleAudit), func(ctx *fiber.Ctx) error { return ctx.SendString("ok") })
+	api.Get("/user/list", authorize(model.AdminRoleSecurity), func(ctx *fiber.Ctx) error { return ctx.SendString("ok") })
+
+	call := func(method, path, token, body string) (int, *CommonResponse) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := new(CommonResponse)
+		_ = json.NewDecoder(resp.Body).Decode(r)
+		return resp.StatusCode, r
+	}
+
+	if _, r := call("POST", "/api/v1/auth/login", "", `{"username":"auditor","password":"wrong"}`); r.Code != ResponseCodeUnauthorized {
+		t.Errorf("错误的密码应登录失败: %+v", r)
+	}
+	_, r := call("POST", "/api/v1/auth/login", "", `{"username":"auditor","password":"Audit@2024"}`)
+	if r.Code != 0 {
+		t.Fatalf("登录失败: %+v", r)
+	}
+	token := r.Data.(map[string]interface{})["token"].(string)
+
+	if status, _ := call("GET", "/api/v1/log/count", "", ""); status != fiber.StatusUnauthorized {
+		t.Errorf("未登录应返回401: %d", status)
+	}
+	if status, _ := call("GET", "/api/v1/log/count", token, ""); status != fiber.StatusOK {
+		t.Errorf("审计员应可查看日志: %d", status)
+	}
+	if status, _ := call("GET", "/api/v1/user/list", token, ""); status != fiber.StatusForbidden {
+		t.Errorf("审计员不应查看用户: %d", status)
+	}
+
+	// 修改密码后之前的登录失效
+	hashed, _ = hashPassword("Audit@2025")
+	_, _ = service.AdminService.UpdatePassword(mustAdmin(t, "auditor").ID, hashed)
+	if status, _ := call("GET", "/api/v1/auth/profile", token, ""); status != fiber.StatusUnauthorized {
+		t.Errorf("修改密码后登录应失效: %d", status)
+	}
+}
+
+func mustAdmin(t *testing.T, username string) *model.Admin {
+	t.Helper()
+	admin, err := service.AdminService.GetByUsername(username)
+	if err != nil || admin == nil {
+		t.Fatalf("管理员%s不存在: %v", username, err)
+	}
+	return admin
+}
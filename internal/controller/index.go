@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
@@ -25,136 +26,49 @@ func init() {
 			logger.Errorln(e)
 		},
 	}))
-	ServerApp.Use(cors.New())
+	// 管理页面与接口同源，只有配置了server.allowOrigins(逗号分隔)时才允许跨域访问
+	if origins := config.GetString("server.allowOrigins"); origins != "" {
+		ServerApp.Use(cors.New(cors.Config{
+			AllowOrigins: origins,
+			AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		}))
+	}
 }
 
+// InitProxyManager 从数据库加载反向代理配置，启动后台同步并订阅其他节点的配置变化
 func InitProxyManager() {
-	var services = make(map[uint64]*model.Service)
-	page := 1
-	routeTargetList, total, err := service.RouteTargetService.List(page, 100, nil)
-	if err != nil {
-		logger.Errorln("初始化反向代理失败: ", err)
-		return
-	}
-	for int(total) > len(routeTargetList) {
-		var list []*model.RouteTarget
-		page++
-		list, total, err = service.RouteTargetService.List(page, 100, nil)
-		if err != nil {
-			logger.Errorln("初始化反向代理失败: ", err)
-			return
-		}
-		routeTargetList = append(routeTargetList, list...)
-	}
-
-	// 遍历所有的路由目标，添加到反向代理管理器中
-	var routes = make(map[uint64]*model.Route)
-	var upstreams = make(map[uint64]*model.Upstream)
-
-	for _, routeTarget := range routeTargetList {
-		route, ok := routes[*routeTarget.RouteID]
-		if !ok {
-			route, err = service.RouteService.Get(*routeTarget.RouteID)
-			if err != nil {
-				logger.Errorln("初始化反向代理失败: ", err)
-				return
-			}
-			routes[*routeTarget.RouteID] = route
-		}
-		serv, ok := services[*route.ServiceID]
-		if !ok {
-			serv, err = service.ServiceService.Get(*route.ServiceID)
-			if err != nil {
-				logger.Errorln("初始化反向代理失败: ", err)
-				return
-			}
-			services[*route.ServiceID] = serv
-		}
-		upstream, ok := upstreams[*routeTarget.UpstreamID]
-		if !ok {
-			upstream, err = service.UpstreamService.Get(*routeTarget.UpstreamID)
-			if err != nil {
-				logger.Errorln("初始化反向代理失败: ", err)
-				return
-			}
-			upstreams[*routeTarget.UpstreamID] = upstream
-		}
-		proxy.Manager.AddRoute(serv, route, upstream, routeTarget.Weight)
+	// 数据库中的配置与最新版本不同时(如首次启动或直接修改了数据库)保存为新的版本
+	if _, _, err := service.ConfigSnapshotService.Snapshot("system", "", "启动时的配置"); err != nil {
+		logger.Errorln("保存配置版本失败: ", err)
 	}
-
-	// 加载所有获取用户信息的路由到反向代理管理器中
-	page = 1
-	userInfoRouteList, total, err := service.UserInfoRouteService.List(page, 100, "")
-	if err != nil {
+	if _, err := proxy.Manager.Reconcile(); err != nil {
 		logger.Errorln("初始化反向代理失败: ", err)
-		return
-	}
-	for int(total) > len(userInfoRouteList) {
-		var list []*model.UserInfoRoute
-		page++
-		list, total, err = service.UserInfoRouteService.List(page, 100, "")
-		if err != nil {
-			logger.Errorln("初始化反向代理失败: ", err)
-			return
-		}
-		userInfoRouteList = append(userInfoRouteList, list...)
-	}
-	for _, userInfoRoute := range userInfoRouteList {
-		serv, ok := services[userInfoRoute.ServiceID]
-		if !ok {
-			serv, err = service.ServiceService.Get(userInfoRoute.ServiceID)
-			if err != nil {
-				logger.Errorln("初始化反向代理失败: ", err)
-				return
-			}
-			services[userInfoRoute.ServiceID] = serv
-		}
-		if serv != nil {
-			proxy.Manager.AddUserRoute(serv, userInfoRoute)
-		}
-	}
-
-	// 所有脱敏字段添加到反向代理管理器中
-	page = 1
-	secretFieldList, total, err := service.ServiceFieldService.List(page, 100, &model.ServiceField{})
-	if err != nil {
-		logger.Errorln("初始化反向代理失败: ", err)
-		return
-	}
-	for int(total) > len(secretFieldList) {
-		var list []*model.ServiceField
-		page++
-		list, total, err = service.ServiceFieldService.List(page, 100, &model.ServiceField{})
-		if err != nil {
-			logger.Errorln("初始化反向代理失败: ", err)
-			return
-		}
-		secretFieldList = append(secretFieldList, list...)
-	}
-	for _, secretField := range secretFieldList {
-		serv, ok := services[secretField.ServiceID]
-		if !ok {
-			serv, err = service.ServiceService.Get(secretField.ServiceID)
-			if err != nil {
-				logger.Errorln("初始化反向代理失败: ", err)
-				return
-			}
-			services[secretField.ServiceID] = serv
-		}
-		proxy.Manager.UpdateServiceField(serv, secretField)
-	}
-
-	// 加载服务的证书
-	for _, serv := range services {
-		proxy.Manager.UpdateServiceCertificate(serv.ID)
 	}
+	proxy.Manager.StartReconciler()
+	proxy.Manager.StartClusterSync(context.Background())
 }
 
 func InitRouter() {
 	apiV1 := ServerApp.Group("/api/v1")
 
+	// Auth，登录接口无需认证，需在authenticate之前注册
+	auth := apiV1.Group("/auth")
+	auth.Post("/login", AuthController.Login)
+	apiV1.Use(authenticate)
+	auth.Get("/profile", AuthController.Profile)
+	auth.Post("/password", AuthController.Password)
+
+	// Admin，系统管理员管理管理员账号，安全审计员可查看
+	admin := apiV1.Group("/admin", authorize(model.AdminRoleSystem, model.AdminRoleAudit))
+	admin.Post("/add", AdminController.Add)
+	admin.Post("/update", AdminController.Update)
+	admin.Post("/resetPassword", AdminController.ResetPassword)
+	admin.Post("/delete/:id", AdminController.Delete)
+	admin.Get("/list", AdminController.List)
+
+	// 三员分立：系统管理员管理网关配置，安全管理员管理脱敏字段、密级及用户，安全审计员只读查看日志
 	// Upstream
-	upstream := apiV1.Group("/upstream")
+	upstream := apiV1.Group("/upstream", authorize(model.AdminRoleSystem, model.AdminRoleSecurity))
 	upstream.Post("/add", UpstreamController.Add)
 	upstream.Post("/update", UpstreamController.Update)
 	upstream.Post("/delete/:id", UpstreamController.Delete)
@@ -163,7 +77,7 @@ func InitRouter() {
 	upstream.Get("/listByRoute", UpstreamController.ListByRoute)
 
 	// Service
-	serv := apiV1.Group("/service")
+	serv := apiV1.Group("/service", authorize(model.AdminRoleSystem, model.AdminRoleSecurity))
 	serv.Post("/add", ServiceController.Add)
 	serv.Post("/update", ServiceController.Update)
 	serv.Post("/updateCert", ServiceController.UpdateCert)
@@ -173,7 +87,7 @@ func InitRouter() {
 	serv.Get("/ports", ServiceController.Ports)
 
 	// Route
-	route := apiV1.Group("/route")
+	route := apiV1.Group("/route", authorize(model.AdminRoleSystem, model.AdminRoleSecurity))
 	route.Post("/add", RouteController.Add)
 	route.Post("/update", RouteController.Update)
 	route.Post("/delete/:id", RouteController.Delete)
@@ -181,8 +95,13 @@ func InitRouter() {
 	route.Get("/list", RouteController.List)
 	route.Get("/listWithTargets", RouteController.ListWithTargets)
 
+	// OpenAPI文档导入，系统管理员新建路由，安全管理员审核脱敏字段
+	openApi := apiV1.Group("/openapi", authorizeAny(model.AdminRoleSystem, model.AdminRoleSecurity))
+	openApi.Post("/plan", OpenApiController.Plan)
+	openApi.Post("/apply", OpenApiController.Apply)
+
 	// RouteTarget
-	routeTarget := apiV1.Group("/routeTarget")
+	routeTarget := apiV1.Group("/routeTarget", authorize(model.AdminRoleSystem, model.AdminRoleSecurity))
 	routeTarget.Post("/save", RouteTargetController.Save)
 	routeTarget.Post("/add", RouteTargetController.Add)
 	//routeTarget.Post("/update", RouteTargetController.Update)
@@ -192,7 +111,7 @@ func InitRouter() {
 	routeTarget.Get("/list", RouteTargetController.List)
 
 	// User
-	user := apiV1.Group("/user")
+	user := apiV1.Group("/user", authorize(model.AdminRoleSecurity))
 	user.Post("/add", UserController.Add)
 	user.Post("/update", UserController.Update)
 	user.Post("/delete/:id", UserController.Delete)
@@ -200,7 +119,7 @@ func InitRouter() {
 	user.Get("/list", UserController.List)
 
 	// UserInfoRoute
-	userInfoRoute := apiV1.Group("/userInfoRoute")
+	userInfoRoute := apiV1.Group("/userInfoRoute", authorize(model.AdminRoleSecurity, model.AdminRoleSystem))
 	userInfoRoute.Post("/add", UserInfoRouteController.Add)
 	userInfoRoute.Post("/update", UserInfoRouteController.Update)
 	userInfoRoute.Post("/delete/:id", UserInfoRouteController.Delete)
@@ -209,7 +128,7 @@ func InitRouter() {
 	userInfoRoute.Get("/list", UserInfoRouteController.List)
 
 	// UserServiceLevel
-	userServiceLevel := apiV1.Group("/userServiceLevel")
+	userServiceLevel := apiV1.Group("/userServiceLevel", authorize(model.AdminRoleSecurity))
 	userServiceLevel.Post("/add", UserServiceLevelController.Add)
 	userServiceLevel.Post("/update", UserServiceLevelController.Update)
 	userServiceLevel.Post("/delete/:id", UserServiceLevelController.Delete)
@@ -218,7 +137,7 @@ func InitRouter() {
 	userServiceLevel.Get("/ListWithService", UserServiceLevelController.ListWithService)
 
 	// ServiceField
-	secretField := apiV1.Group("/serviceField")
+	secretField := apiV1.Group("/serviceField", authorize(model.AdminRoleSecurity, model.AdminRoleSystem))
 	secretField.Post("/add", ServiceFieldController.Add)
 	secretField.Post("/update", ServiceFieldController.Update)
 	secretField.Post("/delete/:id", ServiceFieldController.Delete)
@@ -226,7 +145,7 @@ func InitRouter() {
 	secretField.Get("/list", ServiceFieldController.List)
 
 	// RouteField
-	routeField := apiV1.Group("/routeField")
+	routeField := apiV1.Group("/routeField", authorize(model.AdminRoleSecurity, model.AdminRoleSystem))
 	routeField.Post("/add", RouteFieldController.Add)
 	routeField.Post("/update", RouteFieldController.Update)
 	routeField.Post("/delete/:id", RouteFieldController.Delete)
@@ -234,7 +153,7 @@ func InitRouter() {
 	routeField.Get("/list", RouteFieldController.List)
 
 	// Certificate
-	cert := apiV1.Group("/certificate")
+	cert := apiV1.Group("/certificate", authorize(model.AdminRoleSystem))
 	cert.Post("/add", CertificateController.Add)
 	cert.Post("/update", CertificateController.Update)
 	cert.Post("/delete/:id", CertificateController.Delete)
@@ -243,13 +162,47 @@ func InitRouter() {
 	cert.Get("/listByDomain", CertificateController.ListByDomain)
 
 	// ServiceCertificate
-	serviceCert := apiV1.Group("/serviceCertificate")
+	serviceCert := apiV1.Group("/serviceCertificate", authorize(model.AdminRoleSystem))
 	serviceCert.Post("/add", CertificateController.AddServiceCertificate)
 	serviceCert.Post("/delete/:id", CertificateController.DeleteServiceCertificate)
 
+	// Config，配置版本，三员均可查看；系统管理员及安全管理员可回滚及导入，由接口按修改的配置校验角色
+	configRead := authorize(model.AdminRoleSystem, model.AdminRoleSecurity, model.AdminRoleAudit)
+	configWrite := authorizeAny(model.AdminRoleSystem, model.AdminRoleSecurity)
+	configGroup := apiV1.Group("/config")
+	configGroup.Get("/versions", configRead, ConfigController.Versions)
+	configGroup.Get("/version/:version", configRead, ConfigController.Version)
+	configGroup.Get("/diff", configRead, ConfigController.Diff)
+	configGroup.Post("/rollback", configWrite, ConfigController.Rollback)
+	configGroup.Get("/export", configRead, ConfigController.Export)
+	configGroup.Post("/import", configWrite, ConfigController.Import)
+
+	// Proxy
+	proxyGroup := apiV1.Group("/proxy", authorize(model.AdminRoleSystem))
+	proxyGroup.Post("/resync", ProxyController.Resync)
+	proxyGroup.Get("/status", ProxyController.Status)
+	proxyGroup.Get("/resolve", ProxyController.Resolve)
+	proxyGroup.Post("/tokenCleanup", ProxyController.CleanupTokens)
+	proxyGroup.Get("/tokenCleanup", ProxyController.TokenCleanupStatus)
+
+	// Token
+	token := apiV1.Group("/token", authorize(model.AdminRoleSecurity))
+	token.Get("/list", TokenController.List)
+	token.Post("/revoke", TokenController.Revoke)
+
+	// Cluster
+	cluster := apiV1.Group("/cluster", authorize(model.AdminRoleSystem))
+	cluster.Get("/nodes", ClusterController.Nodes)
+
 	// Log
-	log := apiV1.Group("/log")
+	log := apiV1.Group("/log", authorize(model.AdminRoleAudit))
 	log.Get("/count", LogController.CountProxyTraceLog)
+
+	// Audit，配置修改审计日志，只有安全审计员可查看
+	audit := apiV1.Group("/audit", authorize(model.AdminRoleAudit))
+	audit.Get("/list", AuditController.List)
+	audit.Get("/verify", AuditController.Verify)
+	audit.Get("/export", AuditController.Export)
 }
 
 const (
@@ -260,6 +213,8 @@ const (
 	ResponseCodeDataNotExists
 
 	ResponseCodeUnknownError
+	ResponseCodeUnauthorized
+	ResponseCodeForbidden
 )
 
 var (
@@ -270,6 +225,9 @@ var (
 	ResponseMsgDataNotExists   = "Data not exists"
 
 	ResponseMsgUnknownError = "Unknown error"
+	ResponseMsgUnauthorized = "Unauthorized"
+	ResponseMsgForbidden    = "Forbidden"
+	ResponseMsgPasswordWeak = "密码强度不足"
 )
 
 type CommonResponse struct {
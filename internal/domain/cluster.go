@@ -0,0 +1,10 @@
+package domain
+
+import "security-gateway/internal/model"
+
+type NodeStatus struct {
+	*model.Node
+	Alive   bool `json:"alive"`   // 心跳未过期
+	Synced  bool `json:"synced"`  // 已应用最新的配置版本
+	Current bool `json:"current"` // 处理本次请求的节点
+}
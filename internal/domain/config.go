@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"encoding/json"
+	"security-gateway/internal/model"
+	"sort"
+)
+
+// GatewayConfig 网关的全部配置，证书只保存服务与证书的关联，证书及私钥不包含在内
+type GatewayConfig struct {
+	Services            []*model.Service            `json:"services"`
+	Routes              []*model.Route              `json:"routes"`
+	RouteTargets        []*model.RouteTarget        `json:"routeTargets"`
+	Upstreams           []*model.Upstream           `json:"upstreams"`
+	ServiceFields       []*model.ServiceField       `json:"serviceFields"`
+	RouteFields         []*model.RouteField         `json:"routeFields"`
+	UserInfoRoutes      []*model.UserInfoRoute      `json:"userInfoRoutes"`
+	ServiceCertificates []*model.ServiceCertificate `json:"serviceCertificates"`
+}
+
+// Sort 各类配置按ID排序，保证相同配置序列化后的内容一致
+func (c *GatewayConfig) Sort() {
+	sort.Slice(c.Services, func(i, j int) bool { return c.Services[i].ID < c.Services[j].ID })
+	sort.Slice(c.Routes, func(i, j int) bool { return c.Routes[i].ID < c.Routes[j].ID })
+	sort.Slice(c.RouteTargets, func(i, j int) bool { return c.RouteTargets[i].ID < c.RouteTargets[j].ID })
+	sort.Slice(c.Upstreams, func(i, j int) bool { return c.Upstreams[i].ID < c.Upstreams[j].ID })
+	sort.Slice(c.ServiceFields, func(i, j int) bool { return c.ServiceFields[i].ID < c.ServiceFields[j].ID })
+	sort.Slice(c.RouteFields, func(i, j int) bool { return c.RouteFields[i].ID < c.RouteFields[j].ID })
+	sort.Slice(c.UserInfoRoutes, func(i, j int) bool { return c.UserInfoRoutes[i].ID < c.UserInfoRoutes[j].ID })
+	sort.Slice(c.ServiceCertificates, func(i, j int) bool { return c.ServiceCertificates[i].ID < c.ServiceCertificates[j].ID })
+}
+
+// MapSecrets 返回用户信息路由的JWT密钥及令牌内省客户端密钥按fn转换后的副本，其他配置共用，原配置不变
+func (c *GatewayConfig) MapSecrets(fn func(string) (string, error)) (*GatewayConfig, error) {
+	copied := *c
+	copied.UserInfoRoutes = make([]*model.UserInfoRoute, 0, len(c.UserInfoRoutes))
+	for _, v := range c.UserInfoRoutes {
+		uir := *v
+		var err error
+		if uir.JwtKey, err = fn(v.JwtKey); err != nil {
+			return nil, err
+		}
+		if uir.ClientSecret, err = fn(v.ClientSecret); err != nil {
+			return nil, err
+		}
+		copied.UserInfoRoutes = append(copied.UserInfoRoutes, &uir)
+	}
+	return &copied, nil
+}
+
+// 与model相同的字段，不使用model中按接口参数解析的UnmarshalJSON，完整还原保存的配置
+type (
+	rawService            model.Service
+	rawRoute              model.Route
+	rawRouteTarget        model.RouteTarget
+	rawUpstream           model.Upstream
+	rawServiceField       model.ServiceField
+	rawRouteField         model.RouteField
+	rawUserInfoRoute      model.UserInfoRoute
+	rawServiceCertificate model.ServiceCertificate
+)
+
+type rawGatewayConfig struct {
+	Services            []*rawService            `json:"services"`
+	Routes              []*rawRoute              `json:"routes"`
+	RouteTargets        []*rawRouteTarget        `json:"routeTargets"`
+	Upstreams           []*rawUpstream           `json:"upstreams"`
+	ServiceFields       []*rawServiceField       `json:"serviceFields"`
+	RouteFields         []*rawRouteField         `json:"routeFields"`
+	UserInfoRoutes      []*rawUserInfoRoute      `json:"userInfoRoutes"`
+	ServiceCertificates []*rawServiceCertificate `json:"serviceCertificates"`
+}
+
+// ParseGatewayConfig 解析GatewayConfig序列化的json
+func ParseGatewayConfig(data []byte) (*GatewayConfig, error) {
+	raw := new(rawGatewayConfig)
+	if err := json.Unmarshal(data, raw); err != nil {
+		return nil, err
+	}
+	c := new(GatewayConfig)
+	for _, v := range raw.Services {
+		c.Services = append(c.Services, (*model.Service)(v))
+	}
+	for _, v := range raw.Routes {
+		c.Routes = append(c.Routes, (*model.Route)(v))
+	}
+	for _, v := range raw.RouteTargets {
+		c.RouteTargets = append(c.RouteTargets, (*model.RouteTarget)(v))
+	}
+	for _, v := range raw.Upstreams {
+		c.Upstreams = append(c.Upstreams, (*model.Upstream)(v))
+	}
+	for _, v := range raw.ServiceFields {
+		c.ServiceFields = append(c.ServiceFields, (*model.ServiceField)(v))
+	}
+	for _, v := range raw.RouteFields {
+		c.RouteFields = append(c.RouteFields, (*model.RouteField)(v))
+	}
+	for _, v := range raw.UserInfoRoutes {
+		c.UserInfoRoutes = append(c.UserInfoRoutes, (*model.UserInfoRoute)(v))
+	}
+	for _, v := range raw.ServiceCertificates {
+		c.ServiceCertificates = append(c.ServiceCertificates, (*model.ServiceCertificate)(v))
+	}
+	return c, nil
+}
@@ -0,0 +1,129 @@
+package domain
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// DeclarativeConfig 声明式的网关配置文档，按自然键导入：上游按名称，服务按域名:端口，路由按服务及URI(含匹配条件)。
+// 证书按名称引用，不包含证书及私钥；用户信息路由的JwtKey、ClientSecret不导出，导入时为空表示保留原值
+type DeclarativeConfig struct {
+	Upstreams []*DeclarativeUpstream `json:"upstreams,omitempty" yaml:"upstreams,omitempty"`
+	Services  []*DeclarativeService  `json:"services,omitempty" yaml:"services,omitempty"`
+}
+
+type DeclarativeUpstream struct {
+	Name                  string `json:"name" yaml:"name"`
+	TargetUrl             string `json:"targetUrl" yaml:"targetUrl"`
+	HealthCheckUrl        string `json:"healthCheckUrl,omitempty" yaml:"healthCheckUrl,omitempty"`
+	ConnectTimeout        int    `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty"`
+	ResponseHeaderTimeout int    `json:"responseHeaderTimeout,omitempty" yaml:"responseHeaderTimeout,omitempty"`
+	MaxIdleConnsPerHost   int    `json:"maxIdleConnsPerHost,omitempty" yaml:"maxIdleConnsPerHost,omitempty"`
+	IdleConnTimeout       int    `json:"idleConnTimeout,omitempty" yaml:"idleConnTimeout,omitempty"`
+	TlsInsecureSkipVerify bool   `json:"tlsInsecureSkipVerify,omitempty" yaml:"tlsInsecureSkipVerify,omitempty"`
+	TlsCaCertificate      string `json:"tlsCaCertificate,omitempty" yaml:"tlsCaCertificate,omitempty"` // CA证书名称
+	TlsServerName         string `json:"tlsServerName,omitempty" yaml:"tlsServerName,omitempty"`
+	TlsClientCertificate  string `json:"tlsClientCertificate,omitempty" yaml:"tlsClientCertificate,omitempty"` // 客户端证书名称
+	TlsGmMode             bool   `json:"tlsGmMode,omitempty" yaml:"tlsGmMode,omitempty"`
+}
+
+type DeclarativeService struct {
+	Name          string                    `json:"name" yaml:"name"`
+	Domain        string                    `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Port          uint16                    `json:"port" yaml:"port"`
+	Certificate   string                    `json:"certificate,omitempty" yaml:"certificate,omitempty"` // 证书名称
+	Fields        []*DeclarativeField       `json:"fields,omitempty" yaml:"fields,omitempty"`
+	UserInfoRoute *DeclarativeUserInfoRoute `json:"userInfoRoute,omitempty" yaml:"userInfoRoute,omitempty"` // 每个服务一个
+	Routes        []*DeclarativeRoute       `json:"routes,omitempty" yaml:"routes,omitempty"`
+}
+
+type DeclarativeRoute struct {
+	Uri          string               `json:"uri" yaml:"uri"`
+	Methods      string               `json:"methods,omitempty" yaml:"methods,omitempty"`
+	MatchHeaders string               `json:"matchHeaders,omitempty" yaml:"matchHeaders,omitempty"`
+	MatchQueries string               `json:"matchQueries,omitempty" yaml:"matchQueries,omitempty"`
+	MatchCookies string               `json:"matchCookies,omitempty" yaml:"matchCookies,omitempty"`
+	Priority     int                  `json:"priority,omitempty" yaml:"priority,omitempty"`
+	LoadBalance  int                  `json:"loadBalance,omitempty" yaml:"loadBalance,omitempty"`
+	Timeout      int                  `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	RewriteType  int                  `json:"rewriteType,omitempty" yaml:"rewriteType,omitempty"`
+	RewriteValue string               `json:"rewriteValue,omitempty" yaml:"rewriteValue,omitempty"`
+	Targets      []*DeclarativeTarget `json:"targets,omitempty" yaml:"targets,omitempty"`
+	Fields       []*DeclarativeField  `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// Key 路由在服务下的自然键，URI相同时以匹配条件区分
+func (r *DeclarativeRoute) Key() string {
+	return RouteKey(r.Uri, r.Methods, r.MatchHeaders, r.MatchQueries, r.MatchCookies)
+}
+
+// RouteKey 路由的自然键，没有匹配条件时即为URI
+func RouteKey(uri, methods, headers, queries, cookies string) string {
+	key := uri
+	for _, p := range []string{methods, headers, queries, cookies} {
+		key += "|" + p
+	}
+	return strings.TrimRight(key, "|")
+}
+
+type DeclarativeTarget struct {
+	Upstream string `json:"upstream" yaml:"upstream"` // 上游名称
+	Weight   int    `json:"weight,omitempty" yaml:"weight,omitempty"`
+}
+
+type DeclarativeField struct {
+	FieldName string `json:"fieldName" yaml:"fieldName"`
+	Comment   string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Level1    string `json:"level1,omitempty" yaml:"level1,omitempty"`
+	Level2    string `json:"level2,omitempty" yaml:"level2,omitempty"`
+	Level3    string `json:"level3,omitempty" yaml:"level3,omitempty"`
+	Level4    string `json:"level4,omitempty" yaml:"level4,omitempty"`
+}
+
+type DeclarativeUserInfoRoute struct {
+	Path             string `json:"path" yaml:"path"`
+	Method           string `json:"method,omitempty" yaml:"method,omitempty"`
+	UsernamePath     string `json:"usernamePath,omitempty" yaml:"usernamePath,omitempty"`
+	UniKeyPath       string `json:"uniKeyPath,omitempty" yaml:"uniKeyPath,omitempty"`
+	MatchKey         string `json:"matchKey,omitempty" yaml:"matchKey,omitempty"`
+	TokenPosition    string `json:"tokenPosition,omitempty" yaml:"tokenPosition,omitempty"`
+	TokenType        string `json:"tokenType,omitempty" yaml:"tokenType,omitempty"`
+	JwtAlgorithms    string `json:"jwtAlgorithms,omitempty" yaml:"jwtAlgorithms,omitempty"`
+	JwtKey           string `json:"jwtKey,omitempty" yaml:"jwtKey,omitempty"`
+	JwksUrl          string `json:"jwksUrl,omitempty" yaml:"jwksUrl,omitempty"`
+	IntrospectionUrl string `json:"introspectionUrl,omitempty" yaml:"introspectionUrl,omitempty"`
+	ClientId         string `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	ClientSecret     string `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	LogoutPath       string `json:"logoutPath,omitempty" yaml:"logoutPath,omitempty"`
+	LogoutMethod     string `json:"logoutMethod,omitempty" yaml:"logoutMethod,omitempty"`
+	LogoutCheck      string `json:"logoutCheck,omitempty" yaml:"logoutCheck,omitempty"`
+}
+
+// 配置文档的格式
+const (
+	ConfigFormatYaml = "yaml"
+	ConfigFormatJson = "json"
+)
+
+// ParseDeclarativeConfig 解析配置文档，format为json时按json解析，否则按yaml解析(yaml兼容json)
+func ParseDeclarativeConfig(data []byte, format string) (c *DeclarativeConfig, err error) {
+	c = new(DeclarativeConfig)
+	if format == ConfigFormatJson {
+		err = json.Unmarshal(data, c)
+	} else {
+		err = yaml.Unmarshal(data, c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// MarshalDeclarativeConfig 按格式序列化配置文档，默认yaml
+func MarshalDeclarativeConfig(c *DeclarativeConfig, format string) ([]byte, error) {
+	if format == ConfigFormatJson {
+		return json.MarshalIndent(c, "", "  ")
+	}
+	return yaml.Marshal(c)
+}
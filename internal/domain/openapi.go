@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+// OpenApiDocument OpenAPI 3文档中生成路由及脱敏字段需要的部分，YAML及JSON格式均按YAML解析
+type OpenApiDocument struct {
+	OpenApi    string                      `yaml:"openapi"`
+	Servers    []*OpenApiServer            `yaml:"servers"`
+	Paths      map[string]*OpenApiPathItem `yaml:"paths"`
+	Components *OpenApiComponents          `yaml:"components"`
+}
+
+type OpenApiServer struct {
+	Url string `yaml:"url"`
+}
+
+type OpenApiComponents struct {
+	Schemas    map[string]*OpenApiSchema    `yaml:"schemas"`
+	Parameters map[string]*OpenApiParameter `yaml:"parameters"`
+	Responses  map[string]*OpenApiResponse  `yaml:"responses"`
+}
+
+type OpenApiPathItem struct {
+	Summary    string              `yaml:"summary"`
+	Parameters []*OpenApiParameter `yaml:"parameters"`
+	Get        *OpenApiOperation   `yaml:"get"`
+	Put        *OpenApiOperation   `yaml:"put"`
+	Post       *OpenApiOperation   `yaml:"post"`
+	Delete     *OpenApiOperation   `yaml:"delete"`
+	Options    *OpenApiOperation   `yaml:"options"`
+	Head       *OpenApiOperation   `yaml:"head"`
+	Patch      *OpenApiOperation   `yaml:"patch"`
+	Trace      *OpenApiOperation   `yaml:"trace"`
+}
+
+// Operations 路径下的请求方法及操作，按固定顺序返回
+func (p *OpenApiPathItem) Operations() (methods []string, operations []*OpenApiOperation) {
+	for _, o := range []struct {
+		method    string
+		operation *OpenApiOperation
+	}{
+		{"GET", p.Get}, {"POST", p.Post}, {"PUT", p.Put}, {"PATCH", p.Patch},
+		{"DELETE", p.Delete}, {"HEAD", p.Head}, {"OPTIONS", p.Options}, {"TRACE", p.Trace},
+	} {
+		if o.operation != nil {
+			methods = append(methods, o.method)
+			operations = append(operations, o.operation)
+		}
+	}
+	return
+}
+
+type OpenApiOperation struct {
+	OperationId string                      `yaml:"operationId"`
+	Summary     string                      `yaml:"summary"`
+	Parameters  []*OpenApiParameter         `yaml:"parameters"`
+	Responses   map[string]*OpenApiResponse `yaml:"responses"`
+}
+
+type OpenApiParameter struct {
+	Ref    string         `yaml:"$ref"`
+	Name   string         `yaml:"name"`
+	In     string         `yaml:"in"`
+	Schema *OpenApiSchema `yaml:"schema"`
+}
+
+type OpenApiResponse struct {
+	Ref     string                       `yaml:"$ref"`
+	Content map[string]*OpenApiMediaType `yaml:"content"`
+}
+
+type OpenApiMediaType struct {
+	Schema *OpenApiSchema `yaml:"schema"`
+}
+
+type OpenApiSchema struct {
+	Ref         string                    `yaml:"$ref"`
+	Type        OpenApiType               `yaml:"type"`
+	Format      string                    `yaml:"format"`
+	Title       string                    `yaml:"title"`
+	Description string                    `yaml:"description"`
+	Properties  map[string]*OpenApiSchema `yaml:"properties"`
+	Items       *OpenApiSchema            `yaml:"items"`
+	AllOf       []*OpenApiSchema          `yaml:"allOf"`
+	OneOf       []*OpenApiSchema          `yaml:"oneOf"`
+	AnyOf       []*OpenApiSchema          `yaml:"anyOf"`
+}
+
+// OpenApiType 数据类型，OpenAPI 3.1中可以为数组，如[string, "null"]，取第一个非null的类型
+type OpenApiType string
+
+func (t *OpenApiType) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		*t = OpenApiType(value.Value)
+	case yaml.SequenceNode:
+		for _, item := range value.Content {
+			if item.Value != "null" {
+				*t = OpenApiType(item.Value)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// ParseOpenApiDocument 解析OpenAPI 3文档，支持YAML及JSON格式
+func ParseOpenApiDocument(data []byte) (*OpenApiDocument, error) {
+	doc := new(OpenApiDocument)
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenApi, "3.") {
+		return nil, fmt.Errorf("只支持OpenAPI 3文档，文档版本: %s", doc.OpenApi)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("文档中没有接口路径")
+	}
+	if doc.Components == nil {
+		doc.Components = new(OpenApiComponents)
+	}
+	return doc, nil
+}
+
+// OpenApiImportPlan 按OpenAPI文档生成的路由及建议的脱敏字段，审核后提交应用
+type OpenApiImportPlan struct {
+	ServiceID uint64          `json:"serviceId,string"`
+	Routes    []*OpenApiRoute `json:"routes"`
+	Warnings  []string        `json:"warnings,omitempty"`
+}
+
+// OpenApiRoute 文档中一个路径对应的路由，多个请求方法合并为一个路由
+type OpenApiRoute struct {
+	Uri     string          `json:"uri"`
+	Methods string          `json:"methods"`
+	Source  string          `json:"source,omitempty"` // 文档中的路径
+	Summary string          `json:"summary,omitempty"`
+	RouteID uint64          `json:"routeId,omitempty,string"` // 服务下已存在相同路径及请求方法的路由
+	Errors  []string        `json:"errors,omitempty"`         // 路由校验错误
+	Fields  []*OpenApiField `json:"fields,omitempty"`
+}
+
+// OpenApiField 建议的路由脱敏字段
+type OpenApiField struct {
+	FieldName string `json:"fieldName"`
+	Comment   string `json:"comment"`
+	Reason    string `json:"reason,omitempty"` // 判断为敏感字段的原因
+	Level1    string `json:"level1"`
+	Level2    string `json:"level2"`
+	Level3    string `json:"level3"`
+	Level4    string `json:"level4"`
+	Exists    bool   `json:"exists,omitempty"` // 路由或服务已配置同名的脱敏字段
+}
+
+// OpenApiApplyRequest 审核后应用的路由及脱敏字段，UpstreamID不为空时新建的路由转发到该上游
+type OpenApiApplyRequest struct {
+	ServiceID  uint64          `json:"serviceId,string"`
+	UpstreamID uint64          `json:"upstreamId,omitempty,string"`
+	Routes     []*OpenApiRoute `json:"routes"`
+}
+
+type OpenApiApplyResult struct {
+	CreatedRoutes  int `json:"createdRoutes"`
+	CreatedFields  int `json:"createdFields"`
+	CreatedTargets int `json:"createdTargets"`
+}
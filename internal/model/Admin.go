@@ -0,0 +1,60 @@
+package model
+
+import "github.com/tidwall/gjson"
+
+const (
+	// AdminRoleSystem 系统管理员，管理服务、路由、上游、证书等网关配置及管理员账号
+	AdminRoleSystem = "system"
+	// AdminRoleSecurity 安全管理员，管理脱敏字段、密级、用户及token
+	AdminRoleSecurity = "security"
+	// AdminRoleAudit 安全审计员，只读查看日志及审计记录
+	AdminRoleAudit = "audit"
+)
+
+// AdminRoles 三员分立的管理员角色
+var AdminRoles = []string{AdminRoleSystem, AdminRoleSecurity, AdminRoleAudit}
+
+type Admin struct {
+	ID            uint64 `json:"id,string" gorm:"primaryKey:autoIncrement:false"`
+	Username      string `json:"username" gorm:"size:50;uniqueIndex;comment:用户名"`
+	Name          string `json:"name" gorm:"size:50;comment:姓名"`
+	Password      string `json:"-" gorm:"size:200;comment:密码摘要"`
+	Role          string `json:"role" gorm:"size:20;comment:角色,system系统管理员,security安全管理员,audit安全审计员"`
+	Disabled      bool   `json:"disabled" gorm:"comment:是否禁用"`
+	LoginFailures int    `json:"loginFailures" gorm:"comment:连续登录失败次数"`
+	LockedUntil   int64  `json:"lockedUntil" gorm:"comment:登录锁定截止时间"`
+	LastLoginTime int64  `json:"lastLoginTime" gorm:"comment:最后登录时间"`
+	LastLoginIp   string `json:"lastLoginIp" gorm:"size:50;comment:最后登录IP"`
+	PasswordTime  int64  `json:"passwordTime" gorm:"comment:密码修改时间,修改后之前的登录失效"`
+	CreateTime    int64  `json:"createTime" gorm:"autoCreateTime:milli"`
+}
+
+func (*Admin) TableComment() string {
+	return "管理员表"
+}
+
+func (a *Admin) UnmarshalJSON(b []byte) error {
+	j := gjson.ParseBytes(b)
+	a.ID = j.Get("id").Uint()
+	a.Username = j.Get("username").String()
+	a.Name = j.Get("name").String()
+	a.Role = j.Get("role").String()
+	a.Disabled = j.Get("disabled").Bool()
+	a.CreateTime = j.Get("createTime").Int()
+
+	return nil
+}
+
+// ValidAdminRole 是否是有效的管理员角色
+func ValidAdminRole(role string) bool {
+	for _, r := range AdminRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	Models = append(Models, &Admin{})
+}
@@ -0,0 +1,40 @@
+package model
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// AuditActionRollback 配置回滚到指定版本
+	AuditActionRollback = "rollback"
+	// AuditActionImport 导入声明式配置文档
+	AuditActionImport = "import"
+	// AuditActionRotate 使用新的主密钥重新加密敏感字段
+	AuditActionRotate = "rotate"
+)
+
+// AuditLog 配置修改审计日志，按Seq连续编号，Hash为包含上一条Hash的摘要，组成哈希链防篡改。
+// 配置主密钥时摘要为HMAC-SM3，密钥由KeyID对应的主密钥派生，未配置时为SM3摘要
+type AuditLog struct {
+	ID         uint64 `json:"id,string" gorm:"primaryKey;autoIncrement:false"`
+	Seq        uint64 `json:"seq" gorm:"uniqueIndex;comment:序号，从1开始连续递增"`
+	Actor      string `json:"actor" gorm:"size:50;index;comment:操作人"`
+	ActorRole  string `json:"actorRole" gorm:"size:20;comment:操作人角色"`
+	Ip         string `json:"ip" gorm:"size:50;comment:来源IP"`
+	Action     string `json:"action" gorm:"size:20;comment:操作,create/update/delete"`
+	Resource   string `json:"resource" gorm:"size:50;index;comment:资源类型"`
+	ResourceID uint64 `json:"resourceId,string" gorm:"index;comment:资源ID"`
+	Before     string `json:"before" gorm:"type:text;comment:修改前的数据(json)"`
+	After      string `json:"after" gorm:"type:text;comment:修改后的数据(json)"`
+	Time       int64  `json:"time" gorm:"index;comment:操作时间"`
+	PrevHash   string `json:"prevHash" gorm:"size:64;comment:上一条日志的摘要"`
+	Hash       string `json:"hash" gorm:"size:64;comment:本条日志的摘要"`
+	KeyID      string `json:"keyId" gorm:"size:50;comment:计算摘要的主密钥ID，为空时为SM3摘要"`
+}
+
+func (*AuditLog) TableComment() string {
+	return "配置审计日志表"
+}
+
+func init() {
+	Models = append(Models, &AuditLog{})
+}
@@ -2,18 +2,42 @@ package model
 
 import "github.com/tidwall/gjson"
 
+// 证书类型
+const (
+	// CertificateTypeServer 服务证书，包含证书及私钥
+	CertificateTypeServer = 1
+	// CertificateTypeCA CA证书，仅包含证书，用于校验上游
+	CertificateTypeCA = 2
+)
+
 type Certificate struct {
 	ID          uint64 `json:"id,string" gorm:"primaryKey:autoIncrement:false"`
 	CertName    string `json:"certName" gorm:"size:50;comment:证书名称"`
 	ServeDomain string `json:"serveDomain" gorm:"size:50;comment:服务域名"`
 	CertDesc    string `json:"certDesc" gorm:"size:200;comment:证书描述"`
+	CertType    int    `json:"certType" gorm:"default:1;comment:证书类型,1-服务证书 2-CA证书"`
 	CertPem     string `json:"certPem,omitempty" gorm:"type:text;comment:证书内容"`
-	KeyPem      string `json:"keyPem,omitempty" gorm:"type:text;comment:私钥内容"`
+	KeyPem      string `json:"keyPem,omitempty" gorm:"type:text;serializer:secret;comment:私钥内容"`
 	SignCertPem string `json:"signCertPem,omitempty" gorm:"type:text;comment:签名证书内容(国密signCert)"`
-	SignKeyPem  string `json:"signKeyPem,omitempty" gorm:"type:text;comment:签名私钥内容(国密signKey)"`
+	SignKeyPem  string `json:"signKeyPem,omitempty" gorm:"type:text;serializer:secret;comment:签名私钥内容(国密signKey)"`
 	EncCertPem  string `json:"encCertPem,omitempty" gorm:"type:text;comment:加密证书内容(国密encCert)"`
-	EncKeyPem   string `json:"encKeyPem,omitempty" gorm:"type:text;comment:加密私钥内容(国密encKey)"`
-	CreateTime  int64  `json:"createTime" gorm:"autoCreateTime:milli"`
+	EncKeyPem   string `json:"encKeyPem,omitempty" gorm:"type:text;serializer:secret;comment:加密私钥内容(国密encKey)"`
+	CertificateMeta
+	CreateTime int64 `json:"createTime" gorm:"autoCreateTime:milli"`
+}
+
+// CertificateMeta 保存时解析的证书信息，取第一个证书(国密证书为签名证书)，过期时间取全部证书及证书链中最早的
+type CertificateMeta struct {
+	Subject      string `json:"subject" gorm:"size:500;comment:证书主题"`
+	Issuer       string `json:"issuer" gorm:"size:500;comment:签发者"`
+	DnsNames     string `json:"dnsNames" gorm:"size:1000;comment:证书包含的域名,逗号分隔"`
+	SerialNumber string `json:"serialNumber" gorm:"size:100;comment:序列号"`
+	KeyAlgorithm string `json:"keyAlgorithm" gorm:"size:20;comment:公钥算法,RSA/ECDSA/SM2"`
+	Fingerprint  string `json:"fingerprint" gorm:"size:64;comment:证书DER的SHA-256指纹"`
+	NotBefore    int64  `json:"notBefore" gorm:"comment:生效时间"`
+	NotAfter     int64  `json:"notAfter" gorm:"index;comment:过期时间,多个证书时取最早的"`
+	// 到期提醒按剩余天数的阈值只发送一次通知，证书内容修改后重置
+	ExpiryWarned int `json:"-" gorm:"default:-1;comment:已发送到期提醒的天数阈值,-1表示未发送,0表示已过期"`
 }
 
 func (*Certificate) TableComment() string {
@@ -27,6 +51,7 @@ func (s *Certificate) UnmarshalJSON(b []byte) error {
 	s.CertName = j.Get("certName").String()
 	s.ServeDomain = j.Get("serveDomain").String()
 	s.CertDesc = j.Get("certDesc").String()
+	s.CertType = int(j.Get("certType").Int())
 	s.CertPem = j.Get("certPem").String()
 	s.KeyPem = j.Get("keyPem").String()
 	s.SignCertPem = j.Get("signCertPem").String()
@@ -38,6 +63,13 @@ func (s *Certificate) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// WithoutPrivateKeys 不包含私钥的副本，接口返回证书时使用
+func (s *Certificate) WithoutPrivateKeys() *Certificate {
+	c := *s
+	c.KeyPem, c.SignKeyPem, c.EncKeyPem = "", "", ""
+	return &c
+}
+
 type ServiceCertificate struct {
 	ID         uint64 `json:"id,string" gorm:"primaryKey:autoIncrement:false"`
 	ServiceID  uint64 `json:"serviceId,string" gorm:"comment:服务ID"`
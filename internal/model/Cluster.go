@@ -0,0 +1,33 @@
+package model
+
+// ConfigVersionID 配置版本表只有一条记录
+const ConfigVersionID = 1
+
+// ConfigVersion 配置版本，每次修改反向代理相关配置时递增，各节点据此判断是否需要同步
+type ConfigVersion struct {
+	ID         uint64 `json:"id,string" gorm:"primaryKey;autoIncrement:false"`
+	Version    int64  `json:"version" gorm:"comment:配置版本"`
+	UpdateTime int64  `json:"updateTime" gorm:"comment:更新时间"`
+}
+
+func (*ConfigVersion) TableComment() string {
+	return "配置版本表"
+}
+
+// Node 集群节点，ID即雪花算法的工作机器ID
+type Node struct {
+	ID            uint64 `json:"id,string" gorm:"primaryKey;autoIncrement:false"`
+	Hostname      string `json:"hostname" gorm:"size:100;comment:主机名"`
+	Addr          string `json:"addr" gorm:"size:100;comment:节点地址"`
+	ConfigVersion int64  `json:"configVersion" gorm:"comment:已应用的配置版本"`
+	StartTime     int64  `json:"startTime" gorm:"comment:启动时间"`
+	HeartbeatTime int64  `json:"heartbeatTime" gorm:"comment:心跳时间"`
+}
+
+func (*Node) TableComment() string {
+	return "集群节点表"
+}
+
+func init() {
+	Models = append(Models, &ConfigVersion{}, &Node{})
+}
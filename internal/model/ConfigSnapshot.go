@@ -0,0 +1,21 @@
+package model
+
+// ConfigSnapshot 网关配置快照，每次修改网关配置后保存一个版本，可比较及回滚
+type ConfigSnapshot struct {
+	ID         uint64 `json:"id,string" gorm:"primaryKey;autoIncrement:false"`
+	Version    uint64 `json:"version" gorm:"uniqueIndex;comment:快照版本，从1开始递增"`
+	Actor      string `json:"actor" gorm:"size:50;comment:操作人"`
+	Ip         string `json:"ip" gorm:"size:50;comment:来源IP"`
+	Summary    string `json:"summary" gorm:"size:200;comment:修改说明"`
+	Digest     string `json:"digest" gorm:"size:64;comment:配置内容的SM3摘要"`
+	Content    string `json:"content,omitempty" gorm:"size:16777215;comment:配置内容(json)"`
+	CreateTime int64  `json:"createTime" gorm:"autoCreateTime:milli"`
+}
+
+func (*ConfigSnapshot) TableComment() string {
+	return "配置快照表"
+}
+
+func init() {
+	Models = append(Models, &ConfigSnapshot{})
+}
@@ -15,12 +15,35 @@ const (
 	LoadBalanceIPHash = 3
 )
 
+// 路径重写方式
+const (
+	//RouteRewriteKeep 保留原路径
+	RouteRewriteKeep = 1
+	//RouteRewriteStripPrefix 去除路由前缀
+	RouteRewriteStripPrefix = 2
+	//RouteRewriteReplacePrefix 替换路由前缀
+	RouteRewriteReplacePrefix = 3
+	//RouteRewriteRegex 正则重写，使用路由中{}片段匹配到的值替换模板中的$1、$2...
+	RouteRewriteRegex = 4
+)
+
 type Route struct {
 	ID        uint64  `json:"id,omitempty,string" gorm:"primaryKey;autoIncrement:false"`
 	ServiceID *uint64 `json:"serviceId,omitempty,string" gorm:"index;comment:服务ID"`
 	Uri       *string `json:"uri,omitempty" gorm:"size:200;comment:URI"`
 	// 负载均衡算法类型
 	LoadBalance int `json:"loadBalance" gorm:"default:1;comment:负载均衡算法类型,1=轮询,2=权重,3=IP哈希"`
+	// 请求总超时
+	Timeout int `json:"timeout" gorm:"default:0;comment:请求总超时(毫秒),0表示使用默认配置"`
+	// 匹配条件，同一路径下可以配置多个路由，按优先级和条件数量依次匹配
+	Methods      *string `json:"methods" gorm:"size:100;comment:匹配的请求方法,多个用逗号分隔,为空表示不限制"`
+	MatchHeaders *string `json:"matchHeaders" gorm:"size:500;comment:匹配的请求头,格式name=value,多个用分号分隔,只写name表示存在即可"`
+	MatchQueries *string `json:"matchQueries" gorm:"size:500;comment:匹配的查询参数,格式同请求头"`
+	MatchCookies *string `json:"matchCookies" gorm:"size:500;comment:匹配的Cookie,格式同请求头"`
+	Priority     int     `json:"priority" gorm:"default:0;comment:优先级,同一路径匹配多个路由时优先级高的优先"`
+	// 路径重写，只处理路径，不处理查询参数
+	RewriteType  int     `json:"rewriteType" gorm:"default:1;comment:路径重写方式,1=保留,2=去除前缀,3=替换前缀,4=正则重写"`
+	RewriteValue *string `json:"rewriteValue" gorm:"size:200;comment:替换的前缀或正则重写模板"`
 
 	CreateTime int64          `json:"createTime" gorm:"autoCreateTime:milli"`
 	DeleteTime gorm.DeletedAt `json:"deleteTime,omitempty" gorm:"index"`
@@ -30,6 +53,23 @@ func (*Route) TableComment() string {
 	return "路径表"
 }
 
+// PredicateValues 返回匹配条件，未配置的条件为空字符串
+func (p *Route) PredicateValues() (methods, headers, queries, cookies string) {
+	if p.Methods != nil {
+		methods = *(p.Methods)
+	}
+	if p.MatchHeaders != nil {
+		headers = *(p.MatchHeaders)
+	}
+	if p.MatchQueries != nil {
+		queries = *(p.MatchQueries)
+	}
+	if p.MatchCookies != nil {
+		cookies = *(p.MatchCookies)
+	}
+	return
+}
+
 func (p *Route) UnmarshalJSON(b []byte) error {
 	j := gjson.ParseBytes(b)
 	p.ID = j.Get("id").Uint()
@@ -42,6 +82,29 @@ func (p *Route) UnmarshalJSON(b []byte) error {
 		p.Uri = &uriStr
 	}
 	p.LoadBalance = int(j.Get("loadBalance").Int())
+	p.Timeout = int(j.Get("timeout").Int())
+	if methods := j.Get("methods"); methods.Exists() {
+		methodsStr := methods.String()
+		p.Methods = &methodsStr
+	}
+	if matchHeaders := j.Get("matchHeaders"); matchHeaders.Exists() {
+		matchHeadersStr := matchHeaders.String()
+		p.MatchHeaders = &matchHeadersStr
+	}
+	if matchQueries := j.Get("matchQueries"); matchQueries.Exists() {
+		matchQueriesStr := matchQueries.String()
+		p.MatchQueries = &matchQueriesStr
+	}
+	if matchCookies := j.Get("matchCookies"); matchCookies.Exists() {
+		matchCookiesStr := matchCookies.String()
+		p.MatchCookies = &matchCookiesStr
+	}
+	p.Priority = int(j.Get("priority").Int())
+	p.RewriteType = int(j.Get("rewriteType").Int())
+	if rewriteValue := j.Get("rewriteValue"); rewriteValue.Exists() {
+		rewriteValueStr := rewriteValue.String()
+		p.RewriteValue = &rewriteValueStr
+	}
 	p.CreateTime = j.Get("createTime").Int()
 
 	return nil
@@ -0,0 +1,22 @@
+package model
+
+// Token 用户登录后的token及密级，token存储使用database时使用
+type Token struct {
+	ID          uint64 `json:"id,string" gorm:"primaryKey;autoIncrement:false"`
+	Port        uint16 `json:"port" gorm:"index:idx_token,priority:1;comment:端口"`
+	Domain      string `json:"domain" gorm:"size:255;index:idx_token,priority:2;comment:域名"`
+	TokenHash   string `json:"-" gorm:"size:64;index:idx_token,priority:3;comment:token的SHA256摘要"`
+	Username    string `json:"username" gorm:"size:50;index;comment:用户名"`
+	SecretLevel int    `json:"secretLevel" gorm:"comment:密级"`
+	ExpireTime  int64  `json:"expireTime" gorm:"index;comment:过期时间"`
+	FixedExpire bool   `json:"fixedExpire" gorm:"comment:过期时间固定,访问时不延长"`
+	CreateTime  int64  `json:"createTime" gorm:"autoCreateTime:milli"`
+}
+
+func (*Token) TableComment() string {
+	return "token表"
+}
+
+func init() {
+	Models = append(Models, &Token{})
+}
@@ -10,11 +10,22 @@ type Upstream struct {
 	Name      *string `json:"name" gorm:"size:50;comment:名称"`
 	TargetUrl *string `json:"targetUrl" gorm:"size:200;comment:目标URL"`
 	// 健康监测地址
-	HealthCheckUrl *string        `json:"healthCheckUrl" gorm:"size:200;comment:健康监测地址"`
-	Status         int            `json:"status" gorm:"comment:状态,1-正常 2-健康检测失败"`
-	LastCheckTime  int64          `json:"lastCheckTime" gorm:"default:0;comment:最后一次健康检测时间"`
-	CreateTime     int64          `json:"createTime" gorm:"autoCreateTime:milli"`
-	DeleteTime     gorm.DeletedAt `json:"deleteTime,omitempty" gorm:"index"`
+	HealthCheckUrl *string `json:"healthCheckUrl" gorm:"size:200;comment:健康监测地址"`
+	Status         int     `json:"status" gorm:"comment:状态,1-正常 2-健康检测失败"`
+	LastCheckTime  int64   `json:"lastCheckTime" gorm:"default:0;comment:最后一次健康检测时间"`
+	// 传输层参数，0表示使用默认配置
+	ConnectTimeout        int `json:"connectTimeout" gorm:"default:0;comment:连接超时(毫秒)"`
+	ResponseHeaderTimeout int `json:"responseHeaderTimeout" gorm:"default:0;comment:响应头超时(毫秒)"`
+	MaxIdleConnsPerHost   int `json:"maxIdleConnsPerHost" gorm:"default:0;comment:每个主机最大空闲连接数"`
+	IdleConnTimeout       int `json:"idleConnTimeout" gorm:"default:0;comment:空闲连接超时(毫秒)"`
+	// 上游TLS配置，默认校验上游证书
+	TlsInsecureSkipVerify  *bool          `json:"tlsInsecureSkipVerify" gorm:"default:false;comment:是否跳过上游证书校验"`
+	TlsCaCertificateID     *uint64        `json:"tlsCaCertificateId,omitempty,string" gorm:"comment:校验上游使用的CA证书ID"`
+	TlsServerName          *string        `json:"tlsServerName" gorm:"size:200;comment:SNI覆盖"`
+	TlsClientCertificateID *uint64        `json:"tlsClientCertificateId,omitempty,string" gorm:"comment:客户端证书ID,用于双向TLS"`
+	TlsGmMode              *bool          `json:"tlsGmMode" gorm:"default:false;comment:是否使用国密TLS连接上游"`
+	CreateTime             int64          `json:"createTime" gorm:"autoCreateTime:milli"`
+	DeleteTime             gorm.DeletedAt `json:"deleteTime,omitempty" gorm:"index"`
 }
 
 func (*Upstream) TableComment() string {
@@ -38,6 +49,30 @@ func (u *Upstream) UnmarshalJSON(b []byte) error {
 	}
 	u.Status = int(j.Get("status").Int())
 	u.LastCheckTime = j.Get("lastCheckTime").Int()
+	u.ConnectTimeout = int(j.Get("connectTimeout").Int())
+	u.ResponseHeaderTimeout = int(j.Get("responseHeaderTimeout").Int())
+	u.MaxIdleConnsPerHost = int(j.Get("maxIdleConnsPerHost").Int())
+	u.IdleConnTimeout = int(j.Get("idleConnTimeout").Int())
+	if nj := j.Get("tlsInsecureSkipVerify"); nj.Exists() {
+		skip := nj.Bool()
+		u.TlsInsecureSkipVerify = &skip
+	}
+	if nj := j.Get("tlsCaCertificateId"); nj.Exists() {
+		caCertificateID := nj.Uint()
+		u.TlsCaCertificateID = &caCertificateID
+	}
+	if nj := j.Get("tlsServerName"); nj.Exists() {
+		serverName := nj.String()
+		u.TlsServerName = &serverName
+	}
+	if nj := j.Get("tlsClientCertificateId"); nj.Exists() {
+		clientCertificateID := nj.Uint()
+		u.TlsClientCertificateID = &clientCertificateID
+	}
+	if nj := j.Get("tlsGmMode"); nj.Exists() {
+		gmMode := nj.Bool()
+		u.TlsGmMode = &gmMode
+	}
 
 	u.CreateTime = j.Get("createTime").Int()
 
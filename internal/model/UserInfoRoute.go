@@ -2,6 +2,13 @@ package model
 
 import "github.com/tidwall/gjson"
 
+const (
+	// TokenTypeJwt token为JWT，校验后从声明中获取用户名及唯一标识，UsernamePath、UniKeyPath、MatchKey为声明中的路径
+	TokenTypeJwt = "jwt"
+	// TokenTypeIntrospection 调用OAuth2令牌内省接口(RFC 7662)，UsernamePath、UniKeyPath、MatchKey为内省结果中的路径
+	TokenTypeIntrospection = "introspection"
+)
+
 type UserInfoRoute struct {
 	ID            uint64 `json:"id,string" gorm:"primaryKey:autoIncrement:false"`
 	ServiceID     uint64 `json:"serviceId,string" gorm:"comment:服务ID"`
@@ -10,8 +17,20 @@ type UserInfoRoute struct {
 	UsernamePath  string `json:"usernamePath" gorm:"size:50;comment:用户名路径"`
 	UniKeyPath    string `json:"uniKeyPath" gorm:"size:50;comment:唯一标识路径"`
 	MatchKey      string `json:"matchKey" gorm:"size:50;comment:匹配键,-表示直接匹配User.UniKey,否则匹配User.UniKeysJson中的值"`
-	TokenPosition string `json:"tokenPosition" gorm:"size:100;comment:token位置"` // request:header:Authorization, request:query:token, request:body:auth.token, request:cookies:token, request:cookies:sessionId, response:body:data.token 之类的
-	CreateTime    int64  `json:"createTime" gorm:"autoCreateTime:milli"`
+	TokenPosition string `json:"tokenPosition" gorm:"size:500;comment:token位置"` // request:header:Authorization, request:query:token, request:body:auth.token, request:cookies:token, response:body:data.token, response:header:X-Token, response:cookies:sessionId 之类的，多个位置以逗号分隔
+	TokenType     string `json:"tokenType" gorm:"size:20;comment:token类型,空表示由用户信息接口获取用户,jwt表示解析JWT获取用户"`
+	JwtAlgorithms string `json:"jwtAlgorithms" gorm:"size:100;comment:允许的JWT签名算法,逗号分隔,如HS256,RS256,ES256,SM2"`
+	JwtKey        string `json:"jwtKey" gorm:"type:text;serializer:secret;comment:JWT校验密钥,HMAC密钥或PEM格式的公钥/证书"`
+	JwksUrl       string `json:"jwksUrl" gorm:"size:500;comment:JWKS地址或文件路径,配置后忽略JwtKey"`
+	// 令牌内省接口地址及客户端认证信息
+	IntrospectionUrl string `json:"introspectionUrl" gorm:"size:500;comment:令牌内省接口地址"`
+	ClientId         string `json:"clientId" gorm:"size:100;comment:令牌内省客户端ID"`
+	ClientSecret     string `json:"clientSecret" gorm:"type:text;serializer:secret;comment:令牌内省客户端密钥"`
+	// 退出登录接口，请求成功后删除请求中的token
+	LogoutPath   string `json:"logoutPath" gorm:"size:50;comment:退出登录路径"`
+	LogoutMethod string `json:"logoutMethod" gorm:"size:10;comment:退出登录方法,空表示任意方法"`
+	LogoutCheck  string `json:"logoutCheck" gorm:"size:200;comment:退出登录响应检查,如code=0或success,空表示只检查状态码"`
+	CreateTime   int64  `json:"createTime" gorm:"autoCreateTime:milli"`
 }
 
 func (*UserInfoRoute) TableComment() string {
@@ -27,6 +46,16 @@ func (u *UserInfoRoute) UnmarshalJSON(b []byte) error {
 	u.UniKeyPath = gjson.GetBytes(b, "uniKeyPath").String()
 	u.MatchKey = gjson.GetBytes(b, "matchKey").String()
 	u.TokenPosition = gjson.GetBytes(b, "tokenPosition").String()
+	u.TokenType = gjson.GetBytes(b, "tokenType").String()
+	u.JwtAlgorithms = gjson.GetBytes(b, "jwtAlgorithms").String()
+	u.JwtKey = gjson.GetBytes(b, "jwtKey").String()
+	u.JwksUrl = gjson.GetBytes(b, "jwksUrl").String()
+	u.IntrospectionUrl = gjson.GetBytes(b, "introspectionUrl").String()
+	u.ClientId = gjson.GetBytes(b, "clientId").String()
+	u.ClientSecret = gjson.GetBytes(b, "clientSecret").String()
+	u.LogoutPath = gjson.GetBytes(b, "logoutPath").String()
+	u.LogoutMethod = gjson.GetBytes(b, "logoutMethod").String()
+	u.LogoutCheck = gjson.GetBytes(b, "logoutCheck").String()
 	u.CreateTime = gjson.GetBytes(b, "createTime").Int()
 
 	return nil
@@ -34,6 +34,8 @@ type MaskingResponseWriter struct {
 	cachedBody *bytes.Buffer
 
 	masked bool // 是否已经脱敏
+
+	statusCode int // 响应状态码
 }
 
 func NewMaskingResponseWriterWithFieldMap(w http.ResponseWriter, maskingFields map[string]*server.DesensitizeField, maskLevel int) *MaskingResponseWriter {
@@ -57,6 +59,19 @@ func NewMaskingResponseWriter(w http.ResponseWriter, maskingFields []*server.Des
 	return NewMaskingResponseWriterWithFieldMap(w, fm, maskLevel)
 }
 
+func (m *MaskingResponseWriter) WriteHeader(statusCode int) {
+	m.statusCode = statusCode
+	m.ResponseWriter.WriteHeader(statusCode)
+}
+
+// StatusCode 响应状态码，未调用WriteHeader时为200
+func (m *MaskingResponseWriter) StatusCode() int {
+	if m.statusCode == 0 {
+		return http.StatusOK
+	}
+	return m.statusCode
+}
+
 func (m *MaskingResponseWriter) writeToResponse(b []byte) (int, error) {
 	_, _ = m.cachedBody.Write(b)
 	return m.ResponseWriter.Write(b)
@@ -1,24 +1,96 @@
 package proxy
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	logger "github.com/sirupsen/logrus"
 	"github.com/tjfoc/gmsm/gmtls"
 	"security-gateway/internal/model"
+	"security-gateway/pkg/server"
+	"sync"
+	"sync/atomic"
 )
 
+// certificateManager 服务证书管理，证书变化时复制并替换快照，TLS握手只读取快照
 type certificateManager struct {
+	mu      sync.Mutex
+	current atomic.Pointer[certificateSnapshot]
+}
+
+// certificateSnapshot 证书快照，发布后不再修改
+type certificateSnapshot struct {
 	certificates map[uint16]map[string]*serviceCertificate
+	// 端口下证书对应的域名索引，SNI与服务域名使用相同的匹配规则
+	domains map[uint16]*server.DomainIndex
+}
+
+func (m *certificateManager) load() *certificateSnapshot {
+	if s := m.current.Load(); s != nil {
+		return s
+	}
+	return &certificateSnapshot{}
 }
 
-// serviceCertificate 服务对应的tls证书，包含证书和私钥
+// sync 按期望的服务证书替换快照，内容未变化的证书直接复用，返回变化的证书数量
+func (m *certificateManager) sync(desired map[uint16]map[string]*model.Certificate) (changed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := m.load()
+	s := &certificateSnapshot{
+		certificates: make(map[uint16]map[string]*serviceCertificate, len(desired)),
+		domains:      make(map[uint16]*server.DomainIndex, len(desired)),
+	}
+	for port, certs := range desired {
+		s.certificates[port] = make(map[string]*serviceCertificate, len(certs))
+		domains := make([]string, 0, len(certs))
+		for domain, cert := range certs {
+			digest := certificateDigest(cert)
+			sc, ok := old.certificates[port][domain]
+			if !ok || sc.digest != digest {
+				var err error
+				if sc, err = newServiceCertificate(port, domain, cert); err != nil {
+					logger.WithField("certificate", cert.ID).Error("解析服务证书失败: ", err)
+					continue
+				}
+				changed++
+			}
+			s.certificates[port][domain] = sc
+			domains = append(domains, domain)
+		}
+		s.domains[port] = server.NewDomainIndex(domains)
+	}
+	for port, certs := range old.certificates {
+		for domain := range certs {
+			if _, ok := s.certificates[port][domain]; !ok {
+				changed++
+			}
+		}
+	}
+	m.current.Store(s)
+	return
+}
+
+// serviceCertificate 服务对应的tls证书
 type serviceCertificate struct {
 	port             uint16
 	domain           string
+	digest           string
 	RsaCertificate   *gmtls.Certificate
 	SmSigCertificate *gmtls.Certificate
 	SmEncCertificate *gmtls.Certificate
 }
 
-func (m *certificateManager) UpdateServiceCertificate(port uint16, domain string, cert *model.Certificate) (err error) {
+// certificateDigest 证书内容摘要，用于判断证书是否变化
+func certificateDigest(cert *model.Certificate) string {
+	h := sha256.New()
+	for _, pem := range []string{cert.CertPem, cert.KeyPem, cert.SignCertPem, cert.SignKeyPem, cert.EncCertPem, cert.EncKeyPem} {
+		h.Write([]byte(pem))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newServiceCertificate(port uint16, domain string, cert *model.Certificate) (sc *serviceCertificate, err error) {
 	var rsaCert gmtls.Certificate
 	if cert.CertPem != "" && cert.KeyPem != "" {
 		rsaCert, err = gmtls.X509KeyPair([]byte(cert.CertPem), []byte(cert.KeyPem))
@@ -40,37 +112,27 @@ func (m *certificateManager) UpdateServiceCertificate(port uint16, domain string
 			return
 		}
 	}
-
-	if _, ok := m.certificates[port]; !ok {
-		m.certificates[port] = make(map[string]*serviceCertificate)
-	}
-
-	m.certificates[port][domain] = &serviceCertificate{
+	sc = &serviceCertificate{
 		port:             port,
 		domain:           domain,
+		digest:           certificateDigest(cert),
 		RsaCertificate:   &rsaCert,
 		SmSigCertificate: &smSigCert,
 		SmEncCertificate: &smEncCert,
 	}
-
 	return
 }
 
 func (m *certificateManager) getServiceCertificate(port uint16, domain string) (certificate *serviceCertificate, ok bool) {
-	if _, ok = m.certificates[port]; !ok {
+	s := m.load()
+	if _, ok = s.certificates[port]; !ok {
 		return
 	}
-
-	certificate, ok = m.certificates[port][domain]
-	return
-}
-
-func (m *certificateManager) deleteServiceCertificate(port uint16, domain string) {
-	if _, ok := m.certificates[port]; !ok {
+	if domain, ok = s.domains[port].Match(domain); !ok {
 		return
 	}
-
-	delete(m.certificates[port], domain)
+	certificate, ok = s.certificates[port][domain]
+	return
 }
 
 func (m *certificateManager) generateDynamicTLSConfig(port uint16) (config *gmtls.Config) {
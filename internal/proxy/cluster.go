@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/cache"
+	"strconv"
+	"time"
+)
+
+// channelConfigChanged 配置变化通知频道，消息内容为新的配置版本
+var channelConfigChanged = cache.Prefix + ":config_changed"
+
+// NotifyChanged 本节点修改了配置，递增配置版本并通知集群中的所有节点同步
+func (m *manager) NotifyChanged() {
+	version, err := service.ClusterService.IncreaseConfigVersion()
+	if err != nil {
+		logger.Error("更新配置版本失败: ", err)
+	} else {
+		go func() {
+			if e := cache.Publish(channelConfigChanged, version); e != nil && !errors.Is(e, cache.ErrDisabled) {
+				logger.Warn("发布配置变化通知失败，其他节点将通过轮询同步: ", e)
+			}
+		}()
+	}
+	m.Trigger()
+}
+
+// AppliedVersion 本节点已应用的配置版本
+func (m *manager) AppliedVersion() int64 {
+	return m.appliedVersion.Load()
+}
+
+// CheckVersion 数据库中的配置版本比已应用的版本新时同步，Redis不可用时依靠定时检查
+func (m *manager) CheckVersion() {
+	version, err := service.ClusterService.GetConfigVersion()
+	if err != nil {
+		logger.Error("获取配置版本失败: ", err)
+		return
+	}
+	if version > m.AppliedVersion() {
+		m.Trigger()
+	}
+}
+
+// StartClusterSync 订阅其他节点的配置变化及token缓存清除通知
+func (m *manager) StartClusterSync(ctx context.Context) {
+	go cache.Subscribe(ctx, channelTokenInvalidated, 5*time.Second, handleTokenInvalidated)
+	go cache.Subscribe(ctx, channelConfigChanged, 5*time.Second, func(data []byte) {
+		version, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil || version > m.AppliedVersion() {
+			m.Trigger()
+		}
+	})
+}
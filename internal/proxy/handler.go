@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	logger "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
@@ -11,10 +12,10 @@ import (
 	"net"
 	"net/http"
 	"security-gateway/internal/model"
-	"security-gateway/internal/service"
 	"security-gateway/pkg/server"
 	"security-gateway/pkg/util"
 	"strings"
+	"sync/atomic"
 )
 
 //func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, port uint16, domain string) func(c *fiber.Ctx) error {
@@ -188,7 +189,7 @@ import (
 //				body:           new(bytes.Buffer),
 //			}
 //
-//			proxy.ServeHTTP(mrw, r)
+//			proxy.ServeHTTP(mrw, outReq)
 //
 //			logger.WithField("proxyId", proxyId).Debug("真实目标地址请求成功: ", trueTargetUrl)
 //
@@ -233,6 +234,7 @@ import (
 //		return handler
 //	}
 func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, port uint16, domain string) http.HandlerFunc {
+	rewriter := newPathRewriter(route)
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		if len(routeProxy.TargetUpstreams) == 0 {
 			// 返回404
@@ -242,16 +244,13 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 		customIp := util.GetUserIP(r)
 		// 反向代理
 		loadBalanceType := route.LoadBalance
-		var realTargetUrl string
+		var target *TargetUpstream
 		switch loadBalanceType {
 		case model.LoadBalanceRoundRobin:
 			// 轮询
-			// 先确保nextIndex不会越界
-			if routeProxy.nextIndex >= len(routeProxy.TargetUpstreams) {
-				routeProxy.nextIndex = 0
-			}
-			realTargetUrl = routeProxy.TargetUpstreams[routeProxy.nextIndex].TargetUrl
-			routeProxy.nextIndex = (routeProxy.nextIndex + 1) % len(routeProxy.TargetUpstreams)
+			// 快照中的目标列表不会变化，原子递增后取模
+			index := (atomic.AddUint32(&routeProxy.nextIndex, 1) - 1) % uint32(len(routeProxy.TargetUpstreams))
+			target = routeProxy.TargetUpstreams[index]
 		case model.LoadBalanceWeight:
 			// 权重
 			if routeProxy.WeightTotal == 0 {
@@ -262,7 +261,7 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 			for _, tu := range routeProxy.TargetUpstreams {
 				weight -= tu.Weight
 				if weight <= 0 {
-					realTargetUrl = tu.TargetUrl
+					target = tu
 					break
 				}
 			}
@@ -270,32 +269,40 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 			// IP哈希
 			ipHash := util.IpHash(customIp)
 			index := ipHash % len(routeProxy.TargetUpstreams)
-			realTargetUrl = routeProxy.TargetUpstreams[index].TargetUrl
+			target = routeProxy.TargetUpstreams[index]
 		}
 
-		// 增加X-Real-IP头
-		r.Header.Add("X-Real-IP", customIp)
-
-		// 获取带参数的原始URL
-		originalURL := r.URL.String()
+		if target == nil {
+			http.NotFound(w, r)
+			return
+		}
 
-		// 去除route中的uri
-		if route.Uri != nil {
-			originalURL = strings.Replace(originalURL, *(route.Uri), "", 1)
+		// 请求总超时
+		if timeout := routeTimeout(route); timeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
 		}
 
-		if !strings.HasSuffix(realTargetUrl, "/") && !strings.HasPrefix(originalURL, "/") {
-			realTargetUrl += "/"
-		} else if strings.HasSuffix(realTargetUrl, "/") && strings.HasPrefix(originalURL, "/") {
-			originalURL = originalURL[1:]
+		// 增加X-Real-IP头
+		r.Header.Add("X-Real-IP", customIp)
+
+		// 路径重写，只修改路径，查询参数保持不变
+		outReq := r
+		if rewriter != nil {
+			rewrittenURL := *r.URL
+			rewrittenURL.Path, rewrittenURL.RawPath = rewriter.RewriteURL(r.URL)
+			outReq = r.WithContext(r.Context())
+			outReq.URL = &rewrittenURL
 		}
-		trueTargetUrl := fmt.Sprintf("%s%s", realTargetUrl, originalURL)
+		trueTargetUrl := joinTargetUrl(target.TargetUrl, outReq.URL.RequestURI())
 
 		proxyId := util.GenerateXid()
 		logger.WithField("proxyId", proxyId).Debug("准备请求真实目标地址: ", trueTargetUrl)
 
 		// 反向代理
-		proxy, err := m.getProxyService(realTargetUrl)
+		s := m.requestSnapshot(r)
+		proxy, err := m.getProxyService(s, target)
 		if err != nil {
 			logger.Error(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -318,44 +325,13 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 
 		token := ""
 		var uir *model.UserInfoRoute
-		if d, has := m.domainToUserRoute[port]; has {
-			if uir, has = d[domain]; has {
-				// 获取token
-				tokenPosition := strings.Split(uir.TokenPosition, ":")
-				if len(tokenPosition) < 3 {
-					// token获取条件不满足
-					return
-				}
-				where := tokenPosition[1]
-				p := tokenPosition[2]
-				switch tokenPosition[0] {
-				case "request":
-					switch where {
-					case "header":
-						token = r.Header.Get(p)
-					case "query":
-						token = r.URL.Query().Get(p)
-					case "body":
-						// 判断req是否是form表单提交
-						if strings.Contains(r.Header.Get(http.CanonicalHeaderKey("Content-Type")), "application/x-www-form-urlencoded") {
-							token = r.PostFormValue(p)
-						} else {
-							reqBody := make([]byte, r.ContentLength)
-							_, _ = r.Body.Read(reqBody)
-							token = gjson.ParseBytes(reqBody).Get(p).String()
-						}
-					case "cookies":
-						tokenCookie, err := r.Cookie(p)
-						if err != nil {
-							logger.Warn(err)
-						}
-						if tokenCookie != nil {
-							token = tokenCookie.Value
-						}
-					}
-				}
-				// token为空，则所有密级都为1
-			}
+		var positions []tokenPosition
+		if uir, _ = s.userRoute(port, domain); uir != nil {
+			// 获取token，读取请求体后需同步到转发的请求
+			positions = parseTokenPositions(uir.TokenPosition)
+			token = requestToken(r, positions)
+			outReq.Body = r.Body
+			// token为空，则所有密级都为1
 		}
 
 		username := ""
@@ -363,6 +339,10 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 		var secLevel = 1
 		if token != "" {
 			l, u := getTokenSecretLevel(port, domain, token)
+			if l == 0 && u == "" {
+				// 未缓存的token，JWT或令牌内省可直接解析用户
+				l, u = resolveToken(s.tokenResolver(port, domain), uir, port, domain, token)
+			}
 			if l > 0 {
 				secLevel = l
 			}
@@ -374,16 +354,31 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 		//mrw := NewMaskingResponseWriter(w, fields, secLevel)
 		mrw := NewMaskingResponseWriterWithFieldMap(w, fieldMap, secLevel)
 
-		proxy.ServeHTTP(mrw, r)
+		proxy.ServeHTTP(mrw, outReq)
 
 		logger.WithField("proxyId", proxyId).Debug("真实目标地址请求成功: ", trueTargetUrl)
 
+		// 退出登录成功后删除token，避免重放旧token仍获得用户的密级
+		if token != "" && isLogoutRequest(uir, r) && logoutSucceeded(uir.LogoutCheck, mrw.StatusCode(), mrw.cachedBody.Bytes()) {
+			deleteToken(port, domain, token)
+			logger.WithField("proxyId", proxyId).Debug("用户退出登录，删除token: ", username)
+		}
+
 		// 判断是否是用户信息路由
 		if uir != nil && uir.Path == r.URL.Path && uir.Method == r.Method {
 			// 获取用户信息，并缓存token和密级关系
 			// 1、获取body
-			bodyStr := string(mrw.cachedBody.Bytes())
-			bodyJson := gjson.Parse(bodyStr)
+			body := mrw.cachedBody.Bytes()
+			bodyJson := gjson.ParseBytes(body)
+
+			// 登录接口可在响应体、响应头或Set-Cookie中返回新的token，优先使用
+			if t := responseToken(w.Header(), body, positions); t != "" {
+				token = t
+			}
+			if token == "" {
+				// token获取失败
+				return
+			}
 
 			// 2、获取用户信息
 			uniKey := bodyJson.Get(uir.UniKeyPath).String()
@@ -396,43 +391,20 @@ func (m *manager) generateHandler(routeProxy *RouteProxy, route *model.Route, po
 			username = bodyJson.Get(uir.UsernamePath).String()
 
 			// 3、根据uniKey存储位置查找user
-			var user *model.User
-			if uir.MatchKey == "-" {
-				user = service.UserService.GetByUniKey(username, uniKey)
-			} else {
-				matchKey := bodyJson.Get(uir.MatchKey).String()
+			matchKey := ""
+			if uir.MatchKey != "-" {
+				matchKey = bodyJson.Get(uir.MatchKey).String()
 				if matchKey == "" {
 					// matchKey获取失败
 					return
 				}
-				user = service.UserService.GetByUniKeyJson(username, uniKey, matchKey)
 			}
+			user, secLevel := findUserSecretLevel(uir, username, uniKey, matchKey)
 			if user == nil {
 				// 用户信息获取失败
-				if username != "" {
-					// 保存用户信息
-					user = &model.User{
-						Username: username,
-						UniKey:   uniKey,
-					}
-					if _, _, err := service.UserService.Add(user); err != nil {
-						logger.Error("保存用户信息失败: ", err)
-						return
-					}
-				}
 				return
 			}
 
-			secLevel := user.SecLevel
-			// 还要看user在服务下的密级，如果存在，则以此为准
-			{
-				// 获取userServiceLevel
-				usl := service.UserServiceLevelService.GetByUserAndServiceID(user.ID, uir.ServiceID)
-				if usl != nil {
-					secLevel = usl.SecLevel
-				}
-			}
-
 			// 4、保存token和密级关系
 			cacheToken(port, domain, token, secLevel, user.Username)
 		}
@@ -492,21 +464,23 @@ func (m *manager) initFiberAppHandler(ps *http.Server, port uint16) {
 			}
 		}()
 
-		if allRouter, ok := m.portToRouter[port]; ok {
+		// 整个请求使用同一个快照
+		s := m.load()
+		if allRouter, ok := s.portToRouter[port]; ok {
 			var router *server.Router
 			domainName := strings.Split(r.Host, ":")[0]
-			router, ok = allRouter[domainName]
-			if !ok {
-				router = allRouter[""]
+			if serviceDomain, matched := s.matchDomain(port, domainName); matched {
+				router = allRouter[serviceDomain]
 			}
 			if router != nil {
-				route := router.FindRoute(r.URL.Path)
+				route := router.MatchRoute(r)
 				if route != nil {
 					handler := route.Handler
 
 					fieldMap := route.MaskFieldMap
 					//r = r.WithContext(context.WithValue(r.Context(), "fields", route.DesensitizeFields))
 					r = r.WithContext(context.WithValue(r.Context(), "fieldMap", fieldMap))
+					r = withSnapshot(r, s)
 					handler(w, r)
 					return
 				}
@@ -597,8 +571,14 @@ func (m *manager) listenAndServeApp(port uint16, ps *http.Server) error {
 	go func() {
 		e := ps.Serve(appLn)
 		//err := app.Listen(fmt.Sprintf(":%d", port))
-		if e != nil {
+		if e != nil && !errors.Is(e, http.ErrServerClosed) {
 			logger.Error("服务异常停止: ", e)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.portToServer[port] != ps {
+				// 端口已重新启动或已关闭
+				return
+			}
 			delete(m.portToServer, port)
 
 			// 重新启动服务
@@ -618,3 +598,13 @@ func (m *manager) listenAndServeApp(port uint16, ps *http.Server) error {
 
 	return nil
 }
+
+// joinTargetUrl 拼接上游地址及转发的请求路径，得到实际请求的地址
+func joinTargetUrl(targetUrl, requestURI string) string {
+	if !strings.HasSuffix(targetUrl, "/") && !strings.HasPrefix(requestURI, "/") {
+		targetUrl += "/"
+	} else if strings.HasSuffix(targetUrl, "/") && strings.HasPrefix(requestURI, "/") {
+		requestURI = requestURI[1:]
+	}
+	return fmt.Sprintf("%s%s", targetUrl, requestURI)
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"github.com/tidwall/gjson"
+	"net/http"
+	"security-gateway/internal/model"
+	"strings"
+)
+
+// isLogoutRequest 是否是服务配置的退出登录接口，未配置方法时匹配任意方法
+func isLogoutRequest(uir *model.UserInfoRoute, r *http.Request) bool {
+	if uir == nil || uir.LogoutPath == "" || uir.LogoutPath != r.URL.Path {
+		return false
+	}
+	return uir.LogoutMethod == "" || strings.EqualFold(uir.LogoutMethod, r.Method)
+}
+
+// logoutSucceeded 退出登录是否成功，状态码为4xx、5xx时失败，配置了响应检查时，
+// 格式为 json路径=值 时要求响应中的值相等，只有json路径时要求值为true
+func logoutSucceeded(check string, statusCode int, body []byte) bool {
+	if statusCode >= http.StatusBadRequest {
+		return false
+	}
+	check = strings.TrimSpace(check)
+	if check == "" {
+		return true
+	}
+	if path, value, ok := strings.Cut(check, "="); ok {
+		return gjson.GetBytes(body, strings.TrimSpace(path)).String() == strings.TrimSpace(value)
+	}
+	return gjson.GetBytes(body, check).Bool()
+}
@@ -174,7 +174,7 @@ func (m *manager) modifyResponse(req *http.Request, resp *ModifiableResponseWrit
 	bodyJson := gjson.Parse(body)
 
 	token := ""
-	if d, has := m.domainToUserRoute[port]; has {
+	if d, has := m.load().domainToUserRoute[port]; has {
 		if uir, ok := d[domain]; ok {
 			// 获取token
 			tokenPosition := strings.Split(uir.TokenPosition, ":")
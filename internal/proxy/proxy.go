@@ -2,12 +2,18 @@ package proxy
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"errors"
+	"fmt"
 	logger "github.com/sirupsen/logrus"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/config"
 	"security-gateway/pkg/util"
+	"time"
 )
 
 type ModifiableResponseWriter struct {
@@ -20,36 +26,206 @@ func (m *ModifiableResponseWriter) Write(b []byte) (int, error) {
 	return m.body.Write(b)
 }
 
-func (m *manager) getProxyService(targetUrl string) (*httputil.ReverseProxy, error) {
-	rp, ok := m.proxyServices[targetUrl]
-	if !ok {
-		tu, err := url.Parse(targetUrl)
-		if err != nil {
-			logger.Error(err)
+// transportOption 上游传输层参数，超时单位均为毫秒
+type transportOption struct {
+	ConnectTimeout        int
+	ResponseHeaderTimeout int
+	MaxIdleConnsPerHost   int
+	IdleConnTimeout       int
+
+	InsecureSkipVerify  bool
+	ServerName          string
+	CaCertificateID     uint64
+	ClientCertificateID uint64
+	GmMode              bool
+	// 引用证书的内容摘要，证书内容变化时重建
+	CertificateDigest string
+}
+
+// upstreamProxy 上游的目标地址及传输层参数，目标地址相同的上游可以使用不同的传输层参数
+type upstreamProxy struct {
+	targetUrl string
+	option    transportOption
+}
+
+// proxyService 按上游ID缓存的反向代理，记录构建时使用的目标地址及传输层参数，变化时重建
+type proxyService struct {
+	reverseProxy *httputil.ReverseProxy
+	transport    *http.Transport
+	upstreamProxy
+}
+
+func defaultTransportOption() transportOption {
+	return transportOption{
+		ConnectTimeout:        config.GetInt("proxy.connectTimeout", 10000),
+		ResponseHeaderTimeout: config.GetInt("proxy.responseHeaderTimeout", 60000),
+		MaxIdleConnsPerHost:   config.GetInt("proxy.maxIdleConnsPerHost", 100),
+		IdleConnTimeout:       config.GetInt("proxy.idleConnTimeout", 90000),
+	}
+}
+
+// newTransportOption 根据上游配置生成传输层参数，未配置的项使用默认配置
+func newTransportOption(upstream *model.Upstream) transportOption {
+	option := defaultTransportOption()
+	if upstream == nil {
+		return option
+	}
+	if upstream.ConnectTimeout > 0 {
+		option.ConnectTimeout = upstream.ConnectTimeout
+	}
+	if upstream.ResponseHeaderTimeout > 0 {
+		option.ResponseHeaderTimeout = upstream.ResponseHeaderTimeout
+	}
+	if upstream.MaxIdleConnsPerHost > 0 {
+		option.MaxIdleConnsPerHost = upstream.MaxIdleConnsPerHost
+	}
+	if upstream.IdleConnTimeout > 0 {
+		option.IdleConnTimeout = upstream.IdleConnTimeout
+	}
+	if upstream.TlsInsecureSkipVerify != nil {
+		option.InsecureSkipVerify = *(upstream.TlsInsecureSkipVerify)
+	}
+	if upstream.TlsServerName != nil {
+		option.ServerName = *(upstream.TlsServerName)
+	}
+	if upstream.TlsCaCertificateID != nil {
+		option.CaCertificateID = *(upstream.TlsCaCertificateID)
+	}
+	if upstream.TlsClientCertificateID != nil {
+		option.ClientCertificateID = *(upstream.TlsClientCertificateID)
+	}
+	if upstream.TlsGmMode != nil {
+		option.GmMode = *(upstream.TlsGmMode)
+	}
+	return option
+}
+
+// routeTimeout 路由的请求总超时，0表示不限制
+func routeTimeout(route *model.Route) time.Duration {
+	timeout := config.GetInt("proxy.requestTimeout", 0)
+	if route != nil && route.Timeout > 0 {
+		timeout = route.Timeout
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+// getProxyService 从快照中获取上游的反向代理，不存在时按同步时上游的传输层参数创建并发布新的快照，
+// 不使用默认参数，避免跳过上游配置的TLS
+func (m *manager) getProxyService(s *snapshot, tu *TargetUpstream) (*httputil.ReverseProxy, error) {
+	if ps, ok := s.proxyServices[tu.UpstreamID]; ok {
+		return ps.reverseProxy, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ps, ok := m.proxyServices[tu.UpstreamID]; ok {
+		return ps.reverseProxy, nil
+	}
+	up, ok := m.transports[tu.UpstreamID]
+	if !ok || up.targetUrl != tu.TargetUrl {
+		return nil, fmt.Errorf("上游%d(%s)的传输层参数不存在", tu.UpstreamID, tu.TargetUrl)
+	}
+	rp, err := m.updateProxyService(tu.UpstreamID, up)
+	if err != nil {
+		return nil, err
+	}
+	m.publish()
+	return rp, nil
+}
+
+// updateProxyService 构建或更新上游对应的反向代理，目标地址及传输层参数未变化时直接复用，调用方需持有写锁
+func (m *manager) updateProxyService(upstreamID uint64, up upstreamProxy) (*httputil.ReverseProxy, error) {
+	if ps, ok := m.proxyServices[upstreamID]; ok {
+		if ps.upstreamProxy == up {
+			return ps.reverseProxy, nil
+		}
+	}
+	return m.buildProxyService(upstreamID, up)
+}
+
+// buildProxyService 构建上游对应的反向代理，替换已有的反向代理
+func (m *manager) buildProxyService(upstreamID uint64, up upstreamProxy) (*httputil.ReverseProxy, error) {
+	targetUrl, option := up.targetUrl, up.option
+	tu, err := url.Parse(targetUrl)
+	if err != nil {
+		logger.Error(err)
+		return nil, err
+	}
+	rp := httputil.NewSingleHostReverseProxy(tu)
+
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(option.ConnectTimeout) * time.Millisecond,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   time.Duration(option.ConnectTimeout) * time.Millisecond,
+		ResponseHeaderTimeout: time.Duration(option.ResponseHeaderTimeout) * time.Millisecond,
+		MaxIdleConnsPerHost:   option.MaxIdleConnsPerHost,
+		IdleConnTimeout:       time.Duration(option.IdleConnTimeout) * time.Millisecond,
+	}
+	if tu.Scheme == "https" {
+		if err = applyUpstreamTLS(transport, dialer, option); err != nil {
+			logger.WithField("target", targetUrl).Error(err)
 			return nil, err
 		}
-		rp = httputil.NewSingleHostReverseProxy(tu)
+	}
+	rp.Transport = transport
 
-		rp.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+	rp.Director = func(req *http.Request) {
+		targetQuery := tu.RawQuery
+		req.URL.Scheme = tu.Scheme
+		req.URL.Host = tu.Host
+		req.Host = tu.Host
+		req.URL.Path, req.URL.RawPath = util.JoinURLPath(tu, req.URL)
+		if targetQuery == "" || req.URL.RawQuery == "" {
+			req.URL.RawQuery = targetQuery + req.URL.RawQuery
+		} else {
+			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
 		}
+	}
 
-		rp.Director = func(req *http.Request) {
-			targetQuery := tu.RawQuery
-			req.URL.Scheme = tu.Scheme
-			req.URL.Host = tu.Host
-			req.Host = tu.Host
-			req.URL.Path, req.URL.RawPath = util.JoinURLPath(tu, req.URL)
-			if targetQuery == "" || req.URL.RawQuery == "" {
-				req.URL.RawQuery = targetQuery + req.URL.RawQuery
-			} else {
-				req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
-			}
+	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, e error) {
+		status := http.StatusBadGateway
+		msg := "上游请求失败"
+		if isTimeoutError(e) {
+			status = http.StatusGatewayTimeout
+			msg = "上游请求超时"
 		}
+		log.WithFields(logger.Fields{
+			"target":   targetUrl,
+			"path":     r.URL.String(),
+			"customIp": util.GetUserIP(r),
+			"status":   status,
+			"error":    e.Error(),
+		}).Warn(msg)
+		w.WriteHeader(status)
+	}
 
-		m.proxyServices[targetUrl] = rp
+	if ps, ok := m.proxyServices[upstreamID]; ok {
+		// 替换前关闭旧的空闲连接
+		ps.transport.CloseIdleConnections()
+	}
+	m.proxyServices[upstreamID] = &proxyService{
+		reverseProxy:  rp,
+		transport:     transport,
+		upstreamProxy: up,
 	}
 	return rp, nil
 }
+
+// removeProxyService 删除上游对应的反向代理并关闭空闲连接
+func (m *manager) removeProxyService(upstreamID uint64) {
+	if ps, ok := m.proxyServices[upstreamID]; ok {
+		ps.transport.CloseIdleConnections()
+		delete(m.proxyServices, upstreamID)
+	}
+}
+
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"github.com/tjfoc/gmsm/gmtls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProxyService_ResponseHeaderTimeout(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	m := &manager{proxyServices: make(map[uint64]*proxyService)}
+	option := defaultTransportOption()
+	option.ResponseHeaderTimeout = 50
+	rp, err := m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL, option: option})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("超时应返回504, 实际返回: %d", w.Code)
+	}
+
+	// 参数未变化时复用，变化时重建
+	same, _ := m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL, option: option})
+	if same != rp {
+		t.Errorf("传输层参数未变化时应复用反向代理")
+	}
+	option.ResponseHeaderTimeout = 1000
+	rebuilt, _ := m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL, option: option})
+	if rebuilt == rp {
+		t.Errorf("传输层参数变化时应重建反向代理")
+	}
+
+	w = httptest.NewRecorder()
+	rebuilt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("未超时应返回200, 实际返回: %d", w.Code)
+	}
+}
+
+func TestProxyService_UpstreamTLSVerify(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	m := &manager{proxyServices: make(map[uint64]*proxyService)}
+	option := defaultTransportOption()
+	rp, err := m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL, option: option})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认校验证书，自签名证书应校验失败
+	w := httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("证书校验失败应返回502, 实际返回: %d", w.Code)
+	}
+
+	option.InsecureSkipVerify = true
+	rp, err = m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL, option: option})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("跳过校验应返回200, 实际返回: %d", w.Code)
+	}
+}
+
+func TestGetProxyService_Miss(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	option := defaultTransportOption()
+	option.InsecureSkipVerify = true
+	m := &manager{
+		proxyServices: make(map[uint64]*proxyService),
+		transports:    map[uint64]upstreamProxy{1: {targetUrl: backend.URL, option: option}},
+	}
+	m.publish()
+
+	// 快照中不存在时按上游的传输层参数创建
+	rp, err := m.getProxyService(m.load(), &TargetUpstream{UpstreamID: 1, TargetUrl: backend.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	rp.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("应使用上游的TLS配置, 实际返回: %d", w.Code)
+	}
+	if m.load().proxyServices[1] == nil {
+		t.Errorf("创建后应发布新的快照")
+	}
+
+	// 没有传输层参数时不使用默认参数创建
+	if _, err = m.getProxyService(m.load(), &TargetUpstream{UpstreamID: 2, TargetUrl: backend.URL}); err == nil {
+		t.Errorf("未知上游应返回错误")
+	}
+}
+
+func TestDialGMTLS_Context(t *testing.T) {
+	// 接受连接后不响应握手的上游
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	config := &gmtls.Config{GMSupport: &gmtls.GMSupport{}, InsecureSkipVerify: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err = dialGMTLS(ctx, &net.Dialer{}, "tcp", ln.Addr().String(), config, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("请求取消时应中断握手: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("中断握手耗时过长: %s", elapsed)
+	}
+
+	// 握手超时
+	start = time.Now()
+	if _, err = dialGMTLS(context.Background(), &net.Dialer{}, "tcp", ln.Addr().String(), config, 100*time.Millisecond); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("握手应超时: %v", err)
+	}
+}
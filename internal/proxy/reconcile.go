@@ -0,0 +1,378 @@
+package proxy
+
+import (
+	"context"
+	logger "github.com/sirupsen/logrus"
+	"reflect"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/server"
+	"sort"
+	"time"
+)
+
+// ReconcileResult 一次同步的结果
+type ReconcileResult struct {
+	Version         int64    `json:"version"`  // 已应用的配置版本
+	Time            int64    `json:"time"`     // 开始时间
+	Duration        int64    `json:"duration"` // 耗时，毫秒
+	AddedServices   int      `json:"addedServices"`
+	UpdatedServices int      `json:"updatedServices"`
+	RemovedServices int      `json:"removedServices"`
+	ProxyServices   int      `json:"proxyServices"` // 新建或重建的反向代理数量
+	Certificates    int      `json:"certificates"`  // 变化的服务证书数量
+	OpenedPorts     []uint16 `json:"openedPorts"`
+	ClosedPorts     []uint16 `json:"closedPorts"`
+}
+
+func (r *ReconcileResult) changed() bool {
+	return r.AddedServices+r.UpdatedServices+r.RemovedServices+r.ProxyServices+r.Certificates+len(r.OpenedPorts)+len(r.ClosedPorts) > 0
+}
+
+// desiredState 根据数据库计算出的期望状态
+type desiredState struct {
+	// 端口 -> 域名 -> 服务路由配置
+	services map[uint16]map[string]*domainState
+	// 上游ID -> 目标地址及传输层参数
+	transports map[uint64]upstreamProxy
+	// 端口 -> 域名 -> 服务证书
+	certificates map[uint16]map[string]*model.Certificate
+}
+
+// Reconcile 从数据库加载全部配置，与运行状态比较后应用变化，多次调用串行执行
+func (m *manager) Reconcile() (*ReconcileResult, error) {
+	m.reconcileMu.Lock()
+	defer m.reconcileMu.Unlock()
+
+	start := time.Now()
+	// 先读取版本再加载配置，加载过程中的修改会使版本变化并再次同步
+	version, err := service.ClusterService.GetConfigVersion()
+	if err != nil {
+		return nil, err
+	}
+	d, err := loadDesiredState()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	result := m.apply(d)
+	m.mu.Unlock()
+
+	result.Version = version
+	result.Time = start.UnixMilli()
+	result.Duration = time.Since(start).Milliseconds()
+	m.appliedVersion.Store(version)
+	if result.changed() {
+		logger.Infof("同步反向代理配置(版本%d): 新增服务%d 更新服务%d 删除服务%d 反向代理%d 证书%d 开启端口%v 关闭端口%v",
+			version, result.AddedServices, result.UpdatedServices, result.RemovedServices, result.ProxyServices, result.Certificates, result.OpenedPorts, result.ClosedPorts)
+	}
+	m.lastReconcile.Store(result)
+	return result, nil
+}
+
+// LastReconcile 最近一次同步的结果，未同步过时返回nil
+func (m *manager) LastReconcile() *ReconcileResult {
+	return m.lastReconcile.Load()
+}
+
+// Trigger 通知本节点后台同步，未处理的通知会合并
+func (m *manager) Trigger() {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// StartReconciler 启动后台同步，收到变化通知后从数据库同步
+func (m *manager) StartReconciler() {
+	go func() {
+		for range m.changed {
+			if _, err := m.Reconcile(); err != nil {
+				logger.Error("同步反向代理配置失败: ", err)
+			}
+		}
+	}()
+}
+
+// apply 将期望状态应用到运行状态并发布快照，未变化的服务保留已构建的路由，调用方需持有写锁
+func (m *manager) apply(d *desiredState) *ReconcileResult {
+	result := &ReconcileResult{}
+
+	// 反向代理，传输层参数未变化时复用
+	for upstreamID, up := range d.transports {
+		if ps, ok := m.proxyServices[upstreamID]; ok && ps.upstreamProxy == up {
+			continue
+		}
+		if _, err := m.buildProxyService(upstreamID, up); err != nil {
+			logger.Error("创建反向代理失败: ", err)
+			continue
+		}
+		result.ProxyServices++
+	}
+	for upstreamID := range m.proxyServices {
+		if _, ok := d.transports[upstreamID]; !ok {
+			m.removeProxyService(upstreamID)
+		}
+	}
+	m.transports = d.transports
+
+	// 服务路由配置
+	for port, domains := range d.services {
+		for domainName, ds := range domains {
+			old, ok := m.services[port][domainName]
+			switch {
+			case !ok:
+				result.AddedServices++
+			case old.equal(ds):
+				domains[domainName] = old
+			default:
+				result.UpdatedServices++
+			}
+		}
+	}
+	for port, domains := range m.services {
+		for domainName := range domains {
+			if _, ok := d.services[port][domainName]; !ok {
+				result.RemovedServices++
+			}
+		}
+	}
+	m.services = d.services
+
+	result.Certificates = m.certManager.sync(d.certificates)
+
+	// 端口下存在路由时开启服务，否则关闭
+	for port, domains := range m.services {
+		if _, ok := m.portToServer[port]; ok || !hasRoutes(domains) {
+			continue
+		}
+		if err := m.handleProxyServer(port); err != nil {
+			logger.Error("启动服务失败: ", err)
+			continue
+		}
+		result.OpenedPorts = append(result.OpenedPorts, port)
+	}
+	for port, ps := range m.portToServer {
+		if hasRoutes(m.services[port]) {
+			continue
+		}
+		_ = ps.Shutdown(context.Background())
+		delete(m.portToServer, port)
+		result.ClosedPorts = append(result.ClosedPorts, port)
+	}
+
+	m.publish()
+	return result
+}
+
+func hasRoutes(domains map[string]*domainState) bool {
+	for _, ds := range domains {
+		if len(ds.routes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// equal 路由配置是否相同，不比较已构建的路由
+func (ds *domainState) equal(o *domainState) bool {
+	return reflect.DeepEqual(ds.routes, o.routes) &&
+		reflect.DeepEqual(ds.serviceFields, o.serviceFields) &&
+		reflect.DeepEqual(ds.userRoute, o.userRoute)
+}
+
+// loadDesiredState 从数据库加载服务、路由、目标、脱敏字段、用户信息接口及证书
+func loadDesiredState() (d *desiredState, err error) {
+	services, err := service.ServiceService.ListAll()
+	if err != nil {
+		return
+	}
+	routes, err := service.RouteService.ListAll()
+	if err != nil {
+		return
+	}
+	routeTargets, err := service.RouteTargetService.ListAll()
+	if err != nil {
+		return
+	}
+	upstreams, err := service.UpstreamService.ListAll()
+	if err != nil {
+		return
+	}
+	serviceFields, err := service.ServiceFieldService.ListAll()
+	if err != nil {
+		return
+	}
+	routeFields, err := service.RouteFieldService.ListAll()
+	if err != nil {
+		return
+	}
+	userInfoRoutes, err := service.UserInfoRouteService.ListAll()
+	if err != nil {
+		return
+	}
+	certificates, err := service.CertificateService.ListAll()
+	if err != nil {
+		return
+	}
+	d = newDesiredState(services, routes, routeTargets, upstreams, serviceFields, routeFields, userInfoRoutes, certificates)
+	return
+}
+
+func newDesiredState(services []*model.Service, routes []*model.Route, routeTargets []*model.RouteTarget, upstreams []*model.Upstream,
+	serviceFields []*model.ServiceField, routeFields []*model.RouteField, userInfoRoutes []*model.UserInfoRoute, certificates []*model.Certificate) *desiredState {
+	d := &desiredState{
+		services:     make(map[uint16]map[string]*domainState),
+		transports:   make(map[uint64]upstreamProxy),
+		certificates: make(map[uint16]map[string]*model.Certificate),
+	}
+
+	certByID := make(map[uint64]*model.Certificate, len(certificates))
+	for _, cert := range certificates {
+		certByID[cert.ID] = cert
+	}
+
+	// 服务
+	dsByServiceID := make(map[uint64]*domainState, len(services))
+	for _, serv := range services {
+		if serv.Port == nil || serv.Domain == nil {
+			continue
+		}
+		port := *serv.Port
+		domainName := *serv.Domain
+		if _, ok := d.services[port]; !ok {
+			d.services[port] = make(map[string]*domainState)
+		}
+		ds := &domainState{
+			routes:        make(map[uint64]*routeState),
+			serviceFields: make(map[string]*server.DesensitizeField),
+		}
+		d.services[port][domainName] = ds
+		dsByServiceID[serv.ID] = ds
+
+		if serv.CertificateID != nil {
+			if cert, ok := certByID[*serv.CertificateID]; ok {
+				if _, ok = d.certificates[port]; !ok {
+					d.certificates[port] = make(map[string]*model.Certificate)
+				}
+				d.certificates[port][domainName] = cert
+			}
+		}
+	}
+	for _, field := range serviceFields {
+		if ds, ok := dsByServiceID[field.ServiceID]; ok {
+			ds.serviceFields[field.FieldName] = newServiceField(field)
+		}
+	}
+	// 每个服务只使用一个用户信息接口
+	sort.Slice(userInfoRoutes, func(i, j int) bool { return userInfoRoutes[i].ID < userInfoRoutes[j].ID })
+	for _, uir := range userInfoRoutes {
+		if ds, ok := dsByServiceID[uir.ServiceID]; ok && ds.userRoute == nil {
+			ds.userRoute = uir
+		}
+	}
+
+	// 路由
+	routeStates := make(map[uint64]*routeState, len(routes))
+	routeServices := make(map[uint64]*domainState, len(routes))
+	for _, route := range routes {
+		if route.ServiceID == nil {
+			continue
+		}
+		ds, ok := dsByServiceID[*route.ServiceID]
+		if !ok {
+			continue
+		}
+		routeStates[route.ID] = &routeState{
+			route:  route,
+			fields: make(map[string]*server.DesensitizeField),
+		}
+		routeServices[route.ID] = ds
+	}
+	for _, field := range routeFields {
+		if rs, ok := routeStates[field.RouteID]; ok {
+			rs.fields[field.FieldName] = newRouteField(field)
+		}
+	}
+
+	// 路由目标，按ID排序保证轮询顺序确定
+	upstreamByID := make(map[uint64]*model.Upstream, len(upstreams))
+	for _, upstream := range upstreams {
+		upstreamByID[upstream.ID] = upstream
+	}
+	sort.Slice(routeTargets, func(i, j int) bool { return routeTargets[i].ID < routeTargets[j].ID })
+	for _, rt := range routeTargets {
+		if rt.RouteID == nil || rt.UpstreamID == nil {
+			continue
+		}
+		rs, ok := routeStates[*rt.RouteID]
+		if !ok {
+			continue
+		}
+		upstream, ok := upstreamByID[*rt.UpstreamID]
+		if !ok || upstream.TargetUrl == nil {
+			continue
+		}
+		targetUrl := *(upstream.TargetUrl)
+		d.transports[upstream.ID] = upstreamProxy{targetUrl: targetUrl, option: upstreamTransportOption(upstream, certByID)}
+
+		hasTargetUpstream := false
+		for _, tu := range rs.targets {
+			if tu.UpstreamID == upstream.ID {
+				hasTargetUpstream = true
+				break
+			}
+		}
+		if !hasTargetUpstream {
+			rs.targets = append(rs.targets, &TargetUpstream{
+				UpstreamID: upstream.ID,
+				TargetUrl:  targetUrl,
+				Weight:     rt.Weight,
+			})
+		}
+	}
+	// 没有目标的路由不生效
+	for routeID, rs := range routeStates {
+		if len(rs.targets) > 0 {
+			routeServices[routeID].routes[routeID] = rs
+		}
+	}
+	return d
+}
+
+// upstreamTransportOption 上游的传输层参数，包含引用证书的内容摘要，证书内容变化时重建反向代理
+func upstreamTransportOption(upstream *model.Upstream, certByID map[uint64]*model.Certificate) transportOption {
+	option := newTransportOption(upstream)
+	var digest string
+	if cert, ok := certByID[option.CaCertificateID]; ok {
+		digest += certificateDigest(cert)
+	}
+	if cert, ok := certByID[option.ClientCertificateID]; ok {
+		digest += certificateDigest(cert)
+	}
+	option.CertificateDigest = digest
+	return option
+}
+
+func newServiceField(field *model.ServiceField) *server.DesensitizeField {
+	return &server.DesensitizeField{
+		Name:                  field.FieldName,
+		IsServiceField:        true,
+		Level1DesensitizeRule: field.Level1,
+		Level2DesensitizeRule: field.Level2,
+		Level3DesensitizeRule: field.Level3,
+		Level4DesensitizeRule: field.Level4,
+	}
+}
+
+func newRouteField(field *model.RouteField) *server.DesensitizeField {
+	return &server.DesensitizeField{
+		Name:                  field.FieldName,
+		IsServiceField:        false,
+		Level1DesensitizeRule: field.Level1,
+		Level2DesensitizeRule: field.Level2,
+		Level3DesensitizeRule: field.Level3,
+		Level4DesensitizeRule: field.Level4,
+	}
+}
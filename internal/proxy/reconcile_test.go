@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"security-gateway/internal/model"
+	"testing"
+)
+
+func newReconcileTestState(uri string, withTarget bool) *desiredState {
+	port := uint16(18080)
+	domain := "a.example.com"
+	serviceID := uint64(1)
+	routeID := uint64(2)
+	upstreamID := uint64(3)
+	targetUrl := "http://127.0.0.1:1"
+
+	services := []*model.Service{{ID: serviceID, Port: &port, Domain: &domain}}
+	routes := []*model.Route{{ID: routeID, ServiceID: &serviceID, Uri: &uri}}
+	upstreams := []*model.Upstream{{ID: upstreamID, TargetUrl: &targetUrl}}
+	var routeTargets []*model.RouteTarget
+	if withTarget {
+		routeTargets = append(routeTargets, &model.RouteTarget{ID: 4, RouteID: &routeID, UpstreamID: &upstreamID, Weight: 1})
+	}
+	serviceFields := []*model.ServiceField{{ID: 5, ServiceID: serviceID, FieldName: "phone", Level1: "all"}}
+	return newDesiredState(services, routes, routeTargets, upstreams, serviceFields, nil, nil, nil)
+}
+
+func TestManager_Apply(t *testing.T) {
+	m := &manager{
+		services: make(map[uint16]map[string]*domainState),
+		// 端口已启动，测试中不监听端口
+		portToServer:  map[uint16]*http.Server{18080: {}},
+		proxyServices: make(map[uint64]*proxyService),
+		certManager:   &certificateManager{},
+	}
+
+	result := m.apply(newReconcileTestState("/api", true))
+	if result.AddedServices != 1 || result.ProxyServices != 1 {
+		t.Errorf("首次同步结果错误: %+v", result)
+	}
+	router := m.load().portToRouter[18080]["a.example.com"]
+	if router == nil || router.MatchRoute(httptest.NewRequest(http.MethodGet, "/api/users", nil)) == nil {
+		t.Fatal("同步后应能匹配路由")
+	}
+
+	// 配置未变化时不重建
+	result = m.apply(newReconcileTestState("/api", true))
+	if result.changed() {
+		t.Errorf("配置未变化时不应有变更: %+v", result)
+	}
+	if m.load().portToRouter[18080]["a.example.com"] != router {
+		t.Errorf("配置未变化时应复用已构建的路由")
+	}
+
+	// 路由变化
+	result = m.apply(newReconcileTestState("/v2", true))
+	if result.UpdatedServices != 1 {
+		t.Errorf("路由变化后应更新服务: %+v", result)
+	}
+	if m.load().portToRouter[18080]["a.example.com"].MatchRoute(httptest.NewRequest(http.MethodGet, "/api/users", nil)) != nil {
+		t.Errorf("旧路由应被移除")
+	}
+
+	// 路由目标全部删除后关闭端口
+	result = m.apply(newReconcileTestState("/v2", false))
+	if len(result.ClosedPorts) != 1 || result.ClosedPorts[0] != 18080 {
+		t.Errorf("端口下没有路由时应关闭: %+v", result)
+	}
+	if len(m.proxyServices) != 0 {
+		t.Errorf("未引用的反向代理应被移除")
+	}
+}
+
+func TestManager_ApplySameTargetUrl(t *testing.T) {
+	m := &manager{
+		services:      make(map[uint16]map[string]*domainState),
+		portToServer:  map[uint16]*http.Server{18080: {}},
+		proxyServices: make(map[uint64]*proxyService),
+		certManager:   &certificateManager{},
+	}
+	port, domain, targetUrl := uint16(18080), "a.example.com", "http://127.0.0.1:1"
+	serviceID := uint64(1)
+	uri1, uri2 := "/fast", "/slow"
+	routeID1, routeID2 := uint64(2), uint64(3)
+	upstreamID1, upstreamID2 := uint64(4), uint64(5)
+	d := newDesiredState(
+		[]*model.Service{{ID: serviceID, Port: &port, Domain: &domain}},
+		[]*model.Route{{ID: routeID1, ServiceID: &serviceID, Uri: &uri1}, {ID: routeID2, ServiceID: &serviceID, Uri: &uri2}},
+		[]*model.RouteTarget{
+			{ID: 6, RouteID: &routeID1, UpstreamID: &upstreamID1, Weight: 1},
+			{ID: 7, RouteID: &routeID2, UpstreamID: &upstreamID2, Weight: 1},
+		},
+		[]*model.Upstream{
+			{ID: upstreamID1, TargetUrl: &targetUrl, ResponseHeaderTimeout: 100},
+			{ID: upstreamID2, TargetUrl: &targetUrl, ResponseHeaderTimeout: 5000},
+		},
+		nil, nil, nil, nil)
+
+	// 目标地址相同的上游各自使用自己的传输层参数
+	if result := m.apply(d); result.ProxyServices != 2 {
+		t.Fatalf("目标地址相同的上游应分别创建反向代理: %+v", result)
+	}
+	if m.proxyServices[upstreamID1].option.ResponseHeaderTimeout != 100 || m.proxyServices[upstreamID2].option.ResponseHeaderTimeout != 5000 {
+		t.Errorf("反向代理的传输层参数错误")
+	}
+	expected := map[uint64]uint64{routeID1: upstreamID1, routeID2: upstreamID2}
+	for _, rp := range m.load().portToRoutes[port][domain] {
+		if len(rp.TargetUpstreams) != 1 || rp.TargetUpstreams[0].UpstreamID != expected[rp.RouteID] {
+			t.Errorf("路由%d的目标错误: %+v", rp.RouteID, rp.TargetUpstreams[0])
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/server"
+	"strconv"
+	"strings"
+)
+
+// RouteMatch 请求匹配到的服务及路由
+type RouteMatch struct {
+	Port         uint16                              `json:"port"`
+	Domain       string                              `json:"domain"` // 匹配到的服务域名
+	RouteID      uint64                              `json:"routeId,string"`
+	Path         string                              `json:"path"` // 路由路径
+	LoadBalance  int                                 `json:"loadBalance"`
+	RewrittenUri string                              `json:"rewrittenUri"` // 路径重写后转发的路径及查询参数
+	Upstreams    []*ResolvedUpstream                 `json:"upstreams"`
+	Fields       map[string]*server.DesensitizeField `json:"fields"`        // 服务及路由合并后的脱敏字段，路由字段覆盖同名的服务字段
+	UserRoute    *model.UserInfoRoute                `json:"userInfoRoute"` // 服务的用户信息路由，不返回密钥
+}
+
+// ResolvedUpstream 路由的候选上游
+type ResolvedUpstream struct {
+	UpstreamID    uint64 `json:"upstreamId,string"`
+	Name          string `json:"name"`
+	TargetUrl     string `json:"targetUrl"`
+	Weight        int    `json:"weight"`
+	Status        int    `json:"status"` // 健康检测状态，0-未检测 1-正常 2-健康检测失败
+	LastCheckTime int64  `json:"lastCheckTime"`
+	RequestUrl    string `json:"requestUrl"` // 转发到该上游时实际请求的地址
+}
+
+// NewResolveRequest 根据完整的URL构造查找路由使用的请求，返回请求的端口，未指定端口时按协议使用80或443
+func NewResolveRequest(method, rawUrl string, header http.Header) (uint16, *http.Request, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" {
+		return 0, nil, fmt.Errorf("URL格式错误: %s", rawUrl)
+	}
+	port := uint64(80)
+	if u.Port() != "" {
+		if port, err = strconv.ParseUint(u.Port(), 10, 16); err != nil {
+			return 0, nil, fmt.Errorf("端口格式错误: %s", u.Port())
+		}
+	} else if u.Scheme == "https" {
+		port = 443
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+	r, err := http.NewRequest(strings.ToUpper(method), u.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	for name, values := range header {
+		if strings.EqualFold(name, "Host") {
+			if len(values) > 0 {
+				r.Host = values[0]
+			}
+			continue
+		}
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	return uint16(port), r, nil
+}
+
+// resolve 按请求的端口、域名及路由树查找路由，与请求处理的匹配规则相同，未匹配时返回nil
+func (s *snapshot) resolve(port uint16, r *http.Request) *RouteMatch {
+	serviceDomain, ok := s.matchDomain(port, strings.Split(r.Host, ":")[0])
+	if !ok {
+		return nil
+	}
+	router := s.portToRouter[port][serviceDomain]
+	if router == nil {
+		return nil
+	}
+	route := router.MatchRoute(r)
+	if route == nil {
+		return nil
+	}
+	for _, rp := range s.portToRoutes[port][serviceDomain] {
+		if routeKey(rp.RouteID) != route.Key() {
+			continue
+		}
+		match := &RouteMatch{
+			Port:    port,
+			Domain:  serviceDomain,
+			RouteID: rp.RouteID,
+			Path:    rp.Path,
+			Fields:  route.MaskFieldMap,
+		}
+		// 与请求处理相同的路径重写
+		rewritten := *r.URL
+		if rp.route != nil {
+			match.LoadBalance = rp.route.LoadBalance
+			if rewriter := newPathRewriter(rp.route); rewriter != nil {
+				rewritten.Path, rewritten.RawPath = rewriter.RewriteURL(r.URL)
+			}
+		}
+		match.RewrittenUri = rewritten.RequestURI()
+		for _, tu := range rp.TargetUpstreams {
+			match.Upstreams = append(match.Upstreams, &ResolvedUpstream{
+				UpstreamID: tu.UpstreamID,
+				TargetUrl:  tu.TargetUrl,
+				Weight:     tu.Weight,
+				RequestUrl: joinTargetUrl(tu.TargetUrl, match.RewrittenUri),
+			})
+		}
+		if uir, ok := s.userRoute(port, serviceDomain); ok {
+			copied := *uir
+			copied.JwtKey, copied.ClientSecret = "", ""
+			match.UserRoute = &copied
+		}
+		return match
+	}
+	return nil
+}
+
+// fillUpstreamStatus 从数据库获取候选上游的名称及健康检测状态
+func fillUpstreamStatus(match *RouteMatch) error {
+	upstreams, err := service.UpstreamService.ListAll()
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint64]*model.Upstream, len(upstreams))
+	for _, upstream := range upstreams {
+		byID[upstream.ID] = upstream
+	}
+	for _, u := range match.Upstreams {
+		upstream, ok := byID[u.UpstreamID]
+		if !ok {
+			continue
+		}
+		u.Status, u.LastCheckTime = upstream.Status, upstream.LastCheckTime
+		if upstream.Name != nil {
+			u.Name = *(upstream.Name)
+		}
+	}
+	return nil
+}
+
+// Resolve 按运行中的反向代理查找请求匹配的路由，未匹配时返回nil
+func (m *manager) Resolve(port uint16, r *http.Request) (*RouteMatch, error) {
+	match := m.load().resolve(port, r)
+	if match == nil {
+		return nil, nil
+	}
+	return match, fillUpstreamStatus(match)
+}
+
+// ResolveRoute 按数据库中的配置查找请求匹配的路由，不依赖运行中的反向代理，不开启端口
+func ResolveRoute(port uint16, r *http.Request) (*RouteMatch, error) {
+	d, err := loadDesiredState()
+	if err != nil {
+		return nil, err
+	}
+	m := &manager{
+		services:      d.services,
+		proxyServices: make(map[uint64]*proxyService),
+	}
+	m.publish()
+	match := m.load().resolve(port, r)
+	if match == nil {
+		return nil, nil
+	}
+	return match, fillUpstreamStatus(match)
+}
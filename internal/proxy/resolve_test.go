@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/server"
+	"testing"
+)
+
+func TestSnapshot_Resolve(t *testing.T) {
+	rs := newSnapshotTestRoute(1, "/api/{\\d+}")
+	rs.route.RewriteType, rs.route.RewriteValue = model.RouteRewriteRegex, new(string)
+	*rs.route.RewriteValue = "/v2/users/$1"
+	rs.fields["phone"] = &server.DesensitizeField{Name: "phone", Level1DesensitizeRule: "all"}
+	m := &manager{
+		services:      make(map[uint16]map[string]*domainState),
+		proxyServices: make(map[uint64]*proxyService),
+	}
+	m.services[8080] = map[string]*domainState{
+		"a.example.com": {
+			routes:        map[uint64]*routeState{1: rs},
+			serviceFields: map[string]*server.DesensitizeField{"name": {Name: "name", IsServiceField: true}},
+			userRoute:     &model.UserInfoRoute{Path: "/api/me", TokenType: model.TokenTypeJwt, JwtKey: "secret"},
+		},
+	}
+	m.publish()
+
+	port, r, err := NewResolveRequest("post", "http://a.example.com:8080/api/12/orders?page=1", nil)
+	if err != nil || port != 8080 || r.Method != http.MethodPost {
+		t.Fatalf("构造请求错误: %d %v", port, err)
+	}
+	match := m.load().resolve(port, r)
+	if match == nil {
+		t.Fatal("应匹配到路由")
+	}
+	if match.Domain != "a.example.com" || match.Path != "/api/{\\d+}" || match.RouteID != 1 {
+		t.Errorf("匹配的服务或路由错误: %+v", match)
+	}
+	if match.RewrittenUri != "/v2/users/12/orders?page=1" || len(match.Upstreams) != 1 ||
+		match.Upstreams[0].RequestUrl != "http://127.0.0.1:1/v2/users/12/orders?page=1" {
+		t.Errorf("重写后的地址错误: %s %+v", match.RewrittenUri, match.Upstreams)
+	}
+	if match.Fields["phone"] == nil || match.Fields["name"] == nil {
+		t.Errorf("脱敏字段应合并服务及路由字段: %v", match.Fields)
+	}
+	if match.UserRoute == nil || match.UserRoute.Path != "/api/me" || match.UserRoute.JwtKey != "" {
+		t.Errorf("用户信息路由错误或返回了密钥: %+v", match.UserRoute)
+	}
+
+	_, r, _ = NewResolveRequest(http.MethodGet, "http://b.example.com:8080/api/12", nil)
+	if match = m.load().resolve(8080, r); match != nil {
+		t.Errorf("未配置的域名不应匹配: %+v", match)
+	}
+}
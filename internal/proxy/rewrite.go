@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	logger "github.com/sirupsen/logrus"
+	"net/url"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/server"
+	"strings"
+)
+
+// pathRewriter 路由的路径重写规则
+type pathRewriter struct {
+	rewriteType int
+	value       string
+	pattern     *server.PathPattern
+}
+
+// newPathRewriter 根据路由配置生成路径重写规则，保留原路径时返回nil
+func newPathRewriter(route *model.Route) *pathRewriter {
+	if route == nil || route.Uri == nil {
+		return nil
+	}
+	switch route.RewriteType {
+	case model.RouteRewriteStripPrefix, model.RouteRewriteReplacePrefix, model.RouteRewriteRegex:
+	default:
+		return nil
+	}
+	pattern, err := server.CompilePathPattern(*(route.Uri))
+	if err != nil {
+		logger.Error("路径重写规则无效: ", err)
+		return nil
+	}
+	rewriter := &pathRewriter{
+		rewriteType: route.RewriteType,
+		pattern:     pattern,
+	}
+	if route.RewriteValue != nil {
+		rewriter.value = *(route.RewriteValue)
+	}
+	return rewriter
+}
+
+// Rewrite 重写请求路径，路由前缀匹配失败时返回原路径
+func (p *pathRewriter) Rewrite(path string) string {
+	if p == nil {
+		return path
+	}
+	return p.rewrite(path, p.pattern.Match)
+}
+
+// RewriteURL 按编码后的路径重写请求URL，返回新的Path及RawPath，保留%2F、%3B等编码的字符，
+// 避免解码后转发到上游时路径片段发生变化
+func (p *pathRewriter) RewriteURL(u *url.URL) (path, rawPath string) {
+	if p == nil {
+		return u.Path, u.RawPath
+	}
+	rawPath = p.rewrite(u.EscapedPath(), p.pattern.MatchEscaped)
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		logger.Warn("重写后的路径无效: ", rawPath)
+		return u.Path, u.RawPath
+	}
+	return path, rawPath
+}
+
+func (p *pathRewriter) rewrite(path string, match func(string) (string, []string, bool)) string {
+	rest, captures, ok := match(path)
+	if !ok {
+		return path
+	}
+	var prefix string
+	switch p.rewriteType {
+	case model.RouteRewriteStripPrefix:
+	case model.RouteRewriteReplacePrefix:
+		prefix = p.value
+	case model.RouteRewriteRegex:
+		prefix = server.ExpandCaptures(p.value, captures)
+	default:
+		return path
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if rest == "/" && prefix != "" && !strings.HasSuffix(path, "/") {
+		// 请求路径与路由完全一致时不额外增加末尾的/
+		return prefix
+	}
+	return prefix + rest
+}
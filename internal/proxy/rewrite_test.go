@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"security-gateway/internal/model"
+	"testing"
+)
+
+func TestPathRewriter_Rewrite(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name  string
+		route *model.Route
+		path  string
+		want  string
+	}{
+		{"保留", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteKeep}, "/api/users", "/api/users"},
+		{"去除前缀", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteStripPrefix}, "/api/users", "/users"},
+		{"去除前缀-完全一致", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteStripPrefix}, "/api", "/"},
+		{"去除前缀-只匹配开头", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteStripPrefix}, "/apix/api", "/apix/api"},
+		{"替换前缀", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteReplacePrefix, RewriteValue: str("/v2/")}, "/api/users", "/v2/users"},
+		{"替换前缀-完全一致", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteReplacePrefix, RewriteValue: str("/v2")}, "/api", "/v2"},
+		{"正则重写", &model.Route{Uri: str("/users/{\\d+}/{[a-z]+}"), RewriteType: model.RouteRewriteRegex, RewriteValue: str("/v2/${2}/$1")}, "/users/12/orders/3", "/v2/orders/12/3"},
+		{"正则重写-不匹配", &model.Route{Uri: str("/users/{\\d+}"), RewriteType: model.RouteRewriteRegex, RewriteValue: str("/v2/$1")}, "/users/abc", "/users/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPathRewriter(tt.route).Rewrite(tt.path); got != tt.want {
+				t.Errorf("Rewrite(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathRewriter_RewriteURL(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name     string
+		route    *model.Route
+		target   string
+		wantPath string
+		wantRaw  string
+	}{
+		{"编码的斜杠", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteStripPrefix}, "/api/files/a%2Fb%3Bc", "/files/a/b;c", "/files/a%2Fb%3Bc"},
+		{"替换前缀", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteReplacePrefix, RewriteValue: str("/v2")}, "/api/a%2Fb", "/v2/a/b", "/v2/a%2Fb"},
+		{"正则重写", &model.Route{Uri: str("/users/{[^/]+}"), RewriteType: model.RouteRewriteRegex, RewriteValue: str("/v2/$1")}, "/users/a%2Fb/x", "/v2/a/b/x", "/v2/a%2Fb/x"},
+		{"编码的路由片段", &model.Route{Uri: str("/用户"), RewriteType: model.RouteRewriteStripPrefix}, "/%E7%94%A8%E6%88%B7/1", "/1", "/1"},
+		{"未编码", &model.Route{Uri: str("/api"), RewriteType: model.RouteRewriteStripPrefix}, "/api/users", "/users", "/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.ParseRequestURI(tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, rawPath := newPathRewriter(tt.route).RewriteURL(u)
+			if path != tt.wantPath || rawPath != tt.wantRaw {
+				t.Errorf("RewriteURL(%s) = %s %s, want %s %s", tt.target, path, rawPath, tt.wantPath, tt.wantRaw)
+			}
+		})
+	}
+
+	// 转发到上游时保留编码的斜杠
+	var requestURI string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+	}))
+	defer backend.Close()
+	m := &manager{proxyServices: make(map[uint64]*proxyService)}
+	rp, err := m.updateProxyService(1, upstreamProxy{targetUrl: backend.URL + "/base", option: defaultTransportOption()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/files/a%2Fb?x=1", nil)
+	rewritten := *r.URL
+	rewritten.Path, rewritten.RawPath = newPathRewriter(tests[0].route).RewriteURL(r.URL)
+	r.URL = &rewritten
+	rp.ServeHTTP(httptest.NewRecorder(), r)
+	if requestURI != "/base/files/a%2Fb?x=1" {
+		t.Errorf("上游收到的地址错误: %s", requestURI)
+	}
+}
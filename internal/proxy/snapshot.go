@@ -0,0 +1,184 @@
+package proxy
+
+import (
+	"context"
+	logger "github.com/sirupsen/logrus"
+	"net/http"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/server"
+	"sort"
+)
+
+// snapshot 运行时路由快照，发布后不再修改。
+// 请求处理只读取快照，不加锁；配置变化时在写锁内构建新的快照并原子替换。
+type snapshot struct {
+	// 端口 -> 域名 -> (路由 -> 目标)
+	portToRoutes map[uint16]map[string][]*RouteProxy
+	portToRouter map[uint16]map[string]*server.Router
+	// 端口下的域名索引，只包含有路由的域名
+	portToDomainIndex map[uint16]*server.DomainIndex
+	// 服务的用户信息接口
+	domainToUserRoute map[uint16]map[string]*model.UserInfoRoute
+	// 服务的token解析器，用户信息接口的token类型为jwt或introspection时存在
+	domainToResolver map[uint16]map[string]*tokenResolver
+	// 反向代理服务
+	proxyServices map[uint64]*proxyService
+}
+
+var emptySnapshot = &snapshot{}
+
+// matchDomain 查找请求域名对应的服务域名，顺序：精确匹配、最长通配符后缀、正则、默认
+func (s *snapshot) matchDomain(port uint16, host string) (string, bool) {
+	return s.portToDomainIndex[port].Match(host)
+}
+
+// userRoute 服务的用户信息接口
+func (s *snapshot) userRoute(port uint16, domain string) (uir *model.UserInfoRoute, ok bool) {
+	uir, ok = s.domainToUserRoute[port][domain]
+	return
+}
+
+// tokenResolver 服务的token解析器
+func (s *snapshot) tokenResolver(port uint16, domain string) *tokenResolver {
+	return s.domainToResolver[port][domain]
+}
+
+type snapshotContextKey struct{}
+
+// withSnapshot 请求处理过程中使用同一个快照
+func withSnapshot(r *http.Request, s *snapshot) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), snapshotContextKey{}, s))
+}
+
+// requestSnapshot 获取请求开始时的快照，不存在时使用当前快照
+func (m *manager) requestSnapshot(r *http.Request) *snapshot {
+	if s, ok := r.Context().Value(snapshotContextKey{}).(*snapshot); ok {
+		return s
+	}
+	return m.load()
+}
+
+// load 获取当前快照
+func (m *manager) load() *snapshot {
+	if s := m.current.Load(); s != nil {
+		return s
+	}
+	return emptySnapshot
+}
+
+// domainState 服务(端口+域名)的路由配置，只在持有写锁时访问
+type domainState struct {
+	routes        map[uint64]*routeState
+	serviceFields map[string]*server.DesensitizeField
+	userRoute     *model.UserInfoRoute
+	// 已构建的路由，配置变化时置空，未变化的服务在发布新快照时直接复用
+	built *builtDomain
+}
+
+// routeState 路由配置，fields只包含路由自身的脱敏字段
+type routeState struct {
+	route   *model.Route
+	targets []*TargetUpstream
+	fields  map[string]*server.DesensitizeField
+}
+
+type builtDomain struct {
+	router *server.Router
+	routes []*RouteProxy
+	// token解析器，JWKS及令牌内省的无效token在解析器中缓存，服务配置未变化时保留
+	resolver *tokenResolver
+}
+
+// publish 根据当前配置构建新的快照并原子替换，调用方需持有写锁
+func (m *manager) publish() {
+	s := &snapshot{
+		portToRoutes:      make(map[uint16]map[string][]*RouteProxy, len(m.services)),
+		portToRouter:      make(map[uint16]map[string]*server.Router, len(m.services)),
+		portToDomainIndex: make(map[uint16]*server.DomainIndex, len(m.services)),
+		domainToUserRoute: make(map[uint16]map[string]*model.UserInfoRoute, len(m.services)),
+		domainToResolver:  make(map[uint16]map[string]*tokenResolver, len(m.services)),
+		proxyServices:     make(map[uint64]*proxyService, len(m.proxyServices)),
+	}
+	for port, domains := range m.services {
+		s.portToRoutes[port] = make(map[string][]*RouteProxy, len(domains))
+		s.portToRouter[port] = make(map[string]*server.Router, len(domains))
+		s.domainToUserRoute[port] = make(map[string]*model.UserInfoRoute)
+		s.domainToResolver[port] = make(map[string]*tokenResolver)
+		var domainNames []string
+		for domainName, ds := range domains {
+			if ds.userRoute != nil {
+				s.domainToUserRoute[port][domainName] = ds.userRoute
+			}
+			if ds.built == nil {
+				ds.built = m.buildDomain(port, domainName, ds)
+			}
+			if ds.built.resolver != nil {
+				s.domainToResolver[port][domainName] = ds.built.resolver
+			}
+			if len(ds.built.routes) == 0 {
+				continue
+			}
+			s.portToRoutes[port][domainName] = ds.built.routes
+			s.portToRouter[port][domainName] = ds.built.router
+			domainNames = append(domainNames, domainName)
+		}
+		s.portToDomainIndex[port] = server.NewDomainIndex(domainNames)
+	}
+	for upstreamID, ps := range m.proxyServices {
+		s.proxyServices[upstreamID] = ps
+	}
+	m.current.Store(s)
+}
+
+// buildDomain 构建服务下的路由树，路由按ID排序保证结果确定
+func (m *manager) buildDomain(port uint16, domainName string, ds *domainState) *builtDomain {
+	built := &builtDomain{router: &server.Router{}}
+	routeIDs := make([]uint64, 0, len(ds.routes))
+	for id := range ds.routes {
+		routeIDs = append(routeIDs, id)
+	}
+	sort.Slice(routeIDs, func(i, j int) bool { return routeIDs[i] < routeIDs[j] })
+
+	if ds.userRoute != nil {
+		var err error
+		if built.resolver, err = newTokenResolver(ds.userRoute); err != nil {
+			logger.Errorf("服务%d:%s的token解析配置错误: %v", port, domainName, err)
+		}
+	}
+
+	for _, id := range routeIDs {
+		rs := ds.routes[id]
+		if len(rs.targets) == 0 || rs.route.Uri == nil {
+			continue
+		}
+		routeProxy := &RouteProxy{
+			RouteID: id,
+			Path:    *(rs.route.Uri),
+			route:   rs.route,
+		}
+		for _, tu := range rs.targets {
+			routeProxy.TargetUpstreams = append(routeProxy.TargetUpstreams, &TargetUpstream{
+				UpstreamID: tu.UpstreamID,
+				TargetUrl:  tu.TargetUrl,
+				Weight:     tu.Weight,
+			})
+			routeProxy.WeightTotal += tu.Weight
+		}
+
+		// 整理要脱敏的字段，路由字段覆盖同名的服务字段
+		fieldMap := make(map[string]*server.DesensitizeField, len(ds.serviceFields)+len(rs.fields))
+		for name, field := range ds.serviceFields {
+			fieldMap[name] = field
+		}
+		for name, field := range rs.fields {
+			fieldMap[name] = field
+		}
+
+		methods, headers, queries, cookies := rs.route.PredicateValues()
+		predicate := server.ParsePredicate(methods, headers, queries, cookies, rs.route.Priority)
+		handler := m.generateHandler(routeProxy, rs.route, port, domainName)
+		built.router.AddRoute(routeKey(id), routeProxy.Path, predicate, handler, fieldMap)
+		built.routes = append(built.routes, routeProxy)
+	}
+	return built
+}
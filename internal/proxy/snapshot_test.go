@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/server"
+	"sync"
+	"testing"
+)
+
+func newSnapshotTestRoute(id uint64, uri string) *routeState {
+	return &routeState{
+		route:   &model.Route{ID: id, Uri: &uri},
+		targets: []*TargetUpstream{{TargetUrl: "http://127.0.0.1:1", Weight: 1}},
+		fields:  make(map[string]*server.DesensitizeField),
+	}
+}
+
+func TestManager_PublishSnapshot(t *testing.T) {
+	m := &manager{
+		services:      make(map[uint16]map[string]*domainState),
+		proxyServices: make(map[uint64]*proxyService),
+	}
+	m.services[8080] = map[string]*domainState{
+		"a.example.com": {routes: map[uint64]*routeState{1: newSnapshotTestRoute(1, "/api")}},
+	}
+	m.publish()
+	old := m.load()
+
+	// 修改配置并发布后，旧快照保持不变
+	ds := m.services[8080]["a.example.com"]
+	ds.routes[2] = newSnapshotTestRoute(2, "/admin")
+	ds.built = nil
+	m.publish()
+	current := m.load()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	if route := old.portToRouter[8080]["a.example.com"].MatchRoute(req); route != nil {
+		t.Errorf("旧快照不应包含新路由, 实际匹配: %s", route.Path())
+	}
+	if route := current.portToRouter[8080]["a.example.com"].MatchRoute(req); route == nil || route.Key() != routeKey(2) {
+		t.Errorf("新快照应匹配新路由")
+	}
+	if len(old.portToRoutes[8080]["a.example.com"]) != 1 || len(current.portToRoutes[8080]["a.example.com"]) != 2 {
+		t.Errorf("快照路由数量错误")
+	}
+
+	// 未变化的服务直接复用
+	m.services[8080]["b.example.com"] = &domainState{routes: map[uint64]*routeState{3: newSnapshotTestRoute(3, "/")}}
+	m.publish()
+	if m.load().portToRouter[8080]["a.example.com"] != current.portToRouter[8080]["a.example.com"] {
+		t.Errorf("未变化的服务应复用已构建的路由")
+	}
+	if domain, ok := m.load().matchDomain(8080, "B.example.com"); !ok || domain != "b.example.com" {
+		t.Errorf("新快照应包含新服务域名, 实际: %s", domain)
+	}
+}
+
+func TestManager_ConcurrentPublish(t *testing.T) {
+	m := &manager{
+		services:      make(map[uint16]map[string]*domainState),
+		proxyServices: make(map[uint64]*proxyService),
+	}
+	m.services[8080] = map[string]*domainState{
+		"": {routes: map[uint64]*routeState{1: newSnapshotTestRoute(1, "/api")}},
+	}
+	m.publish()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := uint64(2); i < 200; i++ {
+			m.mu.Lock()
+			ds := m.services[8080][""]
+			ds.routes[i] = newSnapshotTestRoute(i, "/api")
+			delete(ds.routes, i-1)
+			ds.built = nil
+			m.publish()
+			m.mu.Unlock()
+		}
+		close(done)
+	}()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s := m.load()
+				domain, ok := s.matchDomain(8080, "any.example.com")
+				if !ok {
+					t.Error("默认服务应始终可匹配")
+					return
+				}
+				// 每个快照中始终恰好有一个路由
+				if route := s.portToRouter[8080][domain].MatchRoute(req); route == nil {
+					t.Error("快照中路由不应缺失")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
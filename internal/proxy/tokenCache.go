@@ -0,0 +1,198 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/pkg/cache"
+	"sync"
+	"time"
+)
+
+// channelTokenInvalidated 用户密级变化通知频道，其他节点收到后清除本地缓存
+var channelTokenInvalidated = cache.Prefix + ":token_invalidated"
+
+// TokenKey 端口、域名下的token
+type TokenKey struct {
+	Port     uint16
+	Domain   string
+	Token    string
+	Username string
+}
+
+// tokenInvalidation 清除用户的本地缓存，Port为0时清除用户在所有服务下的缓存；
+// TokenHash不为空时只清除服务下的该token，通知中只包含token的摘要
+type tokenInvalidation struct {
+	Port      uint16 `json:"port"`
+	Domain    string `json:"domain"`
+	Username  string `json:"username"`
+	TokenHash string `json:"tokenHash,omitempty"`
+}
+
+func (i *tokenInvalidation) match(key TokenKey, username string) bool {
+	if i.TokenHash != "" {
+		return key.Port == i.Port && key.Domain == i.Domain && tokenHash(key.Token) == i.TokenHash
+	}
+	return username == i.Username && (i.Port == 0 || (key.Port == i.Port && key.Domain == i.Domain))
+}
+
+// tokenCache 本地token缓存，位于token存储之前，命中时不访问存储，过期时间的刷新合并后定时批量提交
+type tokenCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	entries  map[TokenKey]*tokenCacheEntry
+	// 命中后待刷新过期时间的token
+	touched map[TokenKey]struct{}
+	// 每次清除缓存时递增，查询存储期间发生清除时不写入缓存，避免写入旧的密级
+	generation uint64
+}
+
+type tokenCacheEntry struct {
+	info     *TokenInfo
+	expireAt time.Time
+}
+
+func newTokenCache(ttl time.Duration, capacity int) *tokenCache {
+	return &tokenCache{
+		ttl:      ttl,
+		capacity: capacity,
+		entries:  make(map[TokenKey]*tokenCacheEntry),
+		touched:  make(map[TokenKey]struct{}),
+	}
+}
+
+// get 从本地缓存获取，未命中时查询存储并写入缓存，不存在的token不缓存
+func (c *tokenCache) get(store TokenStore, port uint16, domain, token string) (info *TokenInfo, err error) {
+	key := TokenKey{Port: port, Domain: domain, Token: token}
+	now := time.Now()
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && now.Before(e.expireAt) {
+		if e.info.ExpireAt == 0 {
+			c.touched[key] = struct{}{}
+		}
+		c.mu.Unlock()
+		return e.info, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	info, err = store.Get(port, domain, token)
+	if err != nil || info == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.put(key, info, now)
+	}
+	c.mu.Unlock()
+	return
+}
+
+// set 保存token后写入缓存
+func (c *tokenCache) set(port uint16, domain, token string, info *TokenInfo) {
+	c.mu.Lock()
+	c.generation++
+	c.put(TokenKey{Port: port, Domain: domain, Token: token}, info, time.Now())
+	c.mu.Unlock()
+}
+
+// put 写入缓存，固定过期时间的token不超过其过期时间，超出容量时先清除已过期的缓存，仍超出时全部清除，调用方需持有锁
+func (c *tokenCache) put(key TokenKey, info *TokenInfo, now time.Time) {
+	if len(c.entries) >= c.capacity {
+		for k, e := range c.entries {
+			if !now.Before(e.expireAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.capacity {
+			c.entries = make(map[TokenKey]*tokenCacheEntry)
+		}
+	}
+	expireAt := now.Add(c.ttl)
+	if info.ExpireAt > 0 && info.ExpireAt < expireAt.Unix() {
+		expireAt = time.Unix(info.ExpireAt, 0)
+	}
+	c.entries[key] = &tokenCacheEntry{
+		info:     info,
+		expireAt: expireAt,
+	}
+}
+
+// invalidate 清除用户或token的本地缓存
+func (c *tokenCache) invalidate(i *tokenInvalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for k, e := range c.entries {
+		if i.match(k, e.info.Username) {
+			delete(c.entries, k)
+			delete(c.touched, k)
+		}
+	}
+}
+
+// flush 批量刷新缓存命中的token在存储中的过期时间
+func (c *tokenCache) flush(store TokenStore) {
+	c.mu.Lock()
+	if len(c.touched) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	keys := make([]TokenKey, 0, len(c.touched))
+	for k := range c.touched {
+		if e, ok := c.entries[k]; ok {
+			k.Username = e.info.Username
+			keys = append(keys, k)
+		}
+	}
+	c.touched = make(map[TokenKey]struct{})
+	c.mu.Unlock()
+
+	if err := store.Touch(keys); err != nil {
+		logger.Error("刷新token过期时间失败: ", err)
+	}
+}
+
+// start 定时批量刷新过期时间
+func (c *tokenCache) start(store TokenStore) {
+	go func() {
+		ticker := time.NewTicker(c.ttl)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.flush(store)
+		}
+	}()
+}
+
+// invalidateTokenCache 清除本节点的缓存并通知其他节点
+func invalidateTokenCache(i *tokenInvalidation) {
+	if localTokenCache != nil {
+		localTokenCache.invalidate(i)
+	}
+	data, err := json.Marshal(i)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	go func() {
+		if e := cache.Publish(channelTokenInvalidated, data); e != nil && !errors.Is(e, cache.ErrDisabled) {
+			logger.Warn("发布token缓存清除通知失败: ", e)
+		}
+	}()
+}
+
+// handleTokenInvalidated 收到其他节点的通知后清除本地缓存
+func handleTokenInvalidated(data []byte) {
+	if localTokenCache == nil {
+		return
+	}
+	i := new(tokenInvalidation)
+	if err := json.Unmarshal(data, i); err != nil || (i.Username == "" && i.TokenHash == "") {
+		logger.Warn("无效的token缓存清除通知: ", string(data))
+		return
+	}
+	localTokenCache.invalidate(i)
+}
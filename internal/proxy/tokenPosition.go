@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bytes"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxTokenBodySize 从请求体中获取token时最多读取的长度
+const maxTokenBodySize = 1 << 20
+
+// tokenPosition token获取位置，格式为 来源:位置:名称，如 request:header:Authorization、response:cookies:sessionId
+type tokenPosition struct {
+	// request、response
+	source string
+	// header、query、body、cookies
+	where string
+	// 名称或json路径
+	name string
+}
+
+// parseTokenPositions 解析token位置，多个位置以逗号、分号或换行分隔，按顺序取第一个获取到的token，格式错误的忽略
+func parseTokenPositions(s string) (positions []tokenPosition) {
+	items := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r'
+	})
+	for _, item := range items {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+			continue
+		}
+		positions = append(positions, tokenPosition{
+			source: strings.ToLower(strings.TrimSpace(parts[0])),
+			where:  strings.ToLower(strings.TrimSpace(parts[1])),
+			name:   strings.TrimSpace(parts[2]),
+		})
+	}
+	return
+}
+
+// normalizeToken 去除首尾空白及Bearer前缀，保证请求和响应中获取的同一token一致
+func normalizeToken(token string) string {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
+
+// requestToken 从请求中获取token
+func requestToken(r *http.Request, positions []tokenPosition) string {
+	var body []byte
+	bodyRead := false
+	for _, p := range positions {
+		if p.source != "request" {
+			continue
+		}
+		token := ""
+		switch p.where {
+		case "header":
+			token = r.Header.Get(p.name)
+		case "query":
+			token = r.URL.Query().Get(p.name)
+		case "body":
+			if !bodyRead {
+				body = peekRequestBody(r)
+				bodyRead = true
+			}
+			// 判断req是否是form表单提交
+			if strings.Contains(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+				if values, err := url.ParseQuery(string(body)); err == nil {
+					token = values.Get(p.name)
+				}
+			} else {
+				token = gjson.GetBytes(body, p.name).String()
+			}
+		case "cookies":
+			if c, err := r.Cookie(p.name); err == nil {
+				token = c.Value
+			}
+		}
+		if token = normalizeToken(token); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// peekRequestBody 读取请求体的前maxTokenBodySize字节，读取后还原请求体，不影响转发到上游
+func peekRequestBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxTokenBodySize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body
+}
+
+// responseToken 从响应中获取token，cookies从Set-Cookie中获取，已删除的cookie忽略
+func responseToken(header http.Header, body []byte, positions []tokenPosition) string {
+	var cookies []*http.Cookie
+	for _, p := range positions {
+		if p.source != "response" {
+			continue
+		}
+		token := ""
+		switch p.where {
+		case "header":
+			token = header.Get(p.name)
+		case "body":
+			token = gjson.GetBytes(body, p.name).String()
+		case "cookies":
+			if cookies == nil {
+				cookies = (&http.Response{Header: header}).Cookies()
+			}
+			for _, c := range cookies {
+				if c.Name == p.name && c.MaxAge >= 0 && (c.Expires.IsZero() || c.Expires.After(time.Now())) {
+					token = c.Value
+					break
+				}
+			}
+		}
+		if token = normalizeToken(token); token != "" {
+			return token
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTokenPositions(t *testing.T) {
+	positions := parseTokenPositions("request:header:Authorization, response:body:data.token;\nresponse:cookies:sessionId,invalid,request:query:")
+	if len(positions) != 3 {
+		t.Fatalf("解析结果数量错误: %v", positions)
+	}
+	if p := positions[1]; p.source != "response" || p.where != "body" || p.name != "data.token" {
+		t.Errorf("解析结果错误: %v", p)
+	}
+}
+
+func TestRequestToken(t *testing.T) {
+	positions := parseTokenPositions("request:header:Authorization,request:body:auth.token")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer abc")
+	if token := requestToken(r, positions); token != "abc" {
+		t.Errorf("应去除Bearer前缀: %s", token)
+	}
+
+	// 请求头中没有时从请求体获取，请求体仍可完整读取
+	body := `{"auth":{"token":"def"}}`
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if token := requestToken(r, positions); token != "def" {
+		t.Errorf("从请求体获取token错误: %s", token)
+	}
+	if b, _ := io.ReadAll(r.Body); string(b) != body {
+		t.Errorf("请求体被修改: %s", b)
+	}
+}
+
+func TestResponseToken(t *testing.T) {
+	positions := parseTokenPositions("response:body:data.token,response:header:X-Token,response:cookies:sessionId")
+	header := http.Header{}
+	header.Add("Set-Cookie", "sessionId=; Max-Age=0")
+	header.Add("Set-Cookie", "other=1")
+	if token := responseToken(header, []byte(`{}`), positions); token != "" {
+		t.Errorf("已删除的cookie应忽略: %s", token)
+	}
+
+	header.Add("Set-Cookie", "sessionId=ghi; Path=/; HttpOnly")
+	if token := responseToken(header, []byte(`{}`), positions); token != "ghi" {
+		t.Errorf("从Set-Cookie获取token错误: %s", token)
+	}
+	header.Set("X-Token", "bearer jkl")
+	if token := responseToken(header, []byte(`{}`), positions); token != "jkl" {
+		t.Errorf("从响应头获取token错误: %s", token)
+	}
+	if token := responseToken(header, []byte(`{"data":{"token":"mno"}}`), positions); token != "mno" {
+		t.Errorf("从响应体获取token错误: %s", token)
+	}
+}
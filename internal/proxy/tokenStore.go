@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/pkg/cache"
+	"security-gateway/pkg/config"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TokenInfo token对应的密级和用户名
+type TokenInfo struct {
+	SecretLevel int
+	Username    string
+	// ExpireAt 固定的过期时间(unix秒)，如JWT或令牌内省结果中的exp，访问时不延长；为0时按存储的过期时间并在访问时延长
+	ExpireAt int64
+}
+
+// TokenStore 存储用户登录后token与密级、用户名的关系
+type TokenStore interface {
+	// Save 保存token的密级和用户名
+	Save(port uint16, domain, token string, info *TokenInfo) error
+	// Get 获取token的密级和用户名，未固定过期时间时刷新过期时间，不存在时返回nil
+	Get(port uint16, domain, token string) (info *TokenInfo, err error)
+	// UpdateUserLevel 修改用户在服务下所有token的密级
+	UpdateUserLevel(port uint16, domain, username string, secretLevel int) error
+	// UpdateUserAllLevel 修改用户在所有服务下token的密级
+	UpdateUserAllLevel(username string, secretLevel int) error
+	// Touch 批量刷新token的过期时间，只用于未固定过期时间的token
+	Touch(keys []TokenKey) error
+	// Cleanup 清理已过期的token，返回清理的数量
+	Cleanup() (purged int, err error)
+	// Delete 删除token，用户退出登录时使用
+	Delete(port uint16, domain, token string) error
+	// List 获取用户在所有服务下未过期的token
+	List(username string) ([]*TokenRecord, error)
+	// Revoke 删除用户的token，ids为TokenRecord.ID，为空时删除用户所有token，返回删除的数量
+	Revoke(username string, ids []string) (revoked int, err error)
+}
+
+// TokenRecord 用户的token，不返回token本身，以token的摘要作为ID
+type TokenRecord struct {
+	ID          string `json:"id"`          // token的SHA-256摘要
+	Port        uint16 `json:"port"`        // 端口
+	Domain      string `json:"domain"`      // 域名
+	Username    string `json:"username"`    // 用户名
+	SecretLevel int    `json:"secretLevel"` // 密级
+	ExpireTime  int64  `json:"expireTime"`  // 过期时间，毫秒
+	FixedExpire bool   `json:"fixedExpire"` // 过期时间是否固定
+}
+
+// revokeIds 要删除的token，为nil时表示全部
+func revokeIds(ids []string) map[string]struct{} {
+	if len(ids) == 0 {
+		return nil
+	}
+	m := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		m[id] = struct{}{}
+	}
+	return m
+}
+
+const (
+	TokenStoreRedis    = "redis"
+	TokenStoreMemory   = "memory"
+	TokenStoreDatabase = "database"
+)
+
+// tokenStore 当前使用的token存储，未初始化时使用内存存储
+var tokenStore TokenStore = newMemoryTokenStore(defaultTokenTTL, defaultTokenCapacity)
+var tokenStoreName = TokenStoreMemory
+
+// TokenCleanupResult token清理的统计
+type TokenCleanupResult struct {
+	Store       string `json:"store"`       // token存储
+	Time        int64  `json:"time"`        // 最近一次清理的开始时间
+	Duration    int64  `json:"duration"`    // 最近一次清理的耗时，毫秒
+	Purged      int    `json:"purged"`      // 最近一次清理的过期token数量
+	Error       string `json:"error"`       // 最近一次清理的错误
+	Runs        int64  `json:"runs"`        // 累计清理次数
+	Failures    int64  `json:"failures"`    // 累计失败次数
+	TotalPurged int64  `json:"totalPurged"` // 累计清理的过期token数量
+}
+
+var (
+	tokenCleanupMu   sync.Mutex
+	lastTokenCleanup atomic.Pointer[TokenCleanupResult]
+)
+
+const (
+	// defaultTokenTTL token默认过期时间3天(秒)
+	defaultTokenTTL = 60 * 60 * 24 * 3
+	// defaultTokenCapacity 内存存储默认最多保存的token数量
+	defaultTokenCapacity = 100000
+	// defaultTokenLocalTTL 本地缓存默认有效期(秒)
+	defaultTokenLocalTTL = 5
+	// defaultTokenLocalCapacity 本地缓存默认最多保存的token数量
+	defaultTokenLocalCapacity = 10000
+)
+
+// localTokenCache token存储之前的本地缓存，为nil时直接访问存储
+var localTokenCache *tokenCache
+
+// InitTokenStore 根据配置token.store选择token存储，多节点部署时应使用redis或database
+func InitTokenStore() error {
+	ttl := config.GetInt("token.ttl", defaultTokenTTL)
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	store := config.GetString("token.store", TokenStoreRedis)
+	switch store {
+	case TokenStoreRedis:
+		if !cache.Enabled() {
+			return errors.New("token存储使用redis，但未启用redis(redis.enabled)")
+		}
+		tokenStore = &redisTokenStore{ttl: ttl}
+	case TokenStoreMemory:
+		tokenStore = newMemoryTokenStore(ttl, config.GetInt("token.capacity", defaultTokenCapacity))
+	case TokenStoreDatabase:
+		tokenStore = &databaseTokenStore{ttl: ttl}
+	default:
+		return fmt.Errorf("不支持的token存储: %s", store)
+	}
+	tokenStoreName = store
+	logger.Infof("token存储: %s, 过期时间: %d秒", store, ttl)
+
+	// 内存存储无需本地缓存
+	localTTL := config.GetInt("token.localTTL", defaultTokenLocalTTL)
+	if store != TokenStoreMemory && localTTL > 0 {
+		localTokenCache = newTokenCache(time.Duration(localTTL)*time.Second, config.GetInt("token.localCapacity", defaultTokenLocalCapacity))
+		localTokenCache.start(tokenStore)
+		logger.Infof("token本地缓存有效期: %d秒", localTTL)
+	}
+	return nil
+}
+
+// CleanupTokens 清理已过期的token并累计统计，多次调用串行执行
+func CleanupTokens() (*TokenCleanupResult, error) {
+	tokenCleanupMu.Lock()
+	defer tokenCleanupMu.Unlock()
+
+	start := time.Now()
+	result := &TokenCleanupResult{Store: tokenStoreName, Time: start.UnixMilli()}
+	if last := lastTokenCleanup.Load(); last != nil {
+		result.Runs, result.Failures, result.TotalPurged = last.Runs, last.Failures, last.TotalPurged
+	}
+	purged, err := tokenStore.Cleanup()
+	result.Duration = time.Since(start).Milliseconds()
+	result.Purged = purged
+	result.Runs++
+	result.TotalPurged += int64(purged)
+	if err != nil {
+		result.Error = err.Error()
+		result.Failures++
+	}
+	lastTokenCleanup.Store(result)
+	if purged > 0 {
+		logger.Infof("清理过期token: %d个, 耗时%d毫秒, 累计%d个", purged, result.Duration, result.TotalPurged)
+	}
+	return result, err
+}
+
+// LastTokenCleanup 最近一次token清理的统计，未清理过时返回nil
+func LastTokenCleanup() *TokenCleanupResult {
+	return lastTokenCleanup.Load()
+}
+
+func cacheToken(port uint16, domain, token string, secretLevel int, username string) {
+	cacheTokenInfo(port, domain, token, &TokenInfo{SecretLevel: secretLevel, Username: username})
+}
+
+func cacheTokenInfo(port uint16, domain, token string, info *TokenInfo) {
+	if err := tokenStore.Save(port, domain, token, info); err != nil {
+		logger.Error(err)
+		return
+	}
+	if localTokenCache != nil {
+		localTokenCache.set(port, domain, token, info)
+	}
+}
+
+func getTokenSecretLevel(port uint16, domain, token string) (secretLevel int, username string) {
+	var info *TokenInfo
+	var err error
+	if localTokenCache != nil {
+		info, err = localTokenCache.get(tokenStore, port, domain, token)
+	} else {
+		info, err = tokenStore.Get(port, domain, token)
+	}
+	if err != nil {
+		logger.Error(err)
+	}
+	if info != nil {
+		secretLevel, username = info.SecretLevel, info.Username
+	}
+	return
+}
+
+// modifyTokenSecretLevel 修改用户在服务下所有token的密级，并清除各节点的本地缓存
+func modifyTokenSecretLevel(port uint16, domain, username string, secretLevel int) {
+	if err := tokenStore.UpdateUserLevel(port, domain, username, secretLevel); err != nil {
+		logger.Error(err)
+	}
+	invalidateTokenCache(&tokenInvalidation{Port: port, Domain: domain, Username: username})
+}
+
+// modifyUserAllSecretLevel 修改用户在所有服务下token的密级，并清除各节点的本地缓存
+func modifyUserAllSecretLevel(username string, secretLevel int) {
+	if err := tokenStore.UpdateUserAllLevel(username, secretLevel); err != nil {
+		logger.Error(err)
+	}
+	invalidateTokenCache(&tokenInvalidation{Username: username})
+}
+
+// deleteToken 删除token，并清除各节点中该token的本地缓存，用户名为空时同样通知其他节点
+func deleteToken(port uint16, domain, token string) {
+	if err := tokenStore.Delete(port, domain, token); err != nil {
+		logger.Error(err)
+		return
+	}
+	invalidateTokenCache(&tokenInvalidation{Port: port, Domain: domain, TokenHash: tokenHash(token)})
+}
+
+// ListUserTokens 获取用户在所有服务下未过期的token
+func ListUserTokens(username string) ([]*TokenRecord, error) {
+	return tokenStore.List(username)
+}
+
+// TokenStoreName 当前使用的token存储
+func TokenStoreName() string {
+	return tokenStoreName
+}
+
+// RevokeUserTokens 删除用户的token，ids为空时删除用户所有token，并清除各节点的本地缓存
+func RevokeUserTokens(username string, ids []string) (revoked int, err error) {
+	if revoked, err = tokenStore.Revoke(username, ids); err != nil {
+		logger.Error(err)
+	}
+	invalidateTokenCache(&tokenInvalidation{Username: username})
+	if revoked > 0 {
+		logger.Infof("撤销用户%s的token: %d个", username, revoked)
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"time"
+)
+
+// databaseTokenStore 使用数据库存储token，多节点共享，无需Redis，数据库中只保存token的摘要
+type databaseTokenStore struct {
+	ttl int
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *databaseTokenStore) expireTime() int64 {
+	return time.Now().Add(time.Duration(s.ttl) * time.Second).UnixMilli()
+}
+
+func (s *databaseTokenStore) Save(port uint16, domain, token string, info *TokenInfo) error {
+	instance := &model.Token{
+		Port:        port,
+		Domain:      domain,
+		TokenHash:   tokenHash(token),
+		Username:    info.Username,
+		SecretLevel: info.SecretLevel,
+		ExpireTime:  s.expireTime(),
+	}
+	if info.ExpireAt > 0 {
+		instance.ExpireTime = info.ExpireAt * 1000
+		instance.FixedExpire = true
+	}
+	return service.TokenService.Save(instance)
+}
+
+func (s *databaseTokenStore) Get(port uint16, domain, token string) (info *TokenInfo, err error) {
+	instance, err := service.TokenService.Get(port, domain, tokenHash(token), s.expireTime())
+	if err != nil || instance == nil {
+		return
+	}
+	info = &TokenInfo{SecretLevel: instance.SecretLevel, Username: instance.Username}
+	if instance.FixedExpire {
+		info.ExpireAt = instance.ExpireTime / 1000
+	}
+	return
+}
+
+func (s *databaseTokenStore) Touch(keys []TokenKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	hashes := make([]string, len(keys))
+	for i, k := range keys {
+		hashes[i] = tokenHash(k.Token)
+	}
+	return service.TokenService.Touch(hashes, s.expireTime())
+}
+
+func (s *databaseTokenStore) UpdateUserLevel(port uint16, domain, username string, secretLevel int) error {
+	return service.TokenService.UpdateUserLevel(port, domain, username, secretLevel)
+}
+
+func (s *databaseTokenStore) UpdateUserAllLevel(username string, secretLevel int) error {
+	return service.TokenService.UpdateUserAllLevel(username, secretLevel)
+}
+
+func (s *databaseTokenStore) Cleanup() (purged int, err error) {
+	count, err := service.TokenService.DeleteExpired()
+	return int(count), err
+}
+
+func (s *databaseTokenStore) Delete(port uint16, domain, token string) error {
+	return service.TokenService.Delete(port, domain, tokenHash(token))
+}
+
+func (s *databaseTokenStore) List(username string) (records []*TokenRecord, err error) {
+	tokens, err := service.TokenService.ListByUsername(username)
+	if err != nil {
+		return
+	}
+	for _, t := range tokens {
+		records = append(records, &TokenRecord{
+			ID:          t.TokenHash,
+			Port:        t.Port,
+			Domain:      t.Domain,
+			Username:    t.Username,
+			SecretLevel: t.SecretLevel,
+			ExpireTime:  t.ExpireTime,
+			FixedExpire: t.FixedExpire,
+		})
+	}
+	return
+}
+
+func (s *databaseTokenStore) Revoke(username string, ids []string) (revoked int, err error) {
+	count, err := service.TokenService.DeleteByUsername(username, ids)
+	return int(count), err
+}
@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryTokenStore 在进程内存储token，按过期时间和最近使用淘汰，仅适用于单节点部署
+type memoryTokenStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	// 最近使用的在前
+	lru    *list.List
+	tokens map[memoryTokenKey]*list.Element
+	// 端口、域名、用户名 -> token
+	users map[memoryTokenKey]map[string]struct{}
+}
+
+// memoryTokenKey 端口、域名和token(或用户名)
+type memoryTokenKey struct {
+	port   uint16
+	domain string
+	value  string
+}
+
+type memoryToken struct {
+	key         memoryTokenKey
+	secretLevel int
+	username    string
+	expireAt    time.Time
+	// 过期时间固定，访问时不延长
+	fixed bool
+}
+
+func newMemoryTokenStore(ttl, capacity int) *memoryTokenStore {
+	if capacity <= 0 {
+		capacity = defaultTokenCapacity
+	}
+	return &memoryTokenStore{
+		ttl:      time.Duration(ttl) * time.Second,
+		capacity: capacity,
+		lru:      list.New(),
+		tokens:   make(map[memoryTokenKey]*list.Element),
+		users:    make(map[memoryTokenKey]map[string]struct{}),
+	}
+}
+
+func (s *memoryTokenStore) Save(port uint16, domain, token string, info *TokenInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memoryTokenKey{port: port, domain: domain, value: token}
+	if e, ok := s.tokens[key]; ok {
+		s.remove(e)
+	}
+	t := &memoryToken{
+		key:         key,
+		secretLevel: info.SecretLevel,
+		username:    info.Username,
+		expireAt:    time.Now().Add(s.ttl),
+	}
+	if info.ExpireAt > 0 {
+		t.expireAt = time.Unix(info.ExpireAt, 0)
+		t.fixed = true
+	}
+	e := s.lru.PushFront(t)
+	s.tokens[key] = e
+	userKey := memoryTokenKey{port: port, domain: domain, value: info.Username}
+	if _, ok := s.users[userKey]; !ok {
+		s.users[userKey] = make(map[string]struct{})
+	}
+	s.users[userKey][token] = struct{}{}
+
+	// 超出容量时淘汰最久未使用的token
+	for s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) Get(port uint16, domain, token string) (info *TokenInfo, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.tokens[memoryTokenKey{port: port, domain: domain, value: token}]
+	if !ok {
+		return
+	}
+	t := e.Value.(*memoryToken)
+	now := time.Now()
+	if now.After(t.expireAt) {
+		s.remove(e)
+		return
+	}
+	s.lru.MoveToFront(e)
+	info = &TokenInfo{SecretLevel: t.secretLevel, Username: t.username}
+	if t.fixed {
+		info.ExpireAt = t.expireAt.Unix()
+	} else {
+		t.expireAt = now.Add(s.ttl)
+	}
+	return
+}
+
+func (s *memoryTokenStore) Touch(keys []TokenKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expireAt := time.Now().Add(s.ttl)
+	for _, k := range keys {
+		if e, ok := s.tokens[memoryTokenKey{port: k.Port, domain: k.Domain, value: k.Token}]; ok && !e.Value.(*memoryToken).fixed {
+			e.Value.(*memoryToken).expireAt = expireAt
+		}
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) UpdateUserLevel(port uint16, domain, username string, secretLevel int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateTokensLevel(memoryTokenKey{port: port, domain: domain, value: username}, secretLevel)
+	return nil
+}
+
+func (s *memoryTokenStore) UpdateUserAllLevel(username string, secretLevel int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userKey := range s.users {
+		if userKey.value == username {
+			s.updateTokensLevel(userKey, secretLevel)
+		}
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) updateTokensLevel(userKey memoryTokenKey, secretLevel int) {
+	for token := range s.users[userKey] {
+		if e, ok := s.tokens[memoryTokenKey{port: userKey.port, domain: userKey.domain, value: token}]; ok {
+			e.Value.(*memoryToken).secretLevel = secretLevel
+		}
+	}
+}
+
+func (s *memoryTokenStore) Cleanup() (purged int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for e := s.lru.Back(); e != nil; {
+		prev := e.Prev()
+		if now.After(e.Value.(*memoryToken).expireAt) {
+			s.remove(e)
+			purged++
+		}
+		e = prev
+	}
+	return
+}
+
+func (s *memoryTokenStore) Delete(port uint16, domain, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.tokens[memoryTokenKey{port: port, domain: domain, value: token}]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (s *memoryTokenStore) List(username string) (records []*TokenRecord, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eachUserToken(username, func(e *list.Element) {
+		t := e.Value.(*memoryToken)
+		records = append(records, &TokenRecord{
+			ID:          tokenHash(t.key.value),
+			Port:        t.key.port,
+			Domain:      t.key.domain,
+			Username:    t.username,
+			SecretLevel: t.secretLevel,
+			ExpireTime:  t.expireAt.UnixMilli(),
+			FixedExpire: t.fixed,
+		})
+	})
+	return
+}
+
+func (s *memoryTokenStore) Revoke(username string, ids []string) (revoked int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idSet := revokeIds(ids)
+	var elements []*list.Element
+	s.eachUserToken(username, func(e *list.Element) {
+		if idSet != nil {
+			if _, ok := idSet[tokenHash(e.Value.(*memoryToken).key.value)]; !ok {
+				return
+			}
+		}
+		elements = append(elements, e)
+	})
+	for _, e := range elements {
+		s.remove(e)
+	}
+	return len(elements), nil
+}
+
+// eachUserToken 遍历用户在所有服务下未过期的token，调用方需持有锁
+func (s *memoryTokenStore) eachUserToken(username string, fn func(e *list.Element)) {
+	now := time.Now()
+	for userKey, tokens := range s.users {
+		if userKey.value != username {
+			continue
+		}
+		for token := range tokens {
+			if e, ok := s.tokens[memoryTokenKey{port: userKey.port, domain: userKey.domain, value: token}]; ok && now.Before(e.Value.(*memoryToken).expireAt) {
+				fn(e)
+			}
+		}
+	}
+}
+
+// remove 移除token及用户索引，调用方需持有锁
+func (s *memoryTokenStore) remove(e *list.Element) {
+	t := s.lru.Remove(e).(*memoryToken)
+	delete(s.tokens, t.key)
+	userKey := memoryTokenKey{port: t.key.port, domain: t.key.domain, value: t.username}
+	if tokens, ok := s.users[userKey]; ok {
+		delete(tokens, t.key.value)
+		if len(tokens) == 0 {
+			delete(s.users, userKey)
+		}
+	}
+}
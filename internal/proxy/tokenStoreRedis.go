@@ -0,0 +1,542 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gomodule/redigo/redis"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/pkg/cache"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisKeyTokenToSecretLevel 保存token和密级关系, %d为端口号, %s为域名和token为key，密级为值
+var RedisKeyTokenToSecretLevel = cache.Prefix + ":token_to_secret_level:%d:%s:%s"
+
+// RedisKeyTokenToUsername 保存token和用户名关系, %d为端口号, %s为域名和token为key，用户名为值
+var RedisKeyTokenToUsername = cache.Prefix + ":token_to_username:%d:%s:%s"
+
+// RedisKeyUserTokens 保存用户名和token关系, %d为端口号, %s为编码后的域名和用户名为key，token列表为值。
+// 正则表达式域名中可能包含冒号，域名使用base64url编码，以便从key中解析端口、域名和用户名
+var RedisKeyUserTokens = cache.Prefix + ":user_tokens:%d:%s:%s"
+
+// RedisKeyUsernameToTokens 旧版本保存用户名和token关系的key，域名未编码，清理时迁移到RedisKeyUserTokens
+var RedisKeyUsernameToTokens = cache.Prefix + ":username_to_tokens:%d:%s:%s"
+
+// RedisKeyTokenExpireAt 保存token固定的过期时间, %d为端口号, %s为域名和token为key，过期时间(unix秒)为值，存在时访问不延长过期时间
+var RedisKeyTokenExpireAt = cache.Prefix + ":token_expire_at:%d:%s:%s"
+
+// RedisKeyUserServices 保存用户登录过的服务, %s为用户名为key，端口:域名列表为值，避免使用KEYS查找用户的token
+var RedisKeyUserServices = cache.Prefix + ":user_services:%s"
+
+// redisScanCount 每次SCAN的数量
+const redisScanCount = 1000
+
+// redisTokenStore 使用Redis存储token，多节点共享
+type redisTokenStore struct {
+	ttl int
+}
+
+// userTokensKey RedisKeyUserTokens格式化后的key
+func userTokensKey(port uint16, domain, username string) string {
+	return fmt.Sprintf(RedisKeyUserTokens, port, base64.RawURLEncoding.EncodeToString([]byte(domain)), username)
+}
+
+// parseUserTokensKey 从RedisKeyUserTokens中解析端口、域名和用户名
+func parseUserTokensKey(key string) (port uint16, domain, username string, ok bool) {
+	rest := strings.TrimPrefix(key, cache.Prefix+":user_tokens:")
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+	p, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || p == 0 || parts[2] == "" {
+		return
+	}
+	d, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	return uint16(p), string(d), parts[2], true
+}
+
+func closeConn(conn redis.Conn) {
+	err := conn.Close()
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func (s *redisTokenStore) Save(port uint16, domain, token string, info *TokenInfo) (err error) {
+	ttl := s.ttl
+	if info.ExpireAt > 0 {
+		if ttl = int(info.ExpireAt - time.Now().Unix()); ttl <= 0 {
+			return
+		}
+	}
+	// 索引的过期时间不短于token
+	indexTTL := s.ttl
+	if ttl > indexTTL {
+		indexTTL = ttl
+	}
+
+	conn := cache.Get()
+	defer closeConn(conn)
+	// 存储token和密级关系, RedisKeyTokenToSecretLevel格式化后为key，密级为value，并设定过期时间
+	_ = conn.Send("SETEX", fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token), ttl, info.SecretLevel)
+	// 存储token和用户名关系, RedisKeyTokenToUsername格式化后为key，用户名为value，并设定过期时间
+	_ = conn.Send("SETEX", fmt.Sprintf(RedisKeyTokenToUsername, port, domain, token), ttl, info.Username)
+	// 固定的过期时间
+	if info.ExpireAt > 0 {
+		_ = conn.Send("SETEX", fmt.Sprintf(RedisKeyTokenExpireAt, port, domain, token), ttl, info.ExpireAt)
+	} else {
+		_ = conn.Send("DEL", fmt.Sprintf(RedisKeyTokenExpireAt, port, domain, token))
+	}
+	sendUserIndex(conn, port, domain, info.Username, token, indexTTL)
+	_, err = conn.Do("")
+	return
+}
+
+// sendUserIndex 在pipeline中保存用户名和token关系及用户和服务关系
+func sendUserIndex(conn redis.Conn, port uint16, domain, username, token string, ttl int) {
+	// 存储用户名和token关系, RedisKeyUserTokens格式化后为key，token列表为value，set，并设定过期时间
+	_ = conn.Send("SADD", userTokensKey(port, domain, username), token)
+	_ = conn.Send("EXPIRE", userTokensKey(port, domain, username), ttl)
+	// 存储用户和服务关系，修改用户所有服务的密级时使用
+	_ = conn.Send("SADD", fmt.Sprintf(RedisKeyUserServices, username), fmt.Sprintf("%d:%s", port, domain))
+	_ = conn.Send("EXPIRE", fmt.Sprintf(RedisKeyUserServices, username), ttl)
+}
+
+func (s *redisTokenStore) Get(port uint16, domain, token string) (info *TokenInfo, err error) {
+	conn := cache.Get()
+	defer closeConn(conn)
+	// 获取token的密级、用户名及固定的过期时间，合并为一次请求
+	levelKey := fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token)
+	usernameKey := fmt.Sprintf(RedisKeyTokenToUsername, port, domain, token)
+	_ = conn.Send("GET", levelKey)
+	_ = conn.Send("GET", usernameKey)
+	_ = conn.Send("GET", fmt.Sprintf(RedisKeyTokenExpireAt, port, domain, token))
+	replies, err := redis.Values(conn.Do(""))
+	if err != nil {
+		return
+	}
+	secretLevel, err := redis.Int(replies[0], nil)
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			err = nil
+		}
+		return
+	}
+	username, _ := redis.String(replies[1], nil)
+	expireAt, _ := redis.Int64(replies[2], nil)
+	info = &TokenInfo{SecretLevel: secretLevel, Username: username, ExpireAt: expireAt}
+	if expireAt > 0 {
+		return
+	}
+
+	// 刷新过期时间
+	_ = conn.Send("EXPIRE", levelKey, s.ttl)
+	_ = conn.Send("EXPIRE", usernameKey, s.ttl)
+	_ = conn.Send("EXPIRE", userTokensKey(port, domain, username), s.ttl)
+	_ = conn.Send("EXPIRE", fmt.Sprintf(RedisKeyUserServices, username), s.ttl)
+	_, err = conn.Do("")
+	return
+}
+
+// Touch 使用pipeline批量刷新过期时间
+func (s *redisTokenStore) Touch(keys []TokenKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn := cache.Get()
+	defer closeConn(conn)
+	for _, k := range keys {
+		_ = conn.Send("EXPIRE", fmt.Sprintf(RedisKeyTokenToSecretLevel, k.Port, k.Domain, k.Token), s.ttl)
+		_ = conn.Send("EXPIRE", fmt.Sprintf(RedisKeyTokenToUsername, k.Port, k.Domain, k.Token), s.ttl)
+		_ = conn.Send("EXPIRE", userTokensKey(k.Port, k.Domain, k.Username), s.ttl)
+		_ = conn.Send("EXPIRE", fmt.Sprintf(RedisKeyUserServices, k.Username), s.ttl)
+	}
+	_, err := conn.Do("")
+	return err
+}
+
+func (s *redisTokenStore) UpdateUserLevel(port uint16, domain, username string, secretLevel int) error {
+	conn := cache.Get()
+	defer closeConn(conn)
+	return s.updateTokensLevel(conn, port, domain, username, secretLevel)
+}
+
+// updateTokensLevel 修改用户在服务下所有token的密级，同时移除已过期的token
+func (s *redisTokenStore) updateTokensLevel(conn redis.Conn, port uint16, domain, username string, secretLevel int) error {
+	// 取出所有token
+	tokens, err := redis.Strings(conn.Do("SMEMBERS", userTokensKey(port, domain, username)))
+	if err != nil {
+		return err
+	}
+
+	// 修改token和密级关系
+	for _, token := range tokens {
+		// 检查是否存在token和密级关系，保留原有的过期时间
+		var ttl int
+		ttl, err = redis.Int(conn.Do("TTL", fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token)))
+		if err != nil {
+			return err
+		}
+		if ttl == -2 {
+			// 不存在则删除RedisKeyUserTokens中的token
+			_, _ = conn.Do("SREM", userTokensKey(port, domain, username), token)
+			continue
+		}
+		if ttl <= 0 {
+			ttl = s.ttl
+		}
+
+		_, err = conn.Do("SETEX", fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token), ttl, secretLevel)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+	return nil
+}
+
+func (s *redisTokenStore) UpdateUserAllLevel(username string, secretLevel int) error {
+	conn := cache.Get()
+	defer closeConn(conn)
+
+	// 从RedisKeyUserServices中取出用户登录过的服务
+	services, err := redis.Strings(conn.Do("SMEMBERS", fmt.Sprintf(RedisKeyUserServices, username)))
+	if err != nil {
+		return err
+	}
+
+	// 修改token和密级关系
+	for _, member := range services {
+		port, domain, ok := parseUserServiceMember(member)
+		if !ok {
+			_, _ = conn.Do("SREM", fmt.Sprintf(RedisKeyUserServices, username), member)
+			continue
+		}
+		if err = s.updateTokensLevel(conn, port, domain, username, secretLevel); err != nil {
+			logger.Error(err)
+		}
+	}
+	return nil
+}
+
+func (s *redisTokenStore) Delete(port uint16, domain, token string) error {
+	conn := cache.Get()
+	defer closeConn(conn)
+	username, err := redis.String(conn.Do("GET", fmt.Sprintf(RedisKeyTokenToUsername, port, domain, token)))
+	if err != nil && !errors.Is(err, redis.ErrNil) {
+		return err
+	}
+	s.sendDelete(conn, port, domain, token, username)
+	_, err = conn.Do("")
+	return err
+}
+
+// sendDelete 在pipeline中删除token及用户索引中的token
+func (s *redisTokenStore) sendDelete(conn redis.Conn, port uint16, domain, token, username string) {
+	_ = conn.Send("DEL",
+		fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token),
+		fmt.Sprintf(RedisKeyTokenToUsername, port, domain, token),
+		fmt.Sprintf(RedisKeyTokenExpireAt, port, domain, token))
+	if username != "" {
+		_ = conn.Send("SREM", userTokensKey(port, domain, username), token)
+	}
+}
+
+// List 从RedisKeyUserServices及RedisKeyUserTokens中查找用户的token，批量获取密级及剩余过期时间
+func (s *redisTokenStore) List(username string) (records []*TokenRecord, err error) {
+	conn := cache.Get()
+	defer closeConn(conn)
+	err = s.eachUserToken(conn, username, func(port uint16, domain, token string, level, pttl, expireAt int64) {
+		var expireTime int64
+		if pttl > 0 {
+			expireTime = time.Now().UnixMilli() + pttl
+		}
+		records = append(records, &TokenRecord{
+			ID:          tokenHash(token),
+			Port:        port,
+			Domain:      domain,
+			Username:    username,
+			SecretLevel: int(level),
+			ExpireTime:  expireTime,
+			FixedExpire: expireAt > 0,
+		})
+	})
+	return
+}
+
+func (s *redisTokenStore) Revoke(username string, ids []string) (revoked int, err error) {
+	conn := cache.Get()
+	defer closeConn(conn)
+	idSet := revokeIds(ids)
+	type serviceToken struct {
+		port   uint16
+		domain string
+		token  string
+	}
+	var tokens []serviceToken
+	err = s.eachUserToken(conn, username, func(port uint16, domain, token string, _, _, _ int64) {
+		if idSet != nil {
+			if _, ok := idSet[tokenHash(token)]; !ok {
+				return
+			}
+		}
+		tokens = append(tokens, serviceToken{port: port, domain: domain, token: token})
+	})
+	if err != nil || len(tokens) == 0 {
+		return
+	}
+	for _, t := range tokens {
+		s.sendDelete(conn, t.port, t.domain, t.token, username)
+	}
+	if _, err = conn.Do(""); err != nil {
+		return
+	}
+	return len(tokens), nil
+}
+
+// eachUserToken 遍历用户在所有服务下未过期的token
+func (s *redisTokenStore) eachUserToken(conn redis.Conn, username string, fn func(port uint16, domain, token string, level, pttl, expireAt int64)) error {
+	services, err := redis.Strings(conn.Do("SMEMBERS", fmt.Sprintf(RedisKeyUserServices, username)))
+	if err != nil {
+		return err
+	}
+	for _, member := range services {
+		port, domain, ok := parseUserServiceMember(member)
+		if !ok {
+			continue
+		}
+		var tokens []string
+		if tokens, err = redis.Strings(conn.Do("SMEMBERS", userTokensKey(port, domain, username))); err != nil {
+			return err
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		// 批量获取密级、剩余过期时间及固定的过期时间
+		for _, token := range tokens {
+			levelKey := fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token)
+			_ = conn.Send("GET", levelKey)
+			_ = conn.Send("PTTL", levelKey)
+			_ = conn.Send("GET", fmt.Sprintf(RedisKeyTokenExpireAt, port, domain, token))
+		}
+		var replies []interface{}
+		if replies, err = redis.Values(conn.Do("")); err != nil {
+			return err
+		}
+		for i, token := range tokens {
+			if 3*i+2 >= len(replies) {
+				break
+			}
+			level, e := redis.Int64(replies[3*i], nil)
+			if e != nil {
+				// 已过期
+				continue
+			}
+			pttl, _ := redis.Int64(replies[3*i+1], nil)
+			expireAt, _ := redis.Int64(replies[3*i+2], nil)
+			fn(port, domain, token, level, pttl, expireAt)
+		}
+	}
+	return nil
+}
+
+// Cleanup 使用SCAN遍历RedisKeyUserTokens，清理其中已过期的token及RedisKeyUserServices中已失效的服务，token本身由Redis过期删除。
+// 旧版本的RedisKeyUsernameToTokens迁移到RedisKeyUserTokens
+func (s *redisTokenStore) Cleanup() (purged int, err error) {
+	conn := cache.Get()
+	defer closeConn(conn)
+
+	cursor := 0
+	for {
+		var keys []string
+		cursor, keys, err = scan(conn, cursor, cache.Prefix+":username_to_tokens:*")
+		if err != nil {
+			return
+		}
+		for _, key := range keys {
+			purged += s.migrateLegacyUserTokens(conn, key)
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for {
+		var keys []string
+		cursor, keys, err = scan(conn, cursor, cache.Prefix+":user_tokens:*")
+		if err != nil {
+			return
+		}
+		for _, key := range keys {
+			purged += s.cleanupUserTokens(conn, key)
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for {
+		var keys []string
+		cursor, keys, err = scan(conn, cursor, fmt.Sprintf(RedisKeyUserServices, "*"))
+		if err != nil {
+			return
+		}
+		for _, key := range keys {
+			s.cleanupUserServices(conn, key)
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	return
+}
+
+// migrateLegacyUserTokens 将旧版本RedisKeyUsernameToTokens中未过期的token迁移到RedisKeyUserTokens后删除，返回删除的已过期token数量
+func (s *redisTokenStore) migrateLegacyUserTokens(conn redis.Conn, key string) (purged int) {
+	portPart, rest, _ := strings.Cut(strings.TrimPrefix(key, cache.Prefix+":username_to_tokens:"), ":")
+	p, err := strconv.ParseUint(portPart, 10, 16)
+	if err != nil || p == 0 {
+		_, _ = conn.Do("DEL", key)
+		return
+	}
+	port := uint16(p)
+	tokens, err := redis.Strings(conn.Do("SMEMBERS", key))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	type owner struct {
+		domain, username, token string
+	}
+	var owners []owner
+	for _, token := range tokens {
+		domain, username, ok := legacyTokenOwner(conn, port, rest, token)
+		if !ok {
+			purged++
+			continue
+		}
+		owners = append(owners, owner{domain: domain, username: username, token: token})
+	}
+	for _, o := range owners {
+		sendUserIndex(conn, port, o.domain, o.username, o.token, s.ttl)
+	}
+	_ = conn.Send("DEL", key)
+	if _, err = conn.Do(""); err != nil {
+		logger.Error(err)
+	}
+	return
+}
+
+// legacyTokenOwner 旧版本key中域名和用户名都可能包含冒号，依次尝试以每个冒号分隔域名和用户名，
+// 与token保存的用户名一致时返回，token已过期时返回false
+func legacyTokenOwner(conn redis.Conn, port uint16, rest, token string) (domain, username string, ok bool) {
+	for i := 0; i < len(rest)-1; i++ {
+		if rest[i] != ':' {
+			continue
+		}
+		domain, username = rest[:i], rest[i+1:]
+		saved, err := redis.String(conn.Do("GET", fmt.Sprintf(RedisKeyTokenToUsername, port, domain, token)))
+		if err == nil && saved == username {
+			return domain, username, true
+		}
+	}
+	return "", "", false
+}
+
+// cleanupUserTokens 删除用户token集合中已过期的token，返回删除的数量
+func (s *redisTokenStore) cleanupUserTokens(conn redis.Conn, key string) (purged int) {
+	port, domain, username, ok := parseUserTokensKey(key)
+	if !ok {
+		// 删除无效key
+		_, _ = conn.Do("DEL", key)
+		return
+	}
+
+	// 取出所有token，批量检查是否存在
+	tokens, err := redis.Strings(conn.Do("SMEMBERS", key))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, token := range tokens {
+		_ = conn.Send("EXISTS", fmt.Sprintf(RedisKeyTokenToSecretLevel, port, domain, token))
+	}
+	exists, err := redis.Ints(conn.Do(""))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for i, token := range tokens {
+		if i < len(exists) && exists[i] == 0 {
+			_, _ = conn.Do("SREM", key, token)
+			purged++
+		}
+	}
+	// 补充用户和服务关系，兼容建立索引前保存的token
+	if len(tokens) > purged {
+		_, _ = conn.Do("SADD", fmt.Sprintf(RedisKeyUserServices, username), fmt.Sprintf("%d:%s", port, domain))
+		_, _ = conn.Do("EXPIRE", fmt.Sprintf(RedisKeyUserServices, username), s.ttl)
+	}
+	return
+}
+
+// cleanupUserServices 删除用户服务集合中已没有token的服务
+func (s *redisTokenStore) cleanupUserServices(conn redis.Conn, key string) {
+	username := strings.TrimPrefix(key, fmt.Sprintf(RedisKeyUserServices, ""))
+	services, err := redis.Strings(conn.Do("SMEMBERS", key))
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+	for _, member := range services {
+		port, domain, ok := parseUserServiceMember(member)
+		if ok {
+			var exists int
+			exists, err = redis.Int(conn.Do("EXISTS", userTokensKey(port, domain, username)))
+			if err != nil {
+				logger.Error(err)
+				continue
+			}
+			ok = exists > 0
+		}
+		if !ok {
+			_, _ = conn.Do("SREM", key, member)
+		}
+	}
+}
+
+// scan 使用SCAN分批查找key，不会像KEYS一样阻塞Redis
+func scan(conn redis.Conn, cursor int, match string) (next int, keys []string, err error) {
+	values, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", match, "COUNT", redisScanCount))
+	if err != nil {
+		return
+	}
+	if len(values) != 2 {
+		err = errors.New("SCAN返回格式错误")
+		return
+	}
+	if next, err = redis.Int(values[0], nil); err != nil {
+		return
+	}
+	keys, err = redis.Strings(values[1], nil)
+	return
+}
+
+// parseUserServiceMember 解析RedisKeyUserServices中的端口:域名
+func parseUserServiceMember(member string) (port uint16, domain string, ok bool) {
+	parts := strings.SplitN(member, ":", 2)
+	if len(parts) != 2 {
+		return
+	}
+	p, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || p == 0 {
+		return
+	}
+	return uint16(p), parts[1], true
+}
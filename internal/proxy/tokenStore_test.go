@@ -0,0 +1,260 @@
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func tokenLevel(info *TokenInfo, err error) (secretLevel int, username string, ok bool, e error) {
+	if info == nil {
+		return 0, "", false, err
+	}
+	return info.SecretLevel, info.Username, true, err
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	s := newMemoryTokenStore(60, 2)
+	_ = s.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = s.Save(8080, "a.example.com", "t2", &TokenInfo{SecretLevel: 2, Username: "bob"})
+
+	if level, username, ok, _ := tokenLevel(s.Get(8080, "a.example.com", "t1")); !ok || level != 2 || username != "alice" {
+		t.Fatalf("获取token错误: %d %s %v", level, username, ok)
+	}
+	if _, _, ok, _ := tokenLevel(s.Get(8081, "a.example.com", "t1")); ok {
+		t.Errorf("不同端口的token不应共享")
+	}
+
+	// t1刚被访问，超出容量时淘汰t2
+	_ = s.Save(8080, "a.example.com", "t3", &TokenInfo{SecretLevel: 1, Username: "alice"})
+	if _, _, ok, _ := tokenLevel(s.Get(8080, "a.example.com", "t2")); ok {
+		t.Errorf("最久未使用的token应被淘汰")
+	}
+
+	// 修改用户密级
+	_ = s.UpdateUserAllLevel("alice", 4)
+	for _, token := range []string{"t1", "t3"} {
+		if level, _, _, _ := tokenLevel(s.Get(8080, "a.example.com", token)); level != 4 {
+			t.Errorf("token %s 密级应为4, 实际: %d", token, level)
+		}
+	}
+	_ = s.UpdateUserLevel(8080, "a.example.com", "alice", 3)
+	if level, _, _, _ := tokenLevel(s.Get(8080, "a.example.com", "t1")); level != 3 {
+		t.Errorf("token密级应为3, 实际: %d", level)
+	}
+
+	// 固定过期时间的token访问时不延长
+	expireAt := time.Now().Add(time.Hour).Unix()
+	_ = s.Save(8080, "a.example.com", "t4", &TokenInfo{SecretLevel: 2, Username: "bob", ExpireAt: expireAt})
+	if info, _ := s.Get(8080, "a.example.com", "t4"); info == nil || info.ExpireAt != expireAt {
+		t.Errorf("固定的过期时间错误: %+v", info)
+	}
+
+	// 过期的token不返回，过期清理
+	s.ttl = -time.Second
+	_ = s.Save(8080, "a.example.com", "t2", &TokenInfo{SecretLevel: 1, Username: "bob"})
+	if _, _, ok, _ := tokenLevel(s.Get(8080, "a.example.com", "t2")); ok {
+		t.Errorf("过期的token不应返回")
+	}
+	_ = s.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 1, Username: "alice"})
+	_ = s.Save(8080, "a.example.com", "t3", &TokenInfo{SecretLevel: 1, Username: "alice"})
+	if purged, _ := s.Cleanup(); purged != 2 || len(s.tokens) != 0 || len(s.users) != 0 {
+		t.Errorf("清理过期token错误: %d %d %d", purged, len(s.tokens), len(s.users))
+	}
+}
+
+func TestMemoryTokenRevoke(t *testing.T) {
+	s := newMemoryTokenStore(60, 10)
+	_ = s.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = s.Save(8081, "b.example.com", "t2", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = s.Save(8080, "a.example.com", "t3", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = s.Save(8080, "a.example.com", "t4", &TokenInfo{SecretLevel: 2, Username: "bob"})
+
+	records, _ := s.List("alice")
+	if len(records) != 3 {
+		t.Fatalf("用户token数量错误: %d", len(records))
+	}
+
+	// 退出登录
+	_ = s.Delete(8080, "a.example.com", "t3")
+	if info, _ := s.Get(8080, "a.example.com", "t3"); info != nil {
+		t.Errorf("退出登录后token应删除")
+	}
+
+	// 按ID撤销
+	if revoked, _ := s.Revoke("alice", []string{tokenHash("t2")}); revoked != 1 {
+		t.Errorf("撤销数量错误: %d", revoked)
+	}
+	if info, _ := s.Get(8081, "b.example.com", "t2"); info != nil {
+		t.Errorf("撤销后token应删除")
+	}
+	// 撤销用户所有token
+	if revoked, _ := s.Revoke("alice", nil); revoked != 1 {
+		t.Errorf("撤销数量错误: %d", revoked)
+	}
+	if records, _ = s.List("bob"); len(records) != 1 || records[0].ID != tokenHash("t4") {
+		t.Errorf("不应撤销其他用户的token: %+v", records)
+	}
+}
+
+func TestDatabaseTokenStore(t *testing.T) {
+	initTestDatabase(t)
+	s := &databaseTokenStore{ttl: 60}
+	// 默认域名为空字符串，不能与其他域名的token混淆
+	_ = s.Save(8080, "", "t1", &TokenInfo{SecretLevel: 1, Username: "alice"})
+	_ = s.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 2, Username: "bob"})
+	if level, username, ok, _ := tokenLevel(s.Get(8080, "", "t1")); !ok || level != 1 || username != "alice" {
+		t.Fatalf("默认域名的token错误: %d %s %v", level, username, ok)
+	}
+	if level, username, ok, _ := tokenLevel(s.Get(8080, "a.example.com", "t1")); !ok || level != 2 || username != "bob" {
+		t.Fatalf("域名的token错误: %d %s %v", level, username, ok)
+	}
+
+	_ = s.Save(8080, "a.example.com", "t2", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = s.UpdateUserLevel(8080, "", "alice", 3)
+	if level, _, _, _ := tokenLevel(s.Get(8080, "", "t1")); level != 3 {
+		t.Errorf("默认域名的token密级应为3, 实际: %d", level)
+	}
+	if level, _, _, _ := tokenLevel(s.Get(8080, "a.example.com", "t2")); level != 2 {
+		t.Errorf("其他域名的token密级不应修改: %d", level)
+	}
+	// 用户名为空时不修改
+	_ = s.UpdateUserAllLevel("", 4)
+	if level, _, _, _ := tokenLevel(s.Get(8080, "a.example.com", "t1")); level != 2 {
+		t.Errorf("用户名为空时不应修改密级: %d", level)
+	}
+
+	_ = s.Delete(8080, "", "t1")
+	if info, _ := s.Get(8080, "", "t1"); info != nil {
+		t.Errorf("默认域名的token应删除")
+	}
+	if info, _ := s.Get(8080, "a.example.com", "t1"); info == nil {
+		t.Errorf("不应删除其他域名的token")
+	}
+}
+
+func TestLogoutSucceeded(t *testing.T) {
+	body := []byte(`{"code":0,"success":true}`)
+	cases := []struct {
+		check  string
+		status int
+		want   bool
+	}{
+		{"", 200, true},
+		{"", 302, true},
+		{"", 401, false},
+		{"code=0", 200, true},
+		{"code=1", 200, false},
+		{"success", 200, true},
+		{"data.ok", 200, false},
+	}
+	for _, c := range cases {
+		if got := logoutSucceeded(c.check, c.status, body); got != c.want {
+			t.Errorf("%q %d: 应为%v", c.check, c.status, c.want)
+		}
+	}
+}
+
+// countingTokenStore 记录访问存储的次数
+type countingTokenStore struct {
+	TokenStore
+	gets    int
+	touched int
+}
+
+func (s *countingTokenStore) Get(port uint16, domain, token string) (*TokenInfo, error) {
+	s.gets++
+	return s.TokenStore.Get(port, domain, token)
+}
+
+func (s *countingTokenStore) Touch(keys []TokenKey) error {
+	s.touched += len(keys)
+	return s.TokenStore.Touch(keys)
+}
+
+func TestTokenCache(t *testing.T) {
+	store := &countingTokenStore{TokenStore: newMemoryTokenStore(60, 10)}
+	_ = store.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	c := newTokenCache(time.Minute, 10)
+
+	for i := 0; i < 3; i++ {
+		if level, username, _, _ := tokenLevel(c.get(store, 8080, "a.example.com", "t1")); level != 2 || username != "alice" {
+			t.Fatalf("获取token错误: %d %s", level, username)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("缓存命中时不应访问存储, 实际访问%d次", store.gets)
+	}
+	c.flush(store)
+	if store.touched != 1 {
+		t.Errorf("命中的token应批量刷新过期时间, 实际: %d", store.touched)
+	}
+
+	// 修改密级后清除缓存
+	_ = store.UpdateUserAllLevel("alice", 4)
+	c.invalidate(&tokenInvalidation{Port: 8081, Domain: "a.example.com", Username: "alice"})
+	if level, _, _, _ := tokenLevel(c.get(store, 8080, "a.example.com", "t1")); level != 2 {
+		t.Errorf("其他服务的清除不应影响缓存, 实际: %d", level)
+	}
+	c.invalidate(&tokenInvalidation{Username: "alice"})
+	if level, _, _, _ := tokenLevel(c.get(store, 8080, "a.example.com", "t1")); level != 4 {
+		t.Errorf("清除缓存后应获取新的密级, 实际: %d", level)
+	}
+
+	// 其他节点删除没有用户名的token后，按token的摘要清除本节点的缓存
+	_ = store.Save(8080, "a.example.com", "t2", &TokenInfo{SecretLevel: 3})
+	if level, _, _, _ := tokenLevel(c.get(store, 8080, "a.example.com", "t2")); level != 3 {
+		t.Fatalf("获取token错误: %d", level)
+	}
+	_ = store.Delete(8080, "a.example.com", "t2")
+	old := localTokenCache
+	localTokenCache = c
+	defer func() { localTokenCache = old }()
+	handleTokenInvalidated([]byte(`{"port":8080,"domain":"a.example.com","tokenHash":"` + tokenHash("t2") + `"}`))
+	if info, _ := c.get(store, 8080, "a.example.com", "t2"); info != nil {
+		t.Errorf("已删除的token不应命中缓存: %+v", info)
+	}
+	gets := store.gets
+	if level, _, _, _ := tokenLevel(c.get(store, 8080, "a.example.com", "t1")); level != 4 || store.gets != gets {
+		t.Errorf("按token清除不应影响其他token的缓存")
+	}
+}
+
+func TestParseRedisTokenKeys(t *testing.T) {
+	// 正则表达式域名中包含冒号
+	for _, d := range []string{"a.example.com", "", "{^(?:a|b)\\.example\\.com$}"} {
+		port, domain, username, ok := parseUserTokensKey(userTokensKey(8080, d, "alice:1"))
+		if !ok || port != 8080 || domain != d || username != "alice:1" {
+			t.Errorf("解析用户token集合key错误: %d %s %s", port, domain, username)
+		}
+	}
+	if _, _, _, ok := parseUserTokensKey(userTokensKey(0, "a.example.com", "alice")); ok {
+		t.Errorf("端口为0的key应无效")
+	}
+	if _, _, _, ok := parseUserTokensKey(fmt.Sprintf(RedisKeyUserTokens, 8080, "a.example.com", "alice")); ok {
+		t.Errorf("域名未编码的key应无效")
+	}
+	port, domain, ok := parseUserServiceMember("8080:{^(?:a|b)\\.example\\.com$}")
+	if !ok || port != 8080 || domain != "{^(?:a|b)\\.example\\.com$}" {
+		t.Errorf("解析正则表达式域名的服务错误: %d %s", port, domain)
+	}
+	if port, domain, ok = parseUserServiceMember("8443:"); !ok || port != 8443 || domain != "" {
+		t.Errorf("解析默认服务错误: %d %s", port, domain)
+	}
+}
+
+func TestCleanupTokens(t *testing.T) {
+	store := newMemoryTokenStore(-1, 10)
+	tokenStore = store
+	defer func() { tokenStore = newMemoryTokenStore(defaultTokenTTL, defaultTokenCapacity) }()
+
+	_ = store.Save(8080, "a.example.com", "t1", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	_ = store.Save(8080, "a.example.com", "t2", &TokenInfo{SecretLevel: 2, Username: "alice"})
+	if result, err := CleanupTokens(); err != nil || result.Purged != 2 || result.Runs != 1 {
+		t.Errorf("清理结果错误: %+v %v", result, err)
+	}
+	_ = store.Save(8080, "a.example.com", "t3", &TokenInfo{SecretLevel: 2, Username: "bob"})
+	if result, _ := CleanupTokens(); result.Purged != 1 || result.TotalPurged != 3 || result.Runs != 2 {
+		t.Errorf("累计统计错误: %+v", result)
+	}
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/tjfoc/gmsm/gmtls"
+	gmx509 "github.com/tjfoc/gmsm/x509"
+	"net"
+	"net/http"
+	"security-gateway/internal/service"
+	"time"
+)
+
+// newUpstreamTLSConfig 生成连接上游使用的标准TLS配置
+func newUpstreamTLSConfig(option transportOption) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: option.InsecureSkipVerify,
+		ServerName:         option.ServerName,
+	}
+	if option.CaCertificateID != 0 {
+		ca, err := service.CertificateService.Get(option.CaCertificateID)
+		if err != nil {
+			return nil, fmt.Errorf("获取上游CA证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca.CertPem)) {
+			return nil, errors.New("上游CA证书无效")
+		}
+		config.RootCAs = pool
+	}
+	if option.ClientCertificateID != 0 {
+		cert, err := service.CertificateService.Get(option.ClientCertificateID)
+		if err != nil {
+			return nil, fmt.Errorf("获取客户端证书失败: %v", err)
+		}
+		clientCert, err := tls.X509KeyPair([]byte(cert.CertPem), []byte(cert.KeyPem))
+		if err != nil {
+			return nil, fmt.Errorf("客户端证书无效: %v", err)
+		}
+		config.Certificates = []tls.Certificate{clientCert}
+	}
+	return config, nil
+}
+
+// newUpstreamGMTLSConfig 生成连接上游使用的国密TLS配置，客户端证书使用签名证书和加密证书
+func newUpstreamGMTLSConfig(option transportOption) (*gmtls.Config, error) {
+	config := &gmtls.Config{
+		GMSupport:          gmtls.NewGMSupport(),
+		InsecureSkipVerify: option.InsecureSkipVerify,
+		ServerName:         option.ServerName,
+	}
+	if option.CaCertificateID != 0 {
+		ca, err := service.CertificateService.Get(option.CaCertificateID)
+		if err != nil {
+			return nil, fmt.Errorf("获取上游CA证书失败: %v", err)
+		}
+		pool := gmx509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(ca.CertPem)) {
+			return nil, errors.New("上游CA证书无效")
+		}
+		config.RootCAs = pool
+	}
+	if option.ClientCertificateID != 0 {
+		cert, err := service.CertificateService.Get(option.ClientCertificateID)
+		if err != nil {
+			return nil, fmt.Errorf("获取客户端证书失败: %v", err)
+		}
+		sigCert, err := gmtls.X509KeyPair([]byte(cert.SignCertPem), []byte(cert.SignKeyPem))
+		if err != nil {
+			return nil, fmt.Errorf("国密签名证书无效: %v", err)
+		}
+		encCert, err := gmtls.X509KeyPair([]byte(cert.EncCertPem), []byte(cert.EncKeyPem))
+		if err != nil {
+			return nil, fmt.Errorf("国密加密证书无效: %v", err)
+		}
+		config.Certificates = []gmtls.Certificate{sigCert, encCert}
+	}
+	return config, nil
+}
+
+// applyUpstreamTLS 为传输层设置上游TLS，国密模式下使用gmtls自行完成握手
+func applyUpstreamTLS(transport *http.Transport, dialer *net.Dialer, option transportOption) error {
+	if !option.GmMode {
+		config, err := newUpstreamTLSConfig(option)
+		if err != nil {
+			return err
+		}
+		transport.TLSClientConfig = config
+		return nil
+	}
+
+	config, err := newUpstreamGMTLSConfig(option)
+	if err != nil {
+		return err
+	}
+	handshakeTimeout := transport.TLSHandshakeTimeout
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialGMTLS(ctx, dialer, network, addr, config, handshakeTimeout)
+	}
+	return nil
+}
+
+// dialGMTLS 建立国密TLS连接，gmtls的握手不支持context，握手时设置ctx及handshakeTimeout中较早的截止时间，
+// ctx取消时关闭连接中断握手
+func dialGMTLS(ctx context.Context, dialer *net.Dialer, network, addr string, config *gmtls.Config, handshakeTimeout time.Duration) (net.Conn, error) {
+	rawConn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		if config.ServerName, _, err = net.SplitHostPort(addr); err != nil {
+			config.ServerName = addr
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	if handshakeTimeout > 0 {
+		if d := time.Now().Add(handshakeTimeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() {
+		_ = rawConn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	interrupted := make(chan error, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = rawConn.Close()
+			interrupted <- ctx.Err()
+		case <-done:
+			interrupted <- nil
+		}
+	}()
+
+	conn := gmtls.Client(rawConn, config)
+	err = conn.Handshake()
+	close(done)
+	// ctx取消导致的握手失败返回ctx的错误
+	if ctxErr := <-interrupted; ctxErr != nil {
+		err = ctxErr
+	}
+	if err != nil {
+		_ = rawConn.Close()
+		return nil, err
+	}
+	_ = rawConn.SetDeadline(time.Time{})
+	return conn, nil
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"errors"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"security-gateway/internal/model"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/jwt"
+	"security-gateway/pkg/oauth2"
+	"time"
+)
+
+// findUserSecretLevel 根据用户名和唯一标识查找用户及其在服务下的密级，matchKey为空时直接匹配User.UniKey。
+// 用户不存在时保存用户信息并返回nil，由管理员设置密级
+func findUserSecretLevel(uir *model.UserInfoRoute, username, uniKey, matchKey string) (user *model.User, secLevel int) {
+	if matchKey == "" {
+		user = service.UserService.GetByUniKey(username, uniKey)
+	} else {
+		user = service.UserService.GetByUniKeyJson(username, uniKey, matchKey)
+	}
+	if user == nil {
+		if username != "" {
+			// 保存用户信息
+			if _, _, err := service.UserService.Add(&model.User{
+				Username: username,
+				UniKey:   uniKey,
+			}); err != nil {
+				logger.Error("保存用户信息失败: ", err)
+			}
+		}
+		return
+	}
+
+	secLevel = user.SecLevel
+	// 还要看user在服务下的密级，如果存在，则以此为准
+	if usl := service.UserServiceLevelService.GetByUserAndServiceID(user.ID, uir.ServiceID); usl != nil {
+		secLevel = usl.SecLevel
+	}
+	return
+}
+
+// tokenResolver 根据token获取JSON格式的用户声明，JWT校验签名，令牌内省调用内省接口
+type tokenResolver struct {
+	resolve func(token string) (claims []byte, err error)
+	// reject 声明有效但没有对应的网关用户时调用，令牌内省将其按无效token缓存，避免频繁调用内省接口
+	reject func(token string)
+}
+
+// newTokenResolver 根据用户信息接口的token类型创建，普通token返回nil
+func newTokenResolver(uir *model.UserInfoRoute) (*tokenResolver, error) {
+	switch uir.TokenType {
+	case model.TokenTypeJwt:
+		v, err := jwt.NewVerifier(uir.JwtAlgorithms, uir.JwtKey, uir.JwksUrl)
+		if err != nil {
+			return nil, err
+		}
+		return &tokenResolver{resolve: v.Verify}, nil
+	case model.TokenTypeIntrospection:
+		timeout := time.Duration(config.GetInt("token.introspectionTimeout", 5000)) * time.Millisecond
+		i, err := oauth2.NewIntrospector(uir.IntrospectionUrl, uir.ClientId, uir.ClientSecret, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &tokenResolver{resolve: i.Introspect, reject: i.Reject}, nil
+	}
+	return nil, nil
+}
+
+// rejectToken 声明有效但没有对应的网关用户，令牌内省时与无效token一样短暂缓存
+func (r *tokenResolver) rejectToken(token string) {
+	if r.reject != nil {
+		r.reject(token)
+	}
+}
+
+// CheckUserInfoRoute 校验用户信息接口的JWT或令牌内省配置
+func CheckUserInfoRoute(uir *model.UserInfoRoute) error {
+	_, err := newTokenResolver(uir)
+	return err
+}
+
+// resolveToken 解析token得到用户声明，从中获取用户及密级后缓存token，之后的请求直接使用缓存。
+// 声明中的exp作为token在缓存中的过期时间
+func resolveToken(resolver *tokenResolver, uir *model.UserInfoRoute, port uint16, domain, token string) (secLevel int, username string) {
+	if resolver == nil || uir == nil {
+		return
+	}
+	claims, err := resolver.resolve(token)
+	if err != nil {
+		if errors.Is(err, oauth2.ErrInactive) || errors.Is(err, jwt.ErrMalformed) || errors.Is(err, jwt.ErrSignature) || errors.Is(err, jwt.ErrExpired) {
+			logger.Debug("token无效: ", err)
+		} else {
+			logger.Warn("解析token失败: ", err)
+		}
+		return
+	}
+	claimsJson := gjson.ParseBytes(claims)
+	uniKeyPath := uir.UniKeyPath
+	if uniKeyPath == "" {
+		uniKeyPath = "sub"
+	}
+	usernamePath := uir.UsernamePath
+	if usernamePath == "" {
+		usernamePath = "username"
+	}
+	uniKey := claimsJson.Get(uniKeyPath).String()
+	if uniKey == "" {
+		resolver.rejectToken(token)
+		return
+	}
+	username = claimsJson.Get(usernamePath).String()
+	matchKey := ""
+	if uir.MatchKey != "-" {
+		if matchKey = claimsJson.Get(uir.MatchKey).String(); matchKey == "" {
+			resolver.rejectToken(token)
+			return
+		}
+	}
+	user, secLevel := findUserSecretLevel(uir, username, uniKey, matchKey)
+	if user == nil {
+		resolver.rejectToken(token)
+		return
+	}
+	cacheTokenInfo(port, domain, token, &TokenInfo{
+		SecretLevel: secLevel,
+		Username:    user.Username,
+		ExpireAt:    claimsJson.Get("exp").Int(),
+	})
+	return secLevel, user.Username
+}
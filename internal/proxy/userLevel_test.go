@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveToken_Introspection(t *testing.T) {
+	initTestDatabase(t)
+	exp := time.Now().Add(time.Hour).Unix()
+	// 本地的令牌内省接口
+	var unknownRequests int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("token") == "unknown-user-token" {
+			atomic.AddInt32(&unknownRequests, 1)
+			_, _ = fmt.Fprintf(w, `{"active":true,"sub":"u-2","username":"bob","exp":%d}`, exp)
+			return
+		}
+		if r.PostFormValue("token") != "opaque-token" {
+			_, _ = fmt.Fprint(w, `{"active":false}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"active":true,"sub":"u-1","username":"alice","exp":%d}`, exp)
+	}))
+	defer idp.Close()
+
+	user := &model.User{ID: 100, Username: "alice", UniKey: "u-1", SecLevel: 3}
+	if err := database.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	uir := &model.UserInfoRoute{ServiceID: 1, MatchKey: "-", TokenType: model.TokenTypeIntrospection, IntrospectionUrl: idp.URL}
+	resolver, err := newTokenResolver(uir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	level, username := resolveToken(resolver, uir, 8080, "a.example.com", "Bearer opaque-token")
+	if level != 3 || username != "alice" {
+		t.Fatalf("解析token错误: %d %s", level, username)
+	}
+	// 结果缓存在token存储中，过期时间为exp
+	info, _ := tokenStore.Get(8080, "a.example.com", "Bearer opaque-token")
+	if info == nil || info.SecretLevel != 3 || info.ExpireAt != exp {
+		t.Errorf("token缓存错误: %+v", info)
+	}
+
+	if level, _ = resolveToken(resolver, uir, 8080, "a.example.com", "other-token"); level != 0 {
+		t.Errorf("无效token不应获取密级: %d", level)
+	}
+
+	// 没有对应网关用户的token与无效token一样缓存，不重复调用内省接口
+	for i := 0; i < 2; i++ {
+		if level, _ = resolveToken(resolver, uir, 8080, "a.example.com", "unknown-user-token"); level != 0 {
+			t.Errorf("没有对应用户的token不应获取密级: %d", level)
+		}
+	}
+	if n := atomic.LoadInt32(&unknownRequests); n != 1 {
+		t.Errorf("内省接口调用次数应为1，实际为%d", n)
+	}
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"errors"
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/util"
+	"time"
+)
+
+var AdminService = &adminService{}
+
+type adminService struct{}
+
+// ErrLastSystemAdmin 不能删除、禁用最后一个可用的系统管理员或修改其角色
+var ErrLastSystemAdmin = errors.New("至少需要保留一个可用的系统管理员")
+
+// Count 管理员数量
+func (u *adminService) Count() (count int64, err error) {
+	if err = database.DB.Model(&model.Admin{}).Count(&count).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Add 新增管理员，instance.Password为密码摘要
+func (u *adminService) Add(instance *model.Admin) (duplicated, success bool, err error) {
+	if instance.Username == "" || instance.Password == "" || !model.ValidAdminRole(instance.Role) {
+		return
+	}
+	var c int64
+	err = database.DB.Model(&model.Admin{}).Where(&model.Admin{Username: instance.Username}).Count(&c).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if c > 0 {
+		duplicated = true
+		return
+	}
+
+	instance.ID = util.SnowflakeId()
+	instance.PasswordTime = time.Now().UnixMilli()
+	if err = database.DB.Create(instance).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	success = true
+	return
+}
+
+// Update 修改管理员的姓名、角色及是否禁用，用户名和密码不在此修改
+func (u *adminService) Update(instance *model.Admin) (success bool, err error) {
+	if instance.ID == 0 || !model.ValidAdminRole(instance.Role) {
+		logger.Error("ID is required")
+		return
+	}
+	old, err := u.Get(instance.ID)
+	if err != nil {
+		return
+	}
+	if old.Role == model.AdminRoleSystem && !old.Disabled && (instance.Role != model.AdminRoleSystem || instance.Disabled) {
+		if err = u.checkLastSystemAdmin(instance.ID); err != nil {
+			return
+		}
+	}
+	err = database.DB.Model(&model.Admin{ID: instance.ID}).Updates(map[string]interface{}{
+		"name":     instance.Name,
+		"role":     instance.Role,
+		"disabled": instance.Disabled,
+	}).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	success = true
+	return
+}
+
+// UpdatePassword 修改密码，password为密码摘要，同时解除锁定，之前的登录失效
+func (u *adminService) UpdatePassword(id uint64, password string) (success bool, err error) {
+	if id == 0 || password == "" {
+		return
+	}
+	err = database.DB.Model(&model.Admin{ID: id}).Updates(map[string]interface{}{
+		"password":       password,
+		"password_time":  time.Now().UnixMilli(),
+		"login_failures": 0,
+		"locked_until":   0,
+	}).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	success = true
+	return
+}
+
+func (u *adminService) Delete(id uint64) (success bool, err error) {
+	if id == 0 {
+		logger.Error("ID is required")
+		return
+	}
+	old, err := u.Get(id)
+	if err != nil {
+		return
+	}
+	if old.Role == model.AdminRoleSystem && !old.Disabled {
+		if err = u.checkLastSystemAdmin(id); err != nil {
+			return
+		}
+	}
+	if err = database.DB.Delete(&model.Admin{ID: id}).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	success = true
+	return
+}
+
+// checkLastSystemAdmin 除id外是否还有可用的系统管理员
+func (u *adminService) checkLastSystemAdmin(id uint64) (err error) {
+	var c int64
+	err = database.DB.Model(&model.Admin{}).Where(&model.Admin{Role: model.AdminRoleSystem}).
+		Where("disabled = ?", false).Where("id <> ?", id).Count(&c).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if c == 0 {
+		err = ErrLastSystemAdmin
+	}
+	return
+}
+
+func (u *adminService) Get(id uint64) (instance *model.Admin, err error) {
+	if id == 0 {
+		logger.Error("ID is required")
+		return nil, errors.New("ID is required")
+	}
+	instance = new(model.Admin)
+	if err = database.DB.Where(&model.Admin{ID: id}).First(instance).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	return
+}
+
+// GetByUsername 根据用户名获取管理员，不存在时返回nil
+func (u *adminService) GetByUsername(username string) (instance *model.Admin, err error) {
+	if username == "" {
+		return
+	}
+	instance = new(model.Admin)
+	if err = database.DB.Where(&model.Admin{Username: username}).Limit(1).Find(instance).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if instance.ID == 0 {
+		instance = nil
+	}
+	return
+}
+
+func (u *adminService) List(page, pageSize int, condition *model.Admin) (instances []*model.Admin, total int64, err error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	sess := database.DB.Model(&model.Admin{})
+	if condition.Username != "" {
+		sess = sess.Where("username like ?", "%"+condition.Username+"%")
+	}
+	if condition.Role != "" {
+		sess = sess.Where(&model.Admin{Role: condition.Role})
+	}
+
+	err = sess.Count(&total).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if total == 0 {
+		return
+	}
+	err = sess.Order("create_time").Offset((page - 1) * pageSize).Limit(pageSize).Find(&instances).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	return
+}
+
+// LoginFailed 记录登录失败，lockedUntil不为0时锁定至该时间
+func (u *adminService) LoginFailed(id uint64, failures int, lockedUntil int64) {
+	err := database.DB.Model(&model.Admin{ID: id}).Updates(map[string]interface{}{
+		"login_failures": failures,
+		"locked_until":   lockedUntil,
+	}).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+}
+
+// LoginSucceeded 记录登录成功，清除失败次数
+func (u *adminService) LoginSucceeded(id uint64, ip string) {
+	err := database.DB.Model(&model.Admin{ID: id}).Updates(map[string]interface{}{
+		"login_failures":  0,
+		"locked_until":    0,
+		"last_login_time": time.Now().UnixMilli(),
+		"last_login_ip":   ip,
+	}).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+}
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"encoding/hex"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tjfoc/gmsm/sm3"
+	"gorm.io/gorm"
+	"hash"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"security-gateway/pkg/util"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var AuditLogService = &auditLogService{}
+
+type auditLogService struct {
+	// 本节点内串行写入，多节点同时写入时依靠Seq唯一索引冲突后重试
+	mu sync.Mutex
+}
+
+// auditAppendRetries 写入时Seq冲突(其他节点已写入同一序号)的重试次数
+const auditAppendRetries = 5
+
+// auditExportLimit 单次导出的最大条数
+const auditExportLimit = 100000
+
+// auditKeyPurpose 从主密钥派生审计日志摘要密钥的用途
+const auditKeyPurpose = "audit-log"
+
+// AuditVerifyResult 哈希链校验结果，Valid为false时BrokenSeq为第一条校验失败的序号
+type AuditVerifyResult struct {
+	Valid bool  `json:"valid"`
+	Total int64 `json:"total"`
+	// Unkeyed 未配置主密钥时写入的日志数量，只有SM3摘要，可以被有数据库写权限的人重新计算
+	Unkeyed   int64  `json:"unkeyed"`
+	LastSeq   uint64 `json:"lastSeq"`
+	LastHash  string `json:"lastHash"`
+	BrokenSeq uint64 `json:"brokenSeq,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// AuditHash 计算审计日志的摘要，各字段加长度前缀后拼接，避免字段边界被篡改。
+// key不为空时为HMAC-SM3，没有主密钥无法重新计算；为空时为SM3摘要，兼容未配置主密钥时写入的日志
+func AuditHash(l *model.AuditLog, key []byte) string {
+	var h hash.Hash
+	if len(key) > 0 {
+		h = hmac.New(sm3.New, key)
+	} else {
+		h = sm3.New()
+	}
+	fields := []string{
+		strconv.FormatUint(l.Seq, 10),
+		strconv.FormatInt(l.Time, 10),
+		l.Actor,
+		l.ActorRole,
+		l.Ip,
+		l.Action,
+		l.Resource,
+		strconv.FormatUint(l.ResourceID, 10),
+		l.Before,
+		l.After,
+		l.PrevHash,
+	}
+	var size [8]byte
+	for _, f := range fields {
+		binary.BigEndian.PutUint64(size[:], uint64(len(f)))
+		h.Write(size[:])
+		h.Write([]byte(f))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Append 追加一条审计日志，自动设置序号、时间、上一条摘要及本条摘要
+func (s *auditLogService) Append(instance *model.AuditLog) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if instance.Time == 0 {
+		instance.Time = time.Now().UnixMilli()
+	}
+	var key []byte
+	instance.KeyID, key = secret.DeriveKey(auditKeyPurpose)
+	for i := 0; i < auditAppendRetries; i++ {
+		var last *model.AuditLog
+		if last, err = s.last(); err != nil {
+			return
+		}
+		instance.Seq, instance.PrevHash = 1, ""
+		if last != nil {
+			instance.Seq, instance.PrevHash = last.Seq+1, last.Hash
+		}
+		instance.ID = util.SnowflakeId()
+		instance.Hash = AuditHash(instance, key)
+		if err = database.DB.Create(instance).Error; err == nil {
+			return
+		}
+		logger.Warnf("写入审计日志失败，序号%d，重试: %v", instance.Seq, err)
+	}
+	logger.Errorln(err)
+	return
+}
+
+// last 序号最大的审计日志，没有时返回nil
+func (s *auditLogService) last() (instance *model.AuditLog, err error) {
+	instance = new(model.AuditLog)
+	if err = database.DB.Order("seq desc").Limit(1).Find(instance).Error; err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	if instance.ID == 0 {
+		instance = nil
+	}
+	return
+}
+
+func (s *auditLogService) query(condition *model.AuditLog, startTime, endTime int64) *gorm.DB {
+	sess := database.DB.Model(&model.AuditLog{})
+	if condition.Actor != "" {
+		sess = sess.Where(&model.AuditLog{Actor: condition.Actor})
+	}
+	if condition.Action != "" {
+		sess = sess.Where(&model.AuditLog{Action: condition.Action})
+	}
+	if condition.Resource != "" {
+		sess = sess.Where(&model.AuditLog{Resource: condition.Resource})
+	}
+	if condition.ResourceID != 0 {
+		sess = sess.Where(&model.AuditLog{ResourceID: condition.ResourceID})
+	}
+	if startTime > 0 {
+		sess = sess.Where("time >= ?", startTime)
+	}
+	if endTime > 0 {
+		sess = sess.Where("time <= ?", endTime)
+	}
+	return sess
+}
+
+// List 按条件分页查询审计日志，按序号倒序
+func (s *auditLogService) List(page, pageSize int, condition *model.AuditLog, startTime, endTime int64) (instances []*model.AuditLog, total int64, err error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	sess := s.query(condition, startTime, endTime)
+	if err = sess.Count(&total).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if total == 0 {
+		return
+	}
+	err = sess.Order("seq desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&instances).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Export 按条件导出审计日志，按序号正序，最多auditExportLimit条
+func (s *auditLogService) Export(condition *model.AuditLog, startTime, endTime int64) (instances []*model.AuditLog, err error) {
+	err = s.query(condition, startTime, endTime).Order("seq").Limit(auditExportLimit).Find(&instances).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Verify 按序号校验整条哈希链：序号连续、上一条摘要一致、本条摘要与内容一致。
+// 使用主密钥的日志之后不能再出现SM3摘要的日志，防止篡改后降级为SM3摘要重新计算
+func (s *auditLogService) Verify() (result *AuditVerifyResult, err error) {
+	result = &AuditVerifyResult{Valid: true}
+	keys := make(map[string][]byte)
+	keyed := false
+	for {
+		var batch []*model.AuditLog
+		err = database.DB.Where("seq > ?", result.LastSeq).Order("seq").Limit(1000).Find(&batch).Error
+		if err != nil {
+			logger.Errorln(err)
+			return
+		}
+		for _, l := range batch {
+			var key []byte
+			if l.KeyID != "" {
+				if _, ok := keys[l.KeyID]; !ok {
+					keys[l.KeyID] = secret.DeriveKeyByID(l.KeyID, auditKeyPurpose)
+				}
+				key = keys[l.KeyID]
+			}
+			reason := ""
+			switch {
+			case l.Seq != result.LastSeq+1:
+				reason = "序号不连续，缺少序号" + strconv.FormatUint(result.LastSeq+1, 10)
+			case l.PrevHash != result.LastHash:
+				reason = "上一条日志的摘要不一致"
+			case l.KeyID != "" && key == nil:
+				reason = "主密钥" + l.KeyID + "不存在，无法校验"
+			case l.KeyID == "" && keyed:
+				reason = "使用主密钥的日志之后出现SM3摘要的日志"
+			case AuditHash(l, key) != l.Hash:
+				reason = "日志内容与摘要不一致"
+			}
+			if reason != "" {
+				result.Valid, result.BrokenSeq, result.Reason = false, l.Seq, reason
+				return
+			}
+			if l.KeyID != "" {
+				keyed = true
+			} else {
+				result.Unkeyed++
+			}
+			result.Total++
+			result.LastSeq, result.LastHash = l.Seq, l.Hash
+		}
+		if len(batch) < 1000 {
+			return
+		}
+	}
+}
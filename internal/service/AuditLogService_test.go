@@ -0,0 +1,55 @@
+package service
+
+import (
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"testing"
+)
+
+func TestAuditLogKeyedChain(t *testing.T) {
+	initTestDatabase(t)
+	t.Cleanup(func() { _ = secret.Initial() })
+
+	// 配置主密钥前写入的日志为SM3摘要
+	if err := AuditLogService.Append(&model.AuditLog{Actor: "sysadmin", Action: model.AuditActionCreate, Resource: "service"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SG_MASTER_KEY", "k1:000102030405060708090a0b0c0d0e0f")
+	if err := secret.Initial(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := AuditLogService.Append(&model.AuditLog{Actor: "sysadmin", Action: model.AuditActionUpdate, Resource: "service"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := AuditLogService.Verify()
+	if err != nil || !result.Valid || result.Total != 3 || result.Unkeyed != 1 {
+		t.Fatalf("哈希链校验失败: %+v, %v", result, err)
+	}
+
+	// 没有主密钥时篡改内容并重新计算SM3摘要
+	l := new(model.AuditLog)
+	database.DB.Where("seq = ?", 3).First(l)
+	l.After, l.KeyID = `{"name":"changed"}`, ""
+	l.Hash = AuditHash(l, nil)
+	database.DB.Save(l)
+	if result, _ = AuditLogService.Verify(); result.Valid || result.BrokenSeq != 3 {
+		t.Errorf("降级为SM3摘要的篡改未被发现: %+v", result)
+	}
+	l.KeyID = "k1"
+	database.DB.Save(l)
+	if result, _ = AuditLogService.Verify(); result.Valid || result.BrokenSeq != 3 {
+		t.Errorf("篡改内容未被发现: %+v", result)
+	}
+
+	// 主密钥移除后无法校验
+	t.Setenv("SG_MASTER_KEY", "k2:101112131415161718191a1b1c1d1e1f")
+	if err = secret.Initial(); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ = AuditLogService.Verify(); result.Valid || result.BrokenSeq != 2 {
+		t.Errorf("缺少主密钥时应校验失败: %+v", result)
+	}
+}
@@ -1,12 +1,18 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	logger "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"security-gateway/internal/model"
+	"security-gateway/pkg/config"
 	"security-gateway/pkg/database"
 	"security-gateway/pkg/util"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var CertificateService = &certificateService{}
@@ -14,7 +20,14 @@ var CertificateService = &certificateService{}
 type certificateService struct{}
 
 func (u *certificateService) Add(instance *model.Certificate) (duplicated, success bool, err error) {
-	if instance.CertName == "" || instance.ServeDomain == "" || instance.CertPem == "" || instance.KeyPem == "" {
+	if instance.CertType == 0 {
+		instance.CertType = model.CertificateTypeServer
+	}
+	if instance.CertName == "" || instance.CertPem == "" {
+		return
+	}
+	// CA证书只用于校验上游，不需要私钥和服务域名
+	if instance.CertType != model.CertificateTypeCA && (instance.ServeDomain == "" || instance.KeyPem == "") {
 		return
 	}
 	// 检查是否有重复
@@ -55,6 +68,15 @@ func (u *certificateService) Update(instance *model.Certificate) (duplicated, su
 		return
 	}
 
+	// 过期时间变化说明证书已更换，重新发送到期提醒
+	if instance.NotAfter != 0 {
+		if err = database.DB.Model(&model.Certificate{}).Where("id = ? AND not_after <> ?", instance.ID, instance.NotAfter).
+			Update("expiry_warned", -1).Error; err != nil {
+			logger.Errorln(err)
+			return
+		}
+	}
+	// 私钥为空时不修改，保留原有的私钥
 	if err = database.DB.Model(&model.Certificate{ID: instance.ID}).Updates(instance).Error; err != nil {
 		logger.Errorln(err)
 		return
@@ -123,7 +145,7 @@ func (u *certificateService) List(page, pageSize int, condition *model.Certifica
 	if total == 0 {
 		return
 	}
-	err = sess.Omit("cert_pem,key_pem").
+	err = sess.Omit("cert_pem,key_pem,sign_key_pem,enc_key_pem").
 		Offset((page - 1) * pageSize).Limit(pageSize).Find(&instances).Error
 	if err != nil {
 		logger.Errorln(err)
@@ -145,7 +167,7 @@ func (u *certificateService) ListByDomain(domain string) (list []*model.Certific
 	// 2. 查找*的证书, 必须是domain除去第一个.之前的部分加上*
 	fanDomain := "*." + domain[strings.Index(domain, ".")+1:]
 
-	sess := database.DB.Model(&model.Certificate{}).Omit("cert_pem,key_pem").
+	sess := database.DB.Model(&model.Certificate{}).Omit("cert_pem,key_pem,sign_key_pem,enc_key_pem").
 		Where("serve_domain in (?)", []string{domain, fanDomain})
 
 	if err = sess.Find(&list).Error; err != nil {
@@ -194,3 +216,210 @@ func (u *certificateService) DeleteServiceCertificate(id uint64) (success bool,
 	success = err == nil
 	return
 }
+
+// GetServiceCertificate 获取服务与证书的关联
+func (u *certificateService) GetServiceCertificate(id uint64) (instance *model.ServiceCertificate, err error) {
+	if id == 0 {
+		logger.Error("ID is required")
+		return
+	}
+	instance = new(model.ServiceCertificate)
+	if err = database.DB.Where(&model.ServiceCertificate{ID: id}).First(instance).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	return
+}
+
+// ListAll 获取全部证书，用于从数据库同步反向代理配置
+func (u *certificateService) ListAll() (instances []*model.Certificate, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Parse 解析证书并检查私钥是否匹配，填充证书信息，RSA/ECDSA及国密SM2证书均使用gmsm解析；CA证书只需要证书内容
+func (u *certificateService) Parse(instance *model.Certificate) error {
+	if instance.CertType == model.CertificateTypeCA && instance.CertPem == "" {
+		return errors.New("CA证书内容不能为空")
+	}
+	meta, err := certificateMeta(instance)
+	if err != nil {
+		return err
+	}
+	if instance.CertType != model.CertificateTypeCA {
+		if instance.CertPem != "" || instance.KeyPem != "" {
+			if instance.CertPem == "" || instance.KeyPem == "" {
+				return errors.New("证书及私钥需同时填写")
+			}
+			if err = util.CheckKeyPair([]byte(instance.CertPem), []byte(instance.KeyPem)); err != nil {
+				return fmt.Errorf("证书与私钥不匹配: %v", err)
+			}
+		}
+		if instance.SignCertPem != "" || instance.SignKeyPem != "" || instance.EncCertPem != "" || instance.EncKeyPem != "" {
+			if instance.SignCertPem == "" || instance.SignKeyPem == "" || instance.EncCertPem == "" || instance.EncKeyPem == "" {
+				return errors.New("国密签名证书、签名私钥、加密证书及加密私钥需同时填写")
+			}
+			if err = util.CheckKeyPair([]byte(instance.SignCertPem), []byte(instance.SignKeyPem)); err != nil {
+				return fmt.Errorf("国密签名证书与私钥不匹配: %v", err)
+			}
+			if err = util.CheckKeyPair([]byte(instance.EncCertPem), []byte(instance.EncKeyPem)); err != nil {
+				return fmt.Errorf("国密加密证书与私钥不匹配: %v", err)
+			}
+		}
+	}
+	instance.CertificateMeta = *meta
+	return nil
+}
+
+// certificateMeta 解析证书信息，取第一个证书，过期时间取全部证书及证书链中最早的
+func certificateMeta(instance *model.Certificate) (meta *model.CertificateMeta, err error) {
+	for _, part := range []struct {
+		name string
+		pem  string
+	}{
+		{"证书", instance.CertPem},
+		{"国密签名证书", instance.SignCertPem},
+		{"国密加密证书", instance.EncCertPem},
+	} {
+		if part.pem == "" {
+			continue
+		}
+		infos, e := util.ParseCertificatePem([]byte(part.pem))
+		if e != nil {
+			return nil, fmt.Errorf("%s解析失败: %v", part.name, e)
+		}
+		if meta == nil {
+			leaf := infos[0]
+			meta = &model.CertificateMeta{
+				Subject:      limitLength(leaf.Subject, 500),
+				Issuer:       limitLength(leaf.Issuer, 500),
+				DnsNames:     limitLength(strings.Join(append(leaf.DNSNames, leaf.IPAddresses...), ","), 1000),
+				SerialNumber: leaf.SerialNumber,
+				KeyAlgorithm: leaf.PublicKeyAlgorithm,
+				Fingerprint:  leaf.Fingerprint,
+				NotBefore:    leaf.NotBefore,
+				NotAfter:     leaf.NotAfter,
+			}
+		}
+		for _, info := range infos {
+			if info.NotAfter < meta.NotAfter {
+				meta.NotAfter = info.NotAfter
+			}
+		}
+	}
+	if meta == nil {
+		return nil, errors.New("证书内容不能为空")
+	}
+	return
+}
+
+func limitLength(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+// CertificateExpiry 证书到期提醒
+type CertificateExpiry struct {
+	CertID      uint64 `json:"certId,string"`
+	CertName    string `json:"certName"`
+	ServeDomain string `json:"serveDomain"`
+	DnsNames    string `json:"dnsNames"`
+	NotAfter    int64  `json:"notAfter"`
+	DaysLeft    int    `json:"daysLeft"`
+	Threshold   int    `json:"threshold"` // 达到的提醒天数阈值，0表示已过期
+}
+
+// expiryWarnDays 到期提醒的天数阈值，按certificate.expiryWarnDays配置(逗号分隔，默认30,7,1)，从大到小排序
+func expiryWarnDays() (days []int) {
+	for _, v := range strings.Split(config.GetString("certificate.expiryWarnDays", "30,7,1"), ",") {
+		if d, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && d > 0 {
+			days = append(days, d)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return
+}
+
+// CheckExpiry 检查证书到期情况，剩余时间达到提醒阈值的证书每次检查都输出日志，每个阈值只发送一次通知；
+// 多节点同时检查时只有更新提醒状态成功的节点发送通知。升级前保存的证书没有证书信息，先解析并保存
+func (u *certificateService) CheckExpiry(now time.Time) (expiries []*CertificateExpiry, err error) {
+	var certs []*model.Certificate
+	if err = database.DB.Omit("key_pem,sign_key_pem,enc_key_pem").Find(&certs).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	thresholds := expiryWarnDays()
+	for _, cert := range certs {
+		if cert.NotAfter == 0 {
+			meta, e := certificateMeta(cert)
+			if e != nil {
+				logger.WithField("certificate", cert.ID).Warn("解析证书失败: ", e)
+				continue
+			}
+			if e = database.DB.Model(&model.Certificate{ID: cert.ID}).Updates(&model.Certificate{CertificateMeta: *meta}).Error; e != nil {
+				logger.Errorln(e)
+				continue
+			}
+			meta.ExpiryWarned = cert.ExpiryWarned
+			cert.CertificateMeta = *meta
+		}
+
+		remaining := time.UnixMilli(cert.NotAfter).Sub(now)
+		threshold := -1
+		if remaining <= 0 {
+			threshold = 0
+		} else {
+			for _, days := range thresholds {
+				if remaining <= time.Duration(days)*24*time.Hour {
+					threshold = days
+				}
+			}
+		}
+		if threshold < 0 {
+			// 证书已更换或调整了阈值
+			if cert.ExpiryWarned != -1 {
+				database.DB.Model(&model.Certificate{}).Where("id = ?", cert.ID).Update("expiry_warned", -1)
+			}
+			continue
+		}
+
+		expiry := &CertificateExpiry{
+			CertID:      cert.ID,
+			CertName:    cert.CertName,
+			ServeDomain: cert.ServeDomain,
+			DnsNames:    cert.DnsNames,
+			NotAfter:    cert.NotAfter,
+			DaysLeft:    int(remaining.Hours() / 24),
+			Threshold:   threshold,
+		}
+		expiries = append(expiries, expiry)
+		notAfter := time.UnixMilli(cert.NotAfter).Format("2006-01-02 15:04:05")
+		n := &Notification{Event: NotifyEventCertificateExpiring, Level: NotifyLevelWarning, Data: expiry}
+		if threshold == 0 {
+			n.Event, n.Level = NotifyEventCertificateExpired, NotifyLevelError
+			n.Message = fmt.Sprintf("证书%s(%s)已于%s过期", cert.CertName, cert.ServeDomain, notAfter)
+			logger.WithField("certificate", cert.ID).Error(n.Message)
+		} else {
+			n.Message = fmt.Sprintf("证书%s(%s)将于%s过期，剩余%d天", cert.CertName, cert.ServeDomain, notAfter, expiry.DaysLeft)
+			logger.WithField("certificate", cert.ID).Warn(n.Message)
+		}
+
+		if cert.ExpiryWarned != -1 && cert.ExpiryWarned <= threshold {
+			continue
+		}
+		result := database.DB.Model(&model.Certificate{}).Where("id = ? AND expiry_warned = ?", cert.ID, cert.ExpiryWarned).
+			Update("expiry_warned", threshold)
+		if result.Error != nil {
+			logger.Errorln(result.Error)
+			continue
+		}
+		if result.RowsAffected == 1 {
+			NotifyService.Notify(n)
+		}
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	stdx509 "crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
+	"math/big"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newEcdsaCertificate 生成自签名的ECDSA证书及PKCS#8私钥
+func newEcdsaCertificate(t *testing.T, domain string, notAfter time.Time) (certPem, keyPem string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &stdx509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := stdx509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 网关使用gmtls加载证书，ECDSA私钥需为PKCS#8格式
+	keyDer, _ := stdx509.MarshalPKCS8PrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}))
+}
+
+// newSm2Certificate 生成自签名的SM2证书及私钥
+func newSm2Certificate(t *testing.T, domain string, notAfter time.Time) (certPem, keyPem string) {
+	key, err := sm2.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(time.Now().UnixNano()),
+		Subject:            pkix.Name{CommonName: domain},
+		DNSNames:           []string{domain},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           notAfter,
+		SignatureAlgorithm: x509.SM2WithSM3,
+	}
+	cert, err := x509.CreateCertificateToPem(template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData, err := x509.WritePrivateKeyToPem(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(cert), string(keyData)
+}
+
+func TestCertificateParse(t *testing.T) {
+	notAfter := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	certPem, keyPem := newEcdsaCertificate(t, "www.example.com", notAfter)
+	_, otherKey := newEcdsaCertificate(t, "www.example.com", notAfter)
+	signCert, signKey := newSm2Certificate(t, "www.example.com", notAfter.Add(-24*time.Hour))
+	encCert, encKey := newSm2Certificate(t, "www.example.com", notAfter)
+
+	c := &model.Certificate{CertPem: certPem, KeyPem: keyPem, SignCertPem: signCert, SignKeyPem: signKey, EncCertPem: encCert, EncKeyPem: encKey}
+	if err := CertificateService.Parse(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.KeyAlgorithm != "ECDSA" || c.DnsNames != "www.example.com" || !strings.Contains(c.Subject, "www.example.com") || c.Fingerprint == "" {
+		t.Errorf("证书信息错误: %+v", c.CertificateMeta)
+	}
+	// 过期时间取最早的证书
+	if c.NotAfter != notAfter.Add(-24*time.Hour).UnixMilli() {
+		t.Errorf("过期时间错误: %d", c.NotAfter)
+	}
+
+	for name, c := range map[string]*model.Certificate{
+		"私钥不匹配":   {CertPem: certPem, KeyPem: otherKey},
+		"缺少私钥":    {CertPem: certPem},
+		"国密私钥不匹配": {SignCertPem: signCert, SignKeyPem: encKey, EncCertPem: encCert, EncKeyPem: encKey},
+		"证书格式错误":  {CertPem: "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n", KeyPem: keyPem},
+		"CA证书为空":  {CertType: model.CertificateTypeCA},
+	} {
+		if err := CertificateService.Parse(c); err == nil {
+			t.Errorf("%s: 应校验失败", name)
+		}
+	}
+	ca := &model.Certificate{CertType: model.CertificateTypeCA, CertPem: signCert}
+	if err := CertificateService.Parse(ca); err != nil || ca.KeyAlgorithm != "SM2" {
+		t.Errorf("CA证书解析失败: %v %+v", err, ca.CertificateMeta)
+	}
+}
+
+func TestCertificateCheckExpiry(t *testing.T) {
+	initTestDatabase(t)
+	var notified []*Notification
+	NotifyService.RegisterHook(func(n *Notification) error {
+		notified = append(notified, n)
+		return nil
+	})
+
+	now := time.Now()
+	certPem, keyPem := newEcdsaCertificate(t, "www.example.com", now.Add(20*24*time.Hour))
+	// 升级前保存的证书没有证书信息
+	database.DB.Create(&model.Certificate{ID: 1, CertName: "web", ServeDomain: "www.example.com", CertPem: certPem, KeyPem: keyPem})
+
+	check := func(now time.Time, threshold, notifications int) {
+		t.Helper()
+		expiries, err := CertificateService.CheckExpiry(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if threshold < 0 {
+			if len(expiries) != 0 {
+				t.Errorf("不应提醒: %+v", expiries[0])
+			}
+		} else if len(expiries) != 1 || expiries[0].Threshold != threshold {
+			t.Errorf("提醒阈值应为%d: %+v", threshold, expiries)
+		}
+		if len(notified) != notifications {
+			t.Errorf("通知数量应为%d，实际为%d", notifications, len(notified))
+		}
+	}
+	check(now, 30, 1)
+	// 同一阈值只通知一次
+	check(now.Add(time.Hour), 30, 1)
+	check(now.Add(14*24*time.Hour), 7, 2)
+	check(now.Add(19*24*time.Hour+time.Hour), 1, 3)
+	check(now.Add(21*24*time.Hour), 0, 4)
+	if notified[3].Event != NotifyEventCertificateExpired {
+		t.Errorf("过期通知事件错误: %s", notified[3].Event)
+	}
+
+	c := new(model.Certificate)
+	database.DB.First(c, 1)
+	if c.NotAfter == 0 || c.ExpiryWarned != 0 {
+		t.Errorf("证书信息及提醒状态应保存: %+v", c.CertificateMeta)
+	}
+
+	// 更换证书后重新提醒
+	certPem, keyPem = newEcdsaCertificate(t, "www.example.com", now.Add(365*24*time.Hour))
+	update := &model.Certificate{ID: 1, CertPem: certPem, KeyPem: keyPem}
+	if err := CertificateService.Parse(update); err != nil {
+		t.Fatal(err)
+	}
+	if _, success, err := CertificateService.Update(update); !success || err != nil {
+		t.Fatal(err)
+	}
+	check(now, -1, 4)
+	check(now.Add(340*24*time.Hour), 30, 5)
+}
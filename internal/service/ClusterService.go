@@ -0,0 +1,152 @@
+package service
+
+import (
+	"errors"
+	logger "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/util"
+	"time"
+)
+
+var ClusterService = &clusterService{}
+
+type clusterService struct{}
+
+// GetConfigVersion 当前配置版本，未修改过配置时为0
+func (u *clusterService) GetConfigVersion() (version int64, err error) {
+	instance := new(model.ConfigVersion)
+	if err = database.DB.Where("id = ?", model.ConfigVersionID).Limit(1).Find(instance).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	version = instance.Version
+	return
+}
+
+// IncreaseConfigVersion 配置版本加1，返回新的版本
+func (u *clusterService) IncreaseConfigVersion() (version int64, err error) {
+	instance := new(model.ConfigVersion)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		result := tx.Model(&model.ConfigVersion{}).Where("id = ?", model.ConfigVersionID).Updates(map[string]interface{}{
+			"version":     gorm.Expr("version + ?", 1),
+			"update_time": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if e := tx.Create(&model.ConfigVersion{ID: model.ConfigVersionID, Version: 1, UpdateTime: now}).Error; e != nil {
+				return e
+			}
+		}
+		return tx.Where("id = ?", model.ConfigVersionID).First(instance).Error
+	})
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	version = instance.Version
+	return
+}
+
+// RegisterNode 注册本节点，workerId小于0时自动分配未使用或心跳已过期的工作机器ID
+func (u *clusterService) RegisterNode(workerId int64, hostname, addr string, expire time.Duration) (node *model.Node, err error) {
+	now := time.Now().UnixMilli()
+	node = &model.Node{
+		Hostname:      hostname,
+		Addr:          addr,
+		StartTime:     now,
+		HeartbeatTime: now,
+	}
+	if workerId >= 0 {
+		// 指定了工作机器ID，直接占用
+		node.ID = uint64(workerId)
+		var claimed bool
+		if claimed, err = u.claimNode(node, 0); err == nil && !claimed {
+			err = errors.New("节点注册失败")
+		}
+		return
+	}
+
+	// 0保留给手动配置，自动分配从1开始
+	staleBefore := now - expire.Milliseconds()
+	for id := uint64(1); id < util.MaxWorkerId; id++ {
+		node.ID = id
+		var claimed bool
+		if claimed, err = u.claimNode(node, staleBefore); err != nil {
+			return
+		}
+		if claimed {
+			return
+		}
+	}
+	err = errors.New("没有可用的节点ID")
+	return
+}
+
+// claimNode 占用节点ID，记录存在时只有心跳早于staleBefore才能占用，staleBefore为0时直接占用
+func (u *clusterService) claimNode(node *model.Node, staleBefore int64) (claimed bool, err error) {
+	sess := database.DB.Model(&model.Node{}).Where("id = ?", node.ID)
+	if staleBefore > 0 {
+		sess = sess.Where("heartbeat_time < ?", staleBefore)
+	}
+	result := sess.Updates(map[string]interface{}{
+		"hostname":       node.Hostname,
+		"addr":           node.Addr,
+		"config_version": 0,
+		"start_time":     node.StartTime,
+		"heartbeat_time": node.HeartbeatTime,
+	})
+	if result.Error != nil {
+		logger.Errorln(result.Error)
+		err = result.Error
+		return
+	}
+	if result.RowsAffected > 0 {
+		claimed = true
+		return
+	}
+	var c int64
+	if err = database.DB.Model(&model.Node{}).Where("id = ?", node.ID).Count(&c).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if c > 0 {
+		// 节点ID使用中
+		return
+	}
+	// 并发注册时主键冲突，视为被其他节点占用
+	claimed = database.DB.Create(node).Error == nil
+	return
+}
+
+// Heartbeat 更新节点心跳及已应用的配置版本
+func (u *clusterService) Heartbeat(id uint64, configVersion int64) (err error) {
+	err = database.DB.Model(&model.Node{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"config_version": configVersion,
+		"heartbeat_time": time.Now().UnixMilli(),
+	}).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// UnregisterNode 节点退出，释放工作机器ID
+func (u *clusterService) UnregisterNode(id uint64) (err error) {
+	if err = database.DB.Where("id = ?", id).Delete(&model.Node{}).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// ListNodes 获取全部节点
+func (u *clusterService) ListNodes() (instances []*model.Node, err error) {
+	if err = database.DB.Order("id").Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
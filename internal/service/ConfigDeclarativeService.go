@@ -0,0 +1,744 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/server"
+	"security-gateway/pkg/util"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ConfigDeclarativeService = &configDeclarativeService{}
+
+type configDeclarativeService struct {
+	// 导入串行执行
+	mu sync.Mutex
+}
+
+// 导入时配置的计划变化
+const (
+	ConfigPlanCreate    = "create"
+	ConfigPlanUpdate    = "update"
+	ConfigPlanUnchanged = "unchanged"
+)
+
+// ConfigPlanItem 导入时一条配置的变化，Key为自然键
+type ConfigPlanItem struct {
+	Resource string   `json:"resource"`
+	Key      string   `json:"key"`
+	Action   string   `json:"action"`
+	Fields   []string `json:"fields,omitempty"`
+}
+
+// ConfigImportResult 导入结果，DryRun时为计划的变化，数据库未修改
+type ConfigImportResult struct {
+	DryRun    bool              `json:"dryRun"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Items     []*ConfigPlanItem `json:"items"`
+}
+
+// Changed 是否有新增或修改
+func (r *ConfigImportResult) Changed() bool {
+	return r.Created+r.Updated > 0
+}
+
+// changedResources 新增或修改的配置类型
+func (r *ConfigImportResult) changedResources() (resources []string) {
+	for _, item := range r.Items {
+		if item.Action != ConfigPlanUnchanged {
+			resources = append(resources, item.Resource)
+		}
+	}
+	return
+}
+
+// ConfigValidationError 配置文档校验失败，包含全部错误
+type ConfigValidationError struct {
+	Errors []string `json:"errors"`
+}
+
+func (e *ConfigValidationError) Error() string {
+	return "配置文档校验失败: " + strings.Join(e.Errors, "; ")
+}
+
+// ConfigForbiddenError 按三员分立当前角色无权修改的配置，Resources为有变化但无权修改的配置类型
+type ConfigForbiddenError struct {
+	Resources []string `json:"resources"`
+}
+
+func (e *ConfigForbiddenError) Error() string {
+	return "无权修改配置: " + strings.Join(e.Resources, ", ")
+}
+
+// ConfigResourceRole 维护该类配置的管理员角色，脱敏字段及用户信息路由由安全管理员维护，其他配置由系统管理员维护。
+// resource为导入计划中的配置类型或GatewayConfig中的字段名
+func ConfigResourceRole(resource string) string {
+	switch strings.TrimSuffix(resource, "s") {
+	case "serviceField", "routeField", "userInfoRoute":
+		return model.AdminRoleSecurity
+	}
+	return model.AdminRoleSystem
+}
+
+// checkConfigRole 有变化的配置类型中存在role无权修改的配置时返回ConfigForbiddenError，role为空时不校验
+func checkConfigRole(role string, resources []string) error {
+	if role == "" {
+		return nil
+	}
+	var forbidden []string
+	seen := make(map[string]bool)
+	for _, resource := range resources {
+		if !seen[resource] && ConfigResourceRole(resource) != role {
+			forbidden = append(forbidden, resource)
+		}
+		seen[resource] = true
+	}
+	if len(forbidden) > 0 {
+		return &ConfigForbiddenError{Resources: forbidden}
+	}
+	return nil
+}
+
+// errDryRun 试运行结束后回滚事务
+var errDryRun = errors.New("dry run")
+
+// Export 导出当前配置为声明式文档，证书以名称引用，用户信息路由的密钥不导出
+func (s *configDeclarativeService) Export() (doc *domain.DeclarativeConfig, err error) {
+	c, err := loadGatewayConfig(database.DB)
+	if err != nil {
+		return
+	}
+	certs, err := CertificateService.ListAll()
+	if err != nil {
+		return
+	}
+	certName := make(map[uint64]string, len(certs))
+	for _, cert := range certs {
+		certName[cert.ID] = cert.CertName
+	}
+	refName := func(id *uint64) string {
+		if id == nil {
+			return ""
+		}
+		return certName[*id]
+	}
+
+	doc = new(domain.DeclarativeConfig)
+	upstreamName := make(map[uint64]string, len(c.Upstreams))
+	for _, u := range c.Upstreams {
+		upstreamName[u.ID] = value(u.Name)
+		doc.Upstreams = append(doc.Upstreams, &domain.DeclarativeUpstream{
+			Name:                  value(u.Name),
+			TargetUrl:             value(u.TargetUrl),
+			HealthCheckUrl:        value(u.HealthCheckUrl),
+			ConnectTimeout:        u.ConnectTimeout,
+			ResponseHeaderTimeout: u.ResponseHeaderTimeout,
+			MaxIdleConnsPerHost:   u.MaxIdleConnsPerHost,
+			IdleConnTimeout:       u.IdleConnTimeout,
+			TlsInsecureSkipVerify: value(u.TlsInsecureSkipVerify),
+			TlsCaCertificate:      refName(u.TlsCaCertificateID),
+			TlsServerName:         value(u.TlsServerName),
+			TlsClientCertificate:  refName(u.TlsClientCertificateID),
+			TlsGmMode:             value(u.TlsGmMode),
+		})
+	}
+	sort.SliceStable(doc.Upstreams, func(i, j int) bool { return doc.Upstreams[i].Name < doc.Upstreams[j].Name })
+
+	routes := make(map[uint64][]*model.Route)
+	for _, r := range c.Routes {
+		if r.ServiceID != nil {
+			routes[*r.ServiceID] = append(routes[*r.ServiceID], r)
+		}
+	}
+	targets := make(map[uint64][]*model.RouteTarget)
+	for _, t := range c.RouteTargets {
+		if t.RouteID != nil && t.UpstreamID != nil {
+			targets[*t.RouteID] = append(targets[*t.RouteID], t)
+		}
+	}
+	serviceFields := make(map[uint64][]*domain.DeclarativeField)
+	for _, f := range c.ServiceFields {
+		serviceFields[f.ServiceID] = append(serviceFields[f.ServiceID], &domain.DeclarativeField{
+			FieldName: f.FieldName, Comment: f.Comment, Level1: f.Level1, Level2: f.Level2, Level3: f.Level3, Level4: f.Level4,
+		})
+	}
+	routeFields := make(map[uint64][]*domain.DeclarativeField)
+	for _, f := range c.RouteFields {
+		routeFields[f.RouteID] = append(routeFields[f.RouteID], &domain.DeclarativeField{
+			FieldName: f.FieldName, Comment: f.Comment, Level1: f.Level1, Level2: f.Level2, Level3: f.Level3, Level4: f.Level4,
+		})
+	}
+	userInfoRoutes := make(map[uint64]*model.UserInfoRoute)
+	for _, u := range c.UserInfoRoutes {
+		userInfoRoutes[u.ServiceID] = u
+	}
+
+	for _, serv := range c.Services {
+		ds := &domain.DeclarativeService{
+			Name:        value(serv.Name),
+			Domain:      value(serv.Domain),
+			Port:        value(serv.Port),
+			Certificate: refName(serv.CertificateID),
+			Fields:      serviceFields[serv.ID],
+		}
+		if u := userInfoRoutes[serv.ID]; u != nil {
+			ds.UserInfoRoute = &domain.DeclarativeUserInfoRoute{
+				Path:             u.Path,
+				Method:           u.Method,
+				UsernamePath:     u.UsernamePath,
+				UniKeyPath:       u.UniKeyPath,
+				MatchKey:         u.MatchKey,
+				TokenPosition:    u.TokenPosition,
+				TokenType:        u.TokenType,
+				JwtAlgorithms:    u.JwtAlgorithms,
+				JwksUrl:          u.JwksUrl,
+				IntrospectionUrl: u.IntrospectionUrl,
+				ClientId:         u.ClientId,
+				LogoutPath:       u.LogoutPath,
+				LogoutMethod:     u.LogoutMethod,
+				LogoutCheck:      u.LogoutCheck,
+			}
+		}
+		for _, r := range routes[serv.ID] {
+			methods, headers, queries, cookies := r.PredicateValues()
+			dr := &domain.DeclarativeRoute{
+				Uri:          value(r.Uri),
+				Methods:      methods,
+				MatchHeaders: headers,
+				MatchQueries: queries,
+				MatchCookies: cookies,
+				Priority:     r.Priority,
+				LoadBalance:  r.LoadBalance,
+				Timeout:      r.Timeout,
+				RewriteType:  r.RewriteType,
+				RewriteValue: value(r.RewriteValue),
+				Fields:       routeFields[r.ID],
+			}
+			for _, t := range targets[r.ID] {
+				if name, ok := upstreamName[*t.UpstreamID]; ok {
+					dr.Targets = append(dr.Targets, &domain.DeclarativeTarget{Upstream: name, Weight: t.Weight})
+				}
+			}
+			sort.SliceStable(dr.Targets, func(i, j int) bool { return dr.Targets[i].Upstream < dr.Targets[j].Upstream })
+			ds.Routes = append(ds.Routes, dr)
+		}
+		sort.SliceStable(ds.Routes, func(i, j int) bool { return ds.Routes[i].Key() < ds.Routes[j].Key() })
+		doc.Services = append(doc.Services, ds)
+	}
+	sort.SliceStable(doc.Services, func(i, j int) bool {
+		if doc.Services[i].Port != doc.Services[j].Port {
+			return doc.Services[i].Port < doc.Services[j].Port
+		}
+		return doc.Services[i].Domain < doc.Services[j].Domain
+	})
+	return
+}
+
+// Validate 校验配置文档，引用的证书及上游需存在于文档或数据库中，不修改数据库
+func (s *configDeclarativeService) Validate(doc *domain.DeclarativeConfig) error {
+	return (&configImporter{tx: database.DB}).validate(doc)
+}
+
+// Import 导入配置文档，按自然键新增或更新，不删除文档中没有的配置，在一个事务中执行；dryRun时只返回计划的变化。
+// role不为空时按三员分立校验，有变化的配置中存在该角色无权修改的配置时整体不导入，返回ConfigForbiddenError
+func (s *configDeclarativeService) Import(doc *domain.DeclarativeConfig, dryRun bool, role string) (result *ConfigImportResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result = &ConfigImportResult{DryRun: dryRun, Items: []*ConfigPlanItem{}}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		imp := &configImporter{tx: tx, result: result}
+		if e := imp.validate(doc); e != nil {
+			return e
+		}
+		if e := imp.run(doc); e != nil {
+			return e
+		}
+		if e := checkConfigRole(role, result.changedResources()); e != nil {
+			return e
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		err = nil
+	}
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	return
+}
+
+type configImporter struct {
+	tx     *gorm.DB
+	result *ConfigImportResult
+	// 证书名称 -> ID
+	certs map[string]uint64
+	// 上游名称 -> ID
+	upstreams map[string]uint64
+}
+
+func serviceKey(s *domain.DeclarativeService) string {
+	return s.Domain + ":" + strconv.Itoa(int(s.Port))
+}
+
+func (imp *configImporter) loadCertificates() error {
+	var certs []*model.Certificate
+	if err := imp.tx.Select("id", "cert_name").Order("create_time").Find(&certs).Error; err != nil {
+		return err
+	}
+	imp.certs = make(map[string]uint64, len(certs))
+	for _, cert := range certs {
+		if _, ok := imp.certs[cert.CertName]; !ok && cert.CertName != "" {
+			imp.certs[cert.CertName] = cert.ID
+		}
+	}
+	return nil
+}
+
+func (imp *configImporter) validate(doc *domain.DeclarativeConfig) error {
+	if err := imp.loadCertificates(); err != nil {
+		return err
+	}
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+	checkCert := func(owner, name string) {
+		if name != "" && imp.certs[name] == 0 {
+			fail("%s引用的证书%s不存在", owner, name)
+		}
+	}
+	checkFields := func(owner string, fields []*domain.DeclarativeField) {
+		names := make(map[string]bool)
+		for _, f := range fields {
+			if f.FieldName == "" {
+				fail("%s的脱敏字段名不能为空", owner)
+			} else if names[f.FieldName] {
+				fail("%s的脱敏字段%s重复", owner, f.FieldName)
+			}
+			names[f.FieldName] = true
+		}
+	}
+
+	upstreamNames := make(map[string]bool)
+	for _, u := range doc.Upstreams {
+		owner := "上游" + u.Name
+		if u.Name == "" || u.TargetUrl == "" {
+			fail("上游的名称和目标URL不能为空: %s", u.Name)
+			continue
+		}
+		if upstreamNames[u.Name] {
+			fail("%s重复", owner)
+		}
+		upstreamNames[u.Name] = true
+		var c int64
+		if err := imp.tx.Model(&model.Upstream{}).Where("target_url = ? and name <> ?", u.TargetUrl, u.Name).Count(&c).Error; err != nil {
+			return err
+		}
+		if c > 0 {
+			fail("%s的目标URL%s已被其他上游使用", owner, u.TargetUrl)
+		}
+		checkCert(owner, u.TlsCaCertificate)
+		checkCert(owner, u.TlsClientCertificate)
+	}
+
+	serviceKeys, serviceNames := make(map[string]bool), make(map[string]bool)
+	for _, serv := range doc.Services {
+		key := serviceKey(serv)
+		owner := "服务" + key
+		if serv.Name == "" || serv.Port == 0 {
+			fail("%s的名称和端口不能为空", owner)
+			continue
+		}
+		if serviceKeys[key] {
+			fail("%s重复", owner)
+		}
+		if serviceNames[serv.Name] {
+			fail("服务名称%s重复", serv.Name)
+		}
+		serviceKeys[key], serviceNames[serv.Name] = true, true
+		if err := ServiceService.CheckPort(serv.Port); err != nil {
+			fail("%s的%s", owner, err.Error())
+		}
+		var c int64
+		if err := imp.tx.Model(&model.Service{}).Where("name = ? and not (domain = ? and port = ?)", serv.Name, serv.Domain, serv.Port).Count(&c).Error; err != nil {
+			return err
+		}
+		if c > 0 {
+			fail("%s的名称%s已被其他服务使用", owner, serv.Name)
+		}
+		checkCert(owner, serv.Certificate)
+		checkFields(owner, serv.Fields)
+		if u := serv.UserInfoRoute; u != nil && (u.Path == "" || u.UsernamePath == "" || u.UniKeyPath == "" || u.MatchKey == "") {
+			fail("%s的用户信息路由的路径、用户名路径、唯一标识路径及匹配键不能为空", owner)
+		}
+
+		routeKeys := make(map[string]bool)
+		var routeSpecs []*server.RouteSpec
+		for i, r := range serv.Routes {
+			routeOwner := owner + "的路由" + r.Key()
+			if r.Uri == "" {
+				fail("%s的路由URI不能为空", owner)
+				continue
+			}
+			if routeKeys[r.Key()] {
+				fail("%s重复", routeOwner)
+			} else {
+				// 按文档中的顺序检查与前面的路由是否冲突
+				spec := &server.RouteSpec{
+					Key:       fmt.Sprintf("%03d", i),
+					Path:      r.Uri,
+					Predicate: server.ParsePredicate(r.Methods, r.MatchHeaders, r.MatchQueries, r.MatchCookies, r.Priority),
+				}
+				for _, e := range server.ValidateRoute(spec, routeSpecs) {
+					fail("%s: %s", routeOwner, e)
+				}
+				routeSpecs = append(routeSpecs, spec)
+			}
+			routeKeys[r.Key()] = true
+			checkFields(routeOwner, r.Fields)
+			targetNames := make(map[string]bool)
+			for _, t := range r.Targets {
+				if targetNames[t.Upstream] {
+					fail("%s的上游%s重复", routeOwner, t.Upstream)
+				}
+				targetNames[t.Upstream] = true
+				if upstreamNames[t.Upstream] {
+					continue
+				}
+				id, err := imp.findUpstream(t.Upstream)
+				if err != nil {
+					return err
+				}
+				if id == nil {
+					fail("%s引用的上游%s不存在", routeOwner, t.Upstream)
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return &ConfigValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func (imp *configImporter) findUpstream(name string) (*model.Upstream, error) {
+	var list []*model.Upstream
+	if err := imp.tx.Where("name = ?", name).Order("create_time").Limit(1).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, nil
+	}
+	return list[0], nil
+}
+
+func (imp *configImporter) certID(name string) *uint64 {
+	if name == "" {
+		return nil
+	}
+	id := imp.certs[name]
+	return &id
+}
+
+func (imp *configImporter) run(doc *domain.DeclarativeConfig) error {
+	imp.upstreams = make(map[string]uint64)
+	for _, u := range doc.Upstreams {
+		existing, err := imp.findUpstream(u.Name)
+		if err != nil {
+			return err
+		}
+		desired := &model.Upstream{
+			Name:                   &u.Name,
+			TargetUrl:              &u.TargetUrl,
+			HealthCheckUrl:         &u.HealthCheckUrl,
+			ConnectTimeout:         u.ConnectTimeout,
+			ResponseHeaderTimeout:  u.ResponseHeaderTimeout,
+			MaxIdleConnsPerHost:    u.MaxIdleConnsPerHost,
+			IdleConnTimeout:        u.IdleConnTimeout,
+			TlsInsecureSkipVerify:  &u.TlsInsecureSkipVerify,
+			TlsCaCertificateID:     imp.certID(u.TlsCaCertificate),
+			TlsServerName:          &u.TlsServerName,
+			TlsClientCertificateID: imp.certID(u.TlsClientCertificate),
+			TlsGmMode:              &u.TlsGmMode,
+		}
+		if existing != nil {
+			// 健康检查状态不由配置文档决定
+			desired.Status, desired.LastCheckTime = existing.Status, existing.LastCheckTime
+		}
+		id, err := save(imp, "upstream", u.Name, existing, desired, func(v *model.Upstream) *uint64 { return &v.ID })
+		if err != nil {
+			return err
+		}
+		imp.upstreams[u.Name] = id
+	}
+
+	for _, serv := range doc.Services {
+		if err := imp.importService(serv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *configImporter) importService(serv *domain.DeclarativeService) error {
+	key := serviceKey(serv)
+	var existing []*model.Service
+	if err := imp.tx.Where("domain = ? and port = ?", serv.Domain, serv.Port).Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+	desired := &model.Service{
+		Name:          &serv.Name,
+		Domain:        &serv.Domain,
+		Port:          &serv.Port,
+		CertificateID: imp.certID(serv.Certificate),
+	}
+	serviceId, err := save(imp, "service", key, first(existing), desired, func(v *model.Service) *uint64 { return &v.ID })
+	if err != nil {
+		return err
+	}
+
+	for _, f := range serv.Fields {
+		var found []*model.ServiceField
+		if err = imp.tx.Where("service_id = ? and field_name = ?", serviceId, f.FieldName).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+		desired := &model.ServiceField{ServiceID: serviceId, FieldName: f.FieldName, Comment: f.Comment,
+			Level1: f.Level1, Level2: f.Level2, Level3: f.Level3, Level4: f.Level4}
+		if _, err = save(imp, "serviceField", key+" "+f.FieldName, first(found), desired, func(v *model.ServiceField) *uint64 { return &v.ID }); err != nil {
+			return err
+		}
+	}
+
+	if u := serv.UserInfoRoute; u != nil {
+		var found []*model.UserInfoRoute
+		if err = imp.tx.Where("service_id = ?", serviceId).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+		method := strings.ToUpper(u.Method)
+		if method == "" {
+			method = "GET"
+		}
+		desired := &model.UserInfoRoute{
+			ServiceID:        serviceId,
+			Path:             u.Path,
+			Method:           method,
+			UsernamePath:     u.UsernamePath,
+			UniKeyPath:       u.UniKeyPath,
+			MatchKey:         u.MatchKey,
+			TokenPosition:    u.TokenPosition,
+			TokenType:        u.TokenType,
+			JwtAlgorithms:    u.JwtAlgorithms,
+			JwtKey:           u.JwtKey,
+			JwksUrl:          u.JwksUrl,
+			IntrospectionUrl: u.IntrospectionUrl,
+			ClientId:         u.ClientId,
+			ClientSecret:     u.ClientSecret,
+			LogoutPath:       u.LogoutPath,
+			LogoutMethod:     strings.ToUpper(u.LogoutMethod),
+			LogoutCheck:      u.LogoutCheck,
+		}
+		// 导出时不包含密钥，为空时保留原值
+		if old := first(found); old != nil {
+			if desired.JwtKey == "" {
+				desired.JwtKey = old.JwtKey
+			}
+			if desired.ClientSecret == "" {
+				desired.ClientSecret = old.ClientSecret
+			}
+		}
+		if _, err = save(imp, "userInfoRoute", key, first(found), desired, func(v *model.UserInfoRoute) *uint64 { return &v.ID }); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range serv.Routes {
+		if err = imp.importRoute(serviceId, key, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *configImporter) importRoute(serviceId uint64, serviceKey string, r *domain.DeclarativeRoute) error {
+	key := serviceKey + " " + r.Key()
+	var candidates []*model.Route
+	if err := imp.tx.Where("service_id = ? and uri = ?", serviceId, r.Uri).Order("create_time").Find(&candidates).Error; err != nil {
+		return err
+	}
+	var existing *model.Route
+	for _, c := range candidates {
+		methods, headers, queries, cookies := c.PredicateValues()
+		if domain.RouteKey(r.Uri, methods, headers, queries, cookies) == r.Key() {
+			existing = c
+			break
+		}
+	}
+	desired := &model.Route{
+		ServiceID:    &serviceId,
+		Uri:          &r.Uri,
+		LoadBalance:  r.LoadBalance,
+		Timeout:      r.Timeout,
+		Methods:      &r.Methods,
+		MatchHeaders: &r.MatchHeaders,
+		MatchQueries: &r.MatchQueries,
+		MatchCookies: &r.MatchCookies,
+		Priority:     r.Priority,
+		RewriteType:  r.RewriteType,
+		RewriteValue: &r.RewriteValue,
+	}
+	// 与数据库默认值一致
+	if desired.LoadBalance == 0 {
+		desired.LoadBalance = 1
+	}
+	if desired.RewriteType == 0 {
+		desired.RewriteType = 1
+	}
+	routeId, err := save(imp, "route", key, existing, desired, func(v *model.Route) *uint64 { return &v.ID })
+	if err != nil {
+		return err
+	}
+
+	for _, t := range r.Targets {
+		upstreamId, ok := imp.upstreams[t.Upstream]
+		if !ok {
+			u, err := imp.findUpstream(t.Upstream)
+			if err != nil {
+				return err
+			}
+			upstreamId = u.ID
+			imp.upstreams[t.Upstream] = upstreamId
+		}
+		var found []*model.RouteTarget
+		if err = imp.tx.Where("route_id = ? and upstream_id = ?", routeId, upstreamId).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+		desired := &model.RouteTarget{RouteID: &routeId, UpstreamID: &upstreamId, Weight: t.Weight}
+		if _, err = save(imp, "routeTarget", key+" -> "+t.Upstream, first(found), desired, func(v *model.RouteTarget) *uint64 { return &v.ID }); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range r.Fields {
+		var found []*model.RouteField
+		if err = imp.tx.Where("route_id = ? and field_name = ?", routeId, f.FieldName).Limit(1).Find(&found).Error; err != nil {
+			return err
+		}
+		desired := &model.RouteField{RouteID: routeId, FieldName: f.FieldName, Comment: f.Comment,
+			Level1: f.Level1, Level2: f.Level2, Level3: f.Level3, Level4: f.Level4}
+		if _, err = save(imp, "routeField", key+" "+f.FieldName, first(found), desired, func(v *model.RouteField) *uint64 { return &v.ID }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *configImporter) plan(resource, key, action string, fields []string) {
+	imp.result.Items = append(imp.result.Items, &ConfigPlanItem{Resource: resource, Key: key, Action: action, Fields: fields})
+	switch action {
+	case ConfigPlanCreate:
+		imp.result.Created++
+	case ConfigPlanUpdate:
+		imp.result.Updated++
+	default:
+		imp.result.Unchanged++
+	}
+}
+
+// save 按自然键查到的记录existing为nil时新增，否则与desired比较，有变化时更新全部字段，返回记录ID
+func save[T any](imp *configImporter, resource, key string, existing, desired *T, id func(*T) *uint64) (uint64, error) {
+	if existing == nil {
+		*id(desired) = util.SnowflakeId()
+		if err := imp.tx.Create(desired).Error; err != nil {
+			return 0, err
+		}
+		imp.plan(resource, key, ConfigPlanCreate, nil)
+		return *id(desired), nil
+	}
+
+	*id(desired) = *id(existing)
+	fields, err := changedFields(existing, desired)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		imp.plan(resource, key, ConfigPlanUnchanged, nil)
+		return *id(existing), nil
+	}
+	if err = imp.tx.Model(existing).Select("*").Omit("id", "create_time", "delete_time").Updates(desired).Error; err != nil {
+		return 0, err
+	}
+	imp.plan(resource, key, ConfigPlanUpdate, fields)
+	return *id(existing), nil
+}
+
+// changedFields 比较两条记录的字段，空值(null、空字符串、false、0)视为相同，不比较ID及时间字段
+func changedFields(before, after interface{}) ([]string, error) {
+	toMap := func(v interface{}) (m map[string]interface{}, err error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		if err = json.Unmarshal(data, &m); err != nil {
+			return
+		}
+		for k, item := range m {
+			if isEmptyJSON(item) || k == "id" || k == "createTime" || k == "deleteTime" {
+				delete(m, k)
+			}
+		}
+		return
+	}
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+	return diffFields(b, a), nil
+}
+
+func isEmptyJSON(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	}
+	return false
+}
+
+func value[T any](p *T) (v T) {
+	if p != nil {
+		v = *p
+	}
+	return
+}
+
+func first[T any](list []*T) *T {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[0]
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"testing"
+)
+
+func TestConfigDeclarativeImport(t *testing.T) {
+	initTestDatabase(t)
+	database.DB.Create(&model.Certificate{ID: 100, CertName: "web-cert"})
+
+	doc, err := domain.ParseDeclarativeConfig([]byte(`
+upstreams:
+  - name: backend
+    targetUrl: http://127.0.0.1:8080
+services:
+  - name: web
+    domain: example.com
+    port: 8443
+    certificate: web-cert
+    fields:
+      - fieldName: phone
+        level1: mobile
+    userInfoRoute:
+      path: /api/user
+      method: get
+      usernamePath: data.name
+      uniKeyPath: data.id
+      matchKey: Authorization
+      jwtKey: secret
+    routes:
+      - uri: /api
+        targets:
+          - upstream: backend
+            weight: 1
+      - uri: /api
+        methods: POST
+        rewriteType: 2
+`), domain.ConfigFormatYaml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 试运行不修改数据库
+	plan, err := ConfigDeclarativeService.Import(doc, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.DryRun || plan.Created != 7 || plan.Updated != 0 {
+		t.Errorf("试运行结果错误: %+v", plan)
+	}
+	var count int64
+	database.DB.Model(&model.Service{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("试运行不应修改数据库")
+	}
+
+	if result, err := ConfigDeclarativeService.Import(doc, false, ""); err != nil || result.Created != 7 {
+		t.Fatalf("导入失败: %+v %v", result, err)
+	}
+
+	// 导出后再次导入没有变化
+	exported, err := ConfigDeclarativeService.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported.Services) != 1 || len(exported.Services[0].Routes) != 2 || exported.Services[0].Certificate != "web-cert" {
+		t.Fatalf("导出结果错误: %+v", exported.Services)
+	}
+	if exported.Services[0].UserInfoRoute.JwtKey != "" || exported.Services[0].UserInfoRoute.Method != "GET" {
+		t.Errorf("导出的用户信息路由错误: %+v", exported.Services[0].UserInfoRoute)
+	}
+	data, err := domain.MarshalDeclarativeConfig(exported, domain.ConfigFormatJson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = domain.ParseDeclarativeConfig(data, domain.ConfigFormatJson); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ConfigDeclarativeService.Import(doc, false, "")
+	if err != nil || result.Created != 0 || result.Updated != 0 || result.Unchanged != 7 {
+		t.Fatalf("重复导入应没有变化: %+v %v", result, err)
+	}
+	var userInfoRoute model.UserInfoRoute
+	database.DB.First(&userInfoRoute)
+	if userInfoRoute.JwtKey != "secret" {
+		t.Errorf("未指定密钥时应保留原值: %q", userInfoRoute.JwtKey)
+	}
+
+	// 修改上游地址
+	doc.Upstreams[0].TargetUrl = "http://127.0.0.1:9090"
+	if result, err = ConfigDeclarativeService.Import(doc, true, ""); err != nil || result.Updated != 1 || result.Items[0].Fields[0] != "targetUrl" {
+		t.Errorf("修改计划错误: %+v %v", result, err)
+	}
+
+	// 三员分立：安全管理员不能修改上游，系统管理员不能同时修改脱敏字段，整体不导入
+	if _, err = ConfigDeclarativeService.Import(doc, true, model.AdminRoleSecurity); err == nil {
+		t.Errorf("安全管理员不应修改上游")
+	}
+	doc.Services[0].Fields[0].Level1 = "name"
+	_, err = ConfigDeclarativeService.Import(doc, false, model.AdminRoleSystem)
+	if e, ok := err.(*ConfigForbiddenError); !ok || len(e.Resources) != 1 || e.Resources[0] != "serviceField" {
+		t.Errorf("系统管理员不应修改脱敏字段: %v", err)
+	}
+	var upstream model.Upstream
+	database.DB.First(&upstream)
+	if *upstream.TargetUrl != "http://127.0.0.1:8080" {
+		t.Errorf("无权修改时不应导入任何配置: %s", *upstream.TargetUrl)
+	}
+	doc.Upstreams[0].TargetUrl = "http://127.0.0.1:8080"
+	if result, err = ConfigDeclarativeService.Import(doc, false, model.AdminRoleSecurity); err != nil || result.Updated != 1 {
+		t.Errorf("安全管理员修改脱敏字段失败: %+v %v", result, err)
+	}
+
+	// 引用不存在的上游及证书时校验失败
+	doc.Services[0].Certificate = "missing"
+	doc.Services[0].Routes[0].Targets[0].Upstream = "missing"
+	_, err = ConfigDeclarativeService.Import(doc, true, "")
+	if e, ok := err.(*ConfigValidationError); !ok || len(e.Errors) != 2 {
+		t.Errorf("校验结果错误: %v", err)
+	}
+}
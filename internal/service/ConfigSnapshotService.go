@@ -0,0 +1,448 @@
+package service
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tjfoc/gmsm/sm3"
+	"gorm.io/gorm"
+	"reflect"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"security-gateway/pkg/util"
+	"sort"
+	"sync"
+)
+
+var ConfigSnapshotService = &configSnapshotService{}
+
+type configSnapshotService struct {
+	// 保存快照及回滚串行执行
+	mu sync.Mutex
+}
+
+// ErrConfigSnapshotNotFound 指定的配置版本不存在
+var ErrConfigSnapshotNotFound = errors.New("配置版本不存在")
+
+// 配置变化的类型
+const (
+	ConfigChangeAdded    = "added"
+	ConfigChangeRemoved  = "removed"
+	ConfigChangeModified = "modified"
+)
+
+// ConfigChange 两个版本之间一条配置的变化，Resource为GatewayConfig中的字段名
+type ConfigChange struct {
+	Resource string                 `json:"resource"`
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	Fields   []string               `json:"fields,omitempty"`
+	Before   map[string]interface{} `json:"before,omitempty"`
+	After    map[string]interface{} `json:"after,omitempty"`
+}
+
+// ConfigDiff 两个版本的差异，To为0时表示与当前配置比较
+type ConfigDiff struct {
+	From    uint64          `json:"from"`
+	To      uint64          `json:"to"`
+	Changes []*ConfigChange `json:"changes"`
+}
+
+// Current 从数据库读取当前的网关配置
+func (s *configSnapshotService) Current() (c *domain.GatewayConfig, err error) {
+	return loadGatewayConfig(database.DB)
+}
+
+func loadGatewayConfig(db *gorm.DB) (c *domain.GatewayConfig, err error) {
+	c = new(domain.GatewayConfig)
+	for _, dest := range []interface{}{&c.Services, &c.Routes, &c.RouteTargets, &c.Upstreams,
+		&c.ServiceFields, &c.RouteFields, &c.UserInfoRoutes, &c.ServiceCertificates} {
+		if err = db.Find(dest).Error; err != nil {
+			logger.Errorln(err)
+			return nil, err
+		}
+	}
+	c.Sort()
+	return
+}
+
+// Snapshot 保存当前配置为新的版本，与最新版本相同时不保存，返回最新版本
+func (s *configSnapshotService) Snapshot(actor, ip, summary string) (instance *model.ConfigSnapshot, created bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot(actor, ip, summary)
+}
+
+func (s *configSnapshotService) snapshot(actor, ip, summary string) (instance *model.ConfigSnapshot, created bool, err error) {
+	current, err := s.Current()
+	if err != nil {
+		return
+	}
+	// 密钥加密后保存，每次加密结果不同，摘要按密钥的SM3摘要计算，配置未变化时摘要不变
+	masked, err := maskedContent(current)
+	if err != nil {
+		return
+	}
+	digest := hex.EncodeToString(sm3.Sm3Sum(masked))
+	sealed, err := current.MapSecrets(secret.Encrypt)
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	content, err := json.Marshal(sealed)
+	if err != nil {
+		return
+	}
+
+	// 多节点同时保存时依靠Version唯一索引冲突后重试
+	for i := 0; i < 5; i++ {
+		var last *model.ConfigSnapshot
+		if last, err = s.last(); err != nil {
+			return
+		}
+		if last != nil && last.Digest == digest {
+			return last, false, nil
+		}
+		instance = &model.ConfigSnapshot{
+			ID:      util.SnowflakeId(),
+			Version: 1,
+			Actor:   actor,
+			Ip:      ip,
+			Summary: summary,
+			Digest:  digest,
+			Content: string(content),
+		}
+		if last != nil {
+			instance.Version = last.Version + 1
+		}
+		if err = database.DB.Create(instance).Error; err == nil {
+			created = true
+			s.prune(instance.Version)
+			return
+		}
+		logger.Warnf("保存配置快照失败，版本%d，重试: %v", instance.Version, err)
+	}
+	logger.Errorln(err)
+	return nil, false, err
+}
+
+// prune 只保留最近snapshot.maxVersions(默认500)个版本
+func (s *configSnapshotService) prune(latest uint64) {
+	max := uint64(config.GetInt("snapshot.maxVersions", 500))
+	if max == 0 || latest <= max {
+		return
+	}
+	if err := database.DB.Where("version <= ?", latest-max).Delete(&model.ConfigSnapshot{}).Error; err != nil {
+		logger.Errorln(err)
+	}
+}
+
+// last 最新的版本，没有时返回nil
+func (s *configSnapshotService) last() (instance *model.ConfigSnapshot, err error) {
+	instance = new(model.ConfigSnapshot)
+	if err = database.DB.Omit("content").Order("version desc").Limit(1).Find(instance).Error; err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	if instance.ID == 0 {
+		instance = nil
+	}
+	return
+}
+
+// GetByVersion 获取指定版本，包含配置内容
+func (s *configSnapshotService) GetByVersion(version uint64) (instance *model.ConfigSnapshot, err error) {
+	instance = new(model.ConfigSnapshot)
+	if err = database.DB.Where("version = ?", version).Limit(1).Find(instance).Error; err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	if instance.ID == 0 {
+		return nil, ErrConfigSnapshotNotFound
+	}
+	return
+}
+
+// List 分页查询版本，按版本倒序，不包含配置内容
+func (s *configSnapshotService) List(page, pageSize int) (instances []*model.ConfigSnapshot, total int64, err error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	sess := database.DB.Model(&model.ConfigSnapshot{})
+	if err = sess.Count(&total).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if total == 0 {
+		return
+	}
+	err = sess.Omit("content").Order("version desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&instances).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Diff 比较两个版本的配置，to为0时与当前配置比较
+func (s *configSnapshotService) Diff(from, to uint64) (diff *ConfigDiff, err error) {
+	fromSnapshot, err := s.GetByVersion(from)
+	if err != nil {
+		return
+	}
+	fromContent, err := maskedSnapshotContent(fromSnapshot)
+	if err != nil {
+		return
+	}
+	var toContent []byte
+	if to == 0 {
+		var current *domain.GatewayConfig
+		if current, err = s.Current(); err != nil {
+			return
+		}
+		if toContent, err = maskedContent(current); err != nil {
+			return
+		}
+	} else {
+		var toSnapshot *model.ConfigSnapshot
+		if toSnapshot, err = s.GetByVersion(to); err != nil {
+			return
+		}
+		if toContent, err = maskedSnapshotContent(toSnapshot); err != nil {
+			return
+		}
+	}
+	changes, err := diffGatewayConfig(fromContent, toContent)
+	if err != nil {
+		return
+	}
+	return &ConfigDiff{From: from, To: to, Changes: changes}, nil
+}
+
+// maskedContent 密钥替换为SM3摘要后序列化的配置，用于计算摘要及比较版本，不暴露密钥
+func maskedContent(c *domain.GatewayConfig) ([]byte, error) {
+	masked, err := c.MapSecrets(maskSecret)
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	return json.Marshal(masked)
+}
+
+func maskedSnapshotContent(snapshot *model.ConfigSnapshot) ([]byte, error) {
+	c, err := domain.ParseGatewayConfig([]byte(snapshot.Content))
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	return maskedContent(c)
+}
+
+// maskSecret 解密后的SM3摘要，兼容加密前保存的明文
+func maskSecret(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	plaintext, err := secret.Decrypt(value)
+	if err != nil {
+		return "", err
+	}
+	return "sm3:" + hex.EncodeToString(sm3.Sm3Sum([]byte(plaintext))), nil
+}
+
+// diffGatewayConfig 按ID比较两份配置中的每一条记录
+func diffGatewayConfig(from, to []byte) (changes []*ConfigChange, err error) {
+	var before, after map[string][]map[string]interface{}
+	if err = json.Unmarshal(from, &before); err != nil {
+		return
+	}
+	if err = json.Unmarshal(to, &after); err != nil {
+		return
+	}
+	resources := make([]string, 0, len(after))
+	for resource := range after {
+		resources = append(resources, resource)
+	}
+	for resource := range before {
+		if _, ok := after[resource]; !ok {
+			resources = append(resources, resource)
+		}
+	}
+	sort.Strings(resources)
+
+	for _, resource := range resources {
+		beforeByID := make(map[string]map[string]interface{})
+		for _, item := range before[resource] {
+			beforeByID[fmt.Sprint(item["id"])] = item
+		}
+		for _, item := range after[resource] {
+			id := fmt.Sprint(item["id"])
+			old, ok := beforeByID[id]
+			delete(beforeByID, id)
+			if !ok {
+				changes = append(changes, &ConfigChange{Resource: resource, ID: id, Type: ConfigChangeAdded, After: item})
+				continue
+			}
+			if fields := diffFields(old, item); len(fields) > 0 {
+				changes = append(changes, &ConfigChange{Resource: resource, ID: id, Type: ConfigChangeModified, Fields: fields, Before: old, After: item})
+			}
+		}
+		for _, item := range before[resource] {
+			id := fmt.Sprint(item["id"])
+			if _, ok := beforeByID[id]; ok {
+				changes = append(changes, &ConfigChange{Resource: resource, ID: id, Type: ConfigChangeRemoved, Before: item})
+			}
+		}
+	}
+	return
+}
+
+func diffFields(before, after map[string]interface{}) (fields []string) {
+	for k, v := range after {
+		if !reflect.DeepEqual(before[k], v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return
+}
+
+// Rollback 在一个事务中将配置还原为指定版本，引用已删除证书的服务证书关联会被跳过，成功后保存为新的版本。
+// role不为空时按三员分立校验，与当前配置相比有变化的配置中存在该角色无权修改的配置时不回滚，返回ConfigForbiddenError
+func (s *configSnapshotService) Rollback(version uint64, actor, role, ip string) (instance *model.ConfigSnapshot, skipped int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target, err := s.GetByVersion(version)
+	if err != nil {
+		return
+	}
+	if role != "" {
+		var resources []string
+		if resources, err = s.changedResources(target); err != nil {
+			return
+		}
+		if err = checkConfigRole(role, resources); err != nil {
+			return
+		}
+	}
+	c, err := domain.ParseGatewayConfig([]byte(target.Content))
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	// 先解密快照中的密钥，保存时使用当前的主密钥重新加密
+	if c, err = c.MapSecrets(secret.Decrypt); err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if skipped, err = RestoreGatewayConfig(c); err != nil {
+		return
+	}
+	instance, _, err = s.snapshot(actor, ip, fmt.Sprintf("回滚到版本%d", version))
+	return
+}
+
+// changedResources 回滚到target时有变化的配置类型
+func (s *configSnapshotService) changedResources(target *model.ConfigSnapshot) (resources []string, err error) {
+	targetContent, err := maskedSnapshotContent(target)
+	if err != nil {
+		return
+	}
+	current, err := s.Current()
+	if err != nil {
+		return
+	}
+	currentContent, err := maskedContent(current)
+	if err != nil {
+		return
+	}
+	changes, err := diffGatewayConfig(currentContent, targetContent)
+	if err != nil {
+		return
+	}
+	for _, change := range changes {
+		resources = append(resources, change.Resource)
+	}
+	return
+}
+
+// RestoreGatewayConfig 在一个事务中将数据库中的网关配置替换为c，c中没有的记录被删除
+func RestoreGatewayConfig(c *domain.GatewayConfig) (skipped int, err error) {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var certIds []uint64
+		if e := tx.Model(&model.Certificate{}).Pluck("id", &certIds).Error; e != nil {
+			return e
+		}
+		certExists := make(map[uint64]bool, len(certIds))
+		for _, id := range certIds {
+			certExists[id] = true
+		}
+		bindings := make([]*model.ServiceCertificate, 0, len(c.ServiceCertificates))
+		for _, sc := range c.ServiceCertificates {
+			if certExists[sc.CertID] {
+				bindings = append(bindings, sc)
+			} else {
+				skipped++
+			}
+		}
+
+		if e := restoreTable(tx, c.Services, func(v *model.Service) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.Routes, func(v *model.Route) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.RouteTargets, func(v *model.RouteTarget) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.Upstreams, func(v *model.Upstream) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.ServiceFields, func(v *model.ServiceField) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.RouteFields, func(v *model.RouteField) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		if e := restoreTable(tx, c.UserInfoRoutes, func(v *model.UserInfoRoute) uint64 { return v.ID }); e != nil {
+			return e
+		}
+		return restoreTable(tx, bindings, func(v *model.ServiceCertificate) uint64 { return v.ID })
+	})
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// restoreTable 将表中的记录替换为rows：rows中没有的记录删除(有软删除的表为软删除)，rows中的记录先物理删除再按原值插入
+func restoreTable[T any](tx *gorm.DB, rows []*T, id func(*T) uint64) error {
+	ids := make([]uint64, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, id(row))
+	}
+	if len(ids) == 0 {
+		return tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(new(T)).Error
+	}
+	if err := tx.Where("id NOT IN ?", ids).Delete(new(T)).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("id IN ?", ids).Delete(new(T)).Error; err != nil {
+		return err
+	}
+	// 插入全部字段，避免零值被数据库默认值替换
+	return tx.Select("*").CreateInBatches(rows, 100).Error
+}
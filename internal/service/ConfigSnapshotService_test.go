@@ -0,0 +1,90 @@
+package service
+
+import (
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"testing"
+)
+
+func TestConfigRollback(t *testing.T) {
+	initTestDatabase(t)
+
+	name, domain, port := "web", "example.com", uint16(8443)
+	serviceId := uint64(1)
+	uri1, uri2 := "/api", "/v2/api"
+	database.DB.Create(&model.Service{ID: serviceId, Name: &name, Domain: &domain, Port: &port})
+	database.DB.Create(&model.Route{ID: 10, ServiceID: &serviceId, Uri: &uri1, RewriteType: 2})
+	database.DB.Create(&model.Certificate{ID: 100, CertName: "cert"})
+	database.DB.Create(&model.ServiceCertificate{ID: 1000, ServiceID: serviceId, CertID: 100})
+
+	v1, created, err := ConfigSnapshotService.Snapshot("sysadmin", "127.0.0.1", "初始")
+	if err != nil || !created || v1.Version != 1 {
+		t.Fatalf("保存版本失败: %+v %v", v1, err)
+	}
+	// 配置未变化时不保存新版本
+	if same, created, _ := ConfigSnapshotService.Snapshot("sysadmin", "", "无变化"); created || same.Version != 1 {
+		t.Fatalf("配置未变化时不应保存新版本: %+v", same)
+	}
+
+	// 修改路由、删除服务、新增路由，并删除证书
+	database.DB.Model(&model.Route{ID: 10}).Update("uri", uri2)
+	database.DB.Delete(&model.Service{ID: serviceId})
+	database.DB.Create(&model.Route{ID: 11, ServiceID: &serviceId, Uri: &uri1})
+	database.DB.Delete(&model.Certificate{ID: 100})
+	v2, _, err := ConfigSnapshotService.Snapshot("sysadmin", "", "修改")
+	if err != nil || v2.Version != 2 {
+		t.Fatalf("保存版本失败: %+v %v", v2, err)
+	}
+
+	diff, err := ConfigSnapshotService.Diff(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	for _, c := range diff.Changes {
+		types[c.Resource+":"+c.ID] = c.Type
+	}
+	if types["routes:10"] != ConfigChangeModified || types["routes:11"] != ConfigChangeAdded || types["services:1"] != ConfigChangeRemoved {
+		t.Errorf("比较结果错误: %v", types)
+	}
+
+	v3, skipped, err := ConfigSnapshotService.Rollback(1, "sysadmin", model.AdminRoleSystem, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v3.Version != 3 || skipped != 1 {
+		t.Errorf("回滚结果错误: %+v, skipped %d", v3, skipped)
+	}
+	current, err := ConfigSnapshotService.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Services) != 1 || len(current.Routes) != 1 || *current.Routes[0].Uri != uri1 || current.Routes[0].RewriteType != 2 {
+		t.Errorf("回滚后的配置错误: %+v %+v", current.Services, current.Routes)
+	}
+	if len(current.ServiceCertificates) != 0 {
+		t.Errorf("已删除证书的关联不应还原: %+v", current.ServiceCertificates)
+	}
+	// 回滚后与版本1只差已删除证书的关联
+	if diff, _ = ConfigSnapshotService.Diff(1, 0); len(diff.Changes) != 1 || diff.Changes[0].Resource != "serviceCertificates" {
+		t.Errorf("回滚后与版本1的差异错误: %+v", diff.Changes)
+	}
+
+	// 三员分立：回滚会修改脱敏字段时需要安全管理员
+	database.DB.Create(&model.ServiceField{ID: 20, ServiceID: serviceId, FieldName: "phone"})
+	if _, _, err = ConfigSnapshotService.Rollback(v3.Version, "sysadmin", model.AdminRoleSystem, ""); err == nil {
+		t.Errorf("系统管理员不应回滚脱敏字段")
+	} else if e, ok := err.(*ConfigForbiddenError); !ok || len(e.Resources) != 1 || e.Resources[0] != "serviceFields" {
+		t.Errorf("无权回滚的配置错误: %v", err)
+	}
+	if fields, _ := ServiceFieldService.ListAll(); len(fields) != 1 {
+		t.Errorf("无权回滚时不应修改配置")
+	}
+	if _, _, err = ConfigSnapshotService.Rollback(v3.Version, "secadmin", model.AdminRoleSecurity, ""); err != nil {
+		t.Errorf("安全管理员回滚脱敏字段失败: %v", err)
+	}
+
+	if _, _, err = ConfigSnapshotService.Rollback(99, "sysadmin", "", ""); err != ErrConfigSnapshotNotFound {
+		t.Errorf("不存在的版本应返回ErrConfigSnapshotNotFound: %v", err)
+	}
+}
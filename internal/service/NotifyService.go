@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"net/http"
+	"security-gateway/pkg/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+var NotifyService = &notifyService{}
+
+type notifyService struct {
+	mu    sync.RWMutex
+	hooks []NotifyHook
+}
+
+// 通知事件
+const (
+	// NotifyEventCertificateExpiring 证书即将过期
+	NotifyEventCertificateExpiring = "certificate.expiring"
+	// NotifyEventCertificateExpired 证书已过期
+	NotifyEventCertificateExpired = "certificate.expired"
+)
+
+// 通知级别
+const (
+	NotifyLevelWarning = "warning"
+	NotifyLevelError   = "error"
+)
+
+// Notification 通知内容，webhook按JSON格式POST
+type Notification struct {
+	Event   string      `json:"event"`
+	Level   string      `json:"level"`
+	Message string      `json:"message"`
+	Time    int64       `json:"time"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NotifyHook 通知钩子，返回的错误只输出日志
+type NotifyHook func(n *Notification) error
+
+// RegisterHook 注册通知钩子，用于接入邮件、短信等通知方式
+func (s *notifyService) RegisterHook(hook NotifyHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Notify 依次调用注册的通知钩子及配置的webhook(notify.webhooks，逗号分隔)，失败只输出日志
+func (s *notifyService) Notify(n *Notification) {
+	if n.Time == 0 {
+		n.Time = time.Now().UnixMilli()
+	}
+	s.mu.RLock()
+	hooks := append([]NotifyHook(nil), s.hooks...)
+	s.mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(n); err != nil {
+			logger.Errorf("通知钩子执行失败: %s, %v", n.Event, err)
+		}
+	}
+	for _, url := range strings.Split(config.GetString("notify.webhooks"), ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+		if err := postWebhook(url, n); err != nil {
+			logger.Errorf("发送通知失败: %s %s, %v", url, n.Event, err)
+		}
+	}
+}
+
+func postWebhook(url string, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: time.Duration(config.GetInt("notify.timeout", 5000)) * time.Millisecond}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("响应状态码%d", resp.StatusCode)
+	}
+	return nil
+}
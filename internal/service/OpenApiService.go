@@ -0,0 +1,421 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/url"
+	"regexp"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/server"
+	"security-gateway/pkg/util"
+	"sort"
+	"strings"
+)
+
+var OpenApiService = &openApiService{}
+
+type openApiService struct{}
+
+// sensitiveRule 敏感字段的识别规则及建议的脱敏规则，密级越高脱敏越少，"-"表示不脱敏。
+// names为去掉_、-并转为小写后的字段名，以*开头表示包含即可，否则需完全相同
+type sensitiveRule struct {
+	comment string
+	names   []string
+	formats []string
+	levels  [4]string
+}
+
+var sensitiveRules = []*sensitiveRule{
+	{"密码/密钥", []string{"*password", "*passwd", "pwd", "*secret", "*privatekey", "*credential", "*accesstoken", "*refreshtoken"}, []string{"password"}, [4]string{"all-******", "all-******", "all-******", "all-******"}},
+	{"身份证号", []string{"*idcard", "idno", "idnumber", "*identitycard", "*identityno", "*certno", "ssn"}, nil, [4]string{"all-******", "middle-********", "middle-****", "-"}},
+	{"手机号", []string{"*phone", "*mobile", "tel", "*telephone", "cellphone"}, nil, [4]string{"middle-****", "middle-****", "-", "-"}},
+	{"银行卡号", []string{"*bankcard", "*cardno", "*cardnumber", "*bankaccount", "iban"}, nil, [4]string{"all-****", "middle-********", "end-****", "-"}},
+	{"邮箱", []string{"*email", "mail"}, []string{"email", "idn-email"}, [4]string{"start-****", "start-**", "-", "-"}},
+	{"姓名", []string{"realname", "fullname", "truename", "*chinesename"}, nil, [4]string{"start-*", "start-*", "-", "-"}},
+	{"地址", []string{"*address", "addr"}, nil, [4]string{"all-****", "end-******", "-", "-"}},
+	{"出生日期", []string{"birthday", "birthdate", "dateofbirth", "dob"}, nil, [4]string{"all-****", "end-*****", "-", "-"}},
+	{"IP地址", []string{"ip", "ipaddr", "clientip"}, []string{"ipv4", "ipv6"}, [4]string{"end-***", "-", "-", "-"}},
+}
+
+// match 字段是否为敏感字段，返回判断的原因
+func (r *sensitiveRule) match(name, format string) string {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, n := range r.names {
+		if (strings.HasPrefix(n, "*") && strings.Contains(normalized, n[1:])) || normalized == n {
+			return "字段名疑似" + r.comment
+		}
+	}
+	for _, f := range r.formats {
+		if strings.EqualFold(format, f) {
+			return "字段格式" + format + "疑似" + r.comment
+		}
+	}
+	return ""
+}
+
+var openApiParamRegex = regexp.MustCompile(`\{[^}/]*\}`)
+
+// openApiPath 将OpenAPI路径转换为路由路径，路径参数转换为{}包裹的正则表达式片段，如/users/{id}转换为/users/{^[^/]+$}
+func openApiPath(prefix, path string) string {
+	segments := strings.Split(strings.TrimSuffix(prefix, "/")+path, "/")
+	for i, segment := range segments {
+		if !openApiParamRegex.MatchString(segment) {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range openApiParamRegex.FindAllStringIndex(segment, -1) {
+			b.WriteString(regexp.QuoteMeta(segment[last:loc[0]]))
+			b.WriteString("[^/]+")
+			last = loc[1]
+		}
+		b.WriteString(regexp.QuoteMeta(segment[last:]))
+		segments[i] = "{^" + b.String() + "$}"
+	}
+	return strings.Join(segments, "/")
+}
+
+// openApiPrefix 文档第一个服务地址的路径作为路由前缀，如https://example.com/api/v1中的/api/v1
+func openApiPrefix(doc *domain.OpenApiDocument) string {
+	if len(doc.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(doc.Servers[0].Url)
+	if err != nil || strings.Contains(u.Path, "{") {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// Plan 解析OpenAPI文档，生成服务下的路由及建议的路由脱敏字段，不修改配置。
+// prefix为路由前缀，为nil时使用文档第一个服务地址的路径
+func (u *openApiService) Plan(serviceID uint64, data []byte, prefix *string) (plan *domain.OpenApiImportPlan, err error) {
+	doc, err := domain.ParseOpenApiDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析OpenAPI文档失败: %w", err)
+	}
+	serv := new(model.Service)
+	if err = database.DB.Where("id = ?", serviceID).Limit(1).Find(serv).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if serv.ID == 0 {
+		return nil, errors.New("服务不存在")
+	}
+	var existingRoutes []*model.Route
+	if err = database.DB.Where("service_id = ?", serviceID).Find(&existingRoutes).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	var serviceFields []*model.ServiceField
+	if err = database.DB.Where("service_id = ?", serviceID).Find(&serviceFields).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	serviceFieldNames := make(map[string]bool, len(serviceFields))
+	for _, f := range serviceFields {
+		serviceFieldNames[f.FieldName] = true
+	}
+
+	if prefix == nil {
+		p := openApiPrefix(doc)
+		prefix = &p
+	}
+	plan = &domain.OpenApiImportPlan{ServiceID: serviceID}
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		if item == nil {
+			continue
+		}
+		methods, operations := item.Operations()
+		if len(operations) == 0 {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("路径%s没有请求方法，已忽略", path))
+			continue
+		}
+		route := &domain.OpenApiRoute{
+			Uri:     openApiPath(*prefix, path),
+			Methods: strings.Join(methods, ","),
+			Source:  path,
+			Summary: item.Summary,
+		}
+		if route.Summary == "" {
+			route.Summary = operations[0].Summary
+		}
+
+		var routeFieldNames map[string]bool
+		key := domain.RouteKey(route.Uri, route.Methods, "", "", "")
+		for _, r := range existingRoutes {
+			methods, headers, queries, cookies := r.PredicateValues()
+			if r.Uri != nil && key == domain.RouteKey(*(r.Uri), methods, headers, queries, cookies) {
+				route.RouteID = r.ID
+				break
+			}
+		}
+		if route.RouteID != 0 {
+			var routeFields []*model.RouteField
+			if err = database.DB.Where("route_id = ?", route.RouteID).Find(&routeFields).Error; err != nil {
+				logger.Errorln(err)
+				return
+			}
+			routeFieldNames = make(map[string]bool, len(routeFields))
+			for _, f := range routeFields {
+				routeFieldNames[f.FieldName] = true
+			}
+		}
+		scanner := &openApiScanner{doc: doc, visited: make(map[*domain.OpenApiSchema]bool), fields: make(map[string]*domain.OpenApiField)}
+		for _, operation := range operations {
+			for code, response := range operation.Responses {
+				if strings.HasPrefix(code, "2") || code == "default" {
+					scanner.scanResponse(response, 0)
+				}
+			}
+		}
+		names := make([]string, 0, len(scanner.fields))
+		for name := range scanner.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			field := scanner.fields[name]
+			field.Exists = serviceFieldNames[name] || routeFieldNames[name]
+			route.Fields = append(route.Fields, field)
+		}
+		plan.Routes = append(plan.Routes, route)
+	}
+	if serv.Port != nil {
+		if e := ServiceService.CheckPort(*(serv.Port)); e != nil {
+			plan.Warnings = append(plan.Warnings, "服务"+e.Error())
+		}
+	}
+	validateOpenApiRoutes(existingRoutes, plan.Routes)
+	return
+}
+
+// validateOpenApiRoutes 按文档中的顺序校验新建的路由与服务下已有的路由及前面新建的路由是否冲突，错误保存在路由的Errors中
+func validateOpenApiRoutes(existingRoutes []*model.Route, routes []*domain.OpenApiRoute) (errs []string) {
+	specs := make([]*server.RouteSpec, 0, len(existingRoutes)+len(routes))
+	for _, r := range existingRoutes {
+		if r.Uri != nil {
+			specs = append(specs, routeSpec(r))
+		}
+	}
+	for _, route := range routes {
+		if route.RouteID != 0 {
+			continue
+		}
+		spec := &server.RouteSpec{
+			// 新建的路由ID更大，同一路径下排在已有路由后面
+			Key:       fmt.Sprintf("~%05d", len(specs)),
+			Path:      route.Uri,
+			Predicate: server.ParsePredicate(route.Methods, "", "", "", 0),
+		}
+		route.Errors = server.ValidateRoute(spec, specs)
+		for _, e := range route.Errors {
+			errs = append(errs, route.Uri+": "+e)
+		}
+		specs = append(specs, spec)
+	}
+	return
+}
+
+// openApiScanner 扫描响应结构中的敏感字段，引用的结构只扫描一次，避免循环引用
+type openApiScanner struct {
+	doc     *domain.OpenApiDocument
+	visited map[*domain.OpenApiSchema]bool
+	fields  map[string]*domain.OpenApiField
+}
+
+// openApiMaxDepth 扫描的最大嵌套层数
+const openApiMaxDepth = 32
+
+func (s *openApiScanner) scanResponse(response *domain.OpenApiResponse, depth int) {
+	if response == nil || depth > openApiMaxDepth {
+		return
+	}
+	if response.Ref != "" {
+		s.scanResponse(s.doc.Components.Responses[refName(response.Ref)], depth+1)
+		return
+	}
+	for contentType, media := range response.Content {
+		if media != nil && strings.Contains(contentType, "json") {
+			s.scanSchema("", media.Schema, depth+1)
+		}
+	}
+}
+
+func (s *openApiScanner) scanSchema(name string, schema *domain.OpenApiSchema, depth int) {
+	if schema == nil || depth > openApiMaxDepth {
+		return
+	}
+	if name != "" {
+		s.checkField(name, schema)
+	}
+	if schema.Ref != "" {
+		ref := s.doc.Components.Schemas[refName(schema.Ref)]
+		if ref == nil || s.visited[ref] {
+			return
+		}
+		s.visited[ref] = true
+		s.scanSchema(name, ref, depth+1)
+		return
+	}
+	for propName, prop := range schema.Properties {
+		s.scanSchema(propName, prop, depth+1)
+	}
+	// 数组元素的字段名与数组相同
+	s.scanSchema(name, schema.Items, depth+1)
+	for _, list := range [][]*domain.OpenApiSchema{schema.AllOf, schema.OneOf, schema.AnyOf} {
+		for _, sub := range list {
+			s.scanSchema("", sub, depth+1)
+		}
+	}
+}
+
+// checkField 按字段名及格式判断是否为敏感字段，只处理字符串、数字等基本类型的字段
+func (s *openApiScanner) checkField(name string, schema *domain.OpenApiSchema) {
+	if _, ok := s.fields[name]; ok {
+		return
+	}
+	switch schema.Type {
+	case "object", "array":
+		return
+	}
+	if schema.Ref != "" || len(schema.Properties) > 0 {
+		return
+	}
+	for _, rule := range sensitiveRules {
+		if reason := rule.match(name, schema.Format); reason != "" {
+			comment := rule.comment
+			if title := []rune(schema.Title); len(title) > 0 && len(title) <= 50 {
+				comment = schema.Title
+			}
+			s.fields[name] = &domain.OpenApiField{
+				FieldName: name,
+				Comment:   comment,
+				Reason:    reason,
+				Level1:    rule.levels[0],
+				Level2:    rule.levels[1],
+				Level3:    rule.levels[2],
+				Level4:    rule.levels[3],
+			}
+			return
+		}
+	}
+}
+
+// refName 文档内引用的名称，如#/components/schemas/User中的User
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// Apply 应用审核后的路由及脱敏字段：已存在的路由只新增脱敏字段，新建的路由在指定上游时转发到该上游，
+// 已配置同名脱敏字段时跳过，全部在一个事务中执行。路由校验失败时返回ConfigValidationError
+func (u *openApiService) Apply(req *domain.OpenApiApplyRequest) (result *domain.OpenApiApplyResult, err error) {
+	if req.ServiceID == 0 {
+		return nil, errors.New("服务不能为空")
+	}
+	serv := new(model.Service)
+	if err = database.DB.Where("id = ?", req.ServiceID).Limit(1).Find(serv).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if serv.ID == 0 {
+		return nil, errors.New("服务不存在")
+	}
+	var existingRoutes []*model.Route
+	if err = database.DB.Where("service_id = ?", req.ServiceID).Find(&existingRoutes).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	existingIDs := make(map[uint64]bool, len(existingRoutes))
+	for _, r := range existingRoutes {
+		existingIDs[r.ID] = true
+	}
+	var errs []string
+	for _, r := range req.Routes {
+		if r.RouteID != 0 && !existingIDs[r.RouteID] {
+			errs = append(errs, fmt.Sprintf("路由%s不属于该服务", r.Uri))
+		}
+	}
+	if serv.Port != nil {
+		if e := ServiceService.CheckPort(*(serv.Port)); e != nil {
+			errs = append(errs, "服务"+e.Error())
+		}
+	}
+	errs = append(errs, validateOpenApiRoutes(existingRoutes, req.Routes)...)
+	if len(errs) > 0 {
+		return nil, &ConfigValidationError{Errors: errs}
+	}
+
+	result = new(domain.OpenApiApplyResult)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range req.Routes {
+			routeID := r.RouteID
+			if routeID == 0 {
+				methods := r.Methods
+				route := &model.Route{
+					ID:          util.SnowflakeId(),
+					ServiceID:   &req.ServiceID,
+					Uri:         &r.Uri,
+					Methods:     &methods,
+					LoadBalance: model.LoadBalanceRoundRobin,
+					RewriteType: model.RouteRewriteKeep,
+				}
+				if err := tx.Create(route).Error; err != nil {
+					return err
+				}
+				routeID = route.ID
+				result.CreatedRoutes++
+				if req.UpstreamID != 0 {
+					target := &model.RouteTarget{ID: util.SnowflakeId(), RouteID: &routeID, UpstreamID: &req.UpstreamID, Weight: 1}
+					if err := tx.Create(target).Error; err != nil {
+						return err
+					}
+					result.CreatedTargets++
+				}
+			}
+			for _, f := range r.Fields {
+				if f.FieldName == "" {
+					continue
+				}
+				var c int64
+				if err := tx.Model(&model.RouteField{}).Where("route_id = ? and field_name = ?", routeID, f.FieldName).Count(&c).Error; err != nil {
+					return err
+				}
+				if c > 0 {
+					continue
+				}
+				field := &model.RouteField{
+					ID:        util.SnowflakeId(),
+					RouteID:   routeID,
+					FieldName: f.FieldName,
+					Comment:   f.Comment,
+					Level1:    f.Level1,
+					Level2:    f.Level2,
+					Level3:    f.Level3,
+					Level4:    f.Level4,
+				}
+				if err := tx.Create(field).Error; err != nil {
+					return err
+				}
+				result.CreatedFields++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	return
+}
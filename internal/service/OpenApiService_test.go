@@ -0,0 +1,151 @@
+package service
+
+import (
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"testing"
+)
+
+const testOpenApiDocument = `
+openapi: 3.0.3
+servers:
+  - url: https://example.com/api/v1
+paths:
+  /users/{id}:
+    get:
+      summary: 用户详情
+      responses:
+        200:
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+    delete:
+      responses:
+        "204":
+          description: 已删除
+  /users/{id}/avatar.{ext}:
+    get:
+      responses:
+        "200":
+          description: 头像
+  /orders:
+    get:
+      responses:
+        "200":
+          $ref: '#/components/responses/Orders'
+components:
+  responses:
+    Orders:
+      content:
+        application/json:
+          schema:
+            type: array
+            items:
+              type: object
+              properties:
+                receiver_phone:
+                  type: string
+                address:
+                  type: [string, "null"]
+                user:
+                  $ref: '#/components/schemas/User'
+  schemas:
+    User:
+      type: object
+      properties:
+        id:
+          type: integer
+        realName:
+          type: string
+          title: 真实姓名
+        contact:
+          type: string
+          format: email
+        idCard:
+          type: string
+        friends:
+          type: array
+          items:
+            $ref: '#/components/schemas/User'
+`
+
+func TestOpenApiPlanAndApply(t *testing.T) {
+	initTestDatabase(t)
+	serviceID, port, domainName := uint64(10), uint16(18443), "example.com"
+	database.DB.Create(&model.Service{ID: serviceID, Port: &port, Domain: &domainName})
+	database.DB.Create(&model.ServiceField{ID: 11, ServiceID: serviceID, FieldName: "address"})
+
+	plan, err := OpenApiService.Plan(serviceID, []byte(testOpenApiDocument), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Routes) != 3 {
+		t.Fatalf("应生成3个路由: %+v", plan.Routes)
+	}
+	routes := make(map[string]*domain.OpenApiRoute)
+	for _, r := range plan.Routes {
+		if len(r.Errors) > 0 {
+			t.Errorf("路由%s不应有校验错误: %v", r.Uri, r.Errors)
+		}
+		routes[r.Source] = r
+	}
+	user := routes["/users/{id}"]
+	if user == nil || user.Uri != "/api/v1/users/{^[^/]+$}" || user.Methods != "GET,DELETE" {
+		t.Fatalf("路径参数转换错误: %+v", user)
+	}
+	if avatar := routes["/users/{id}/avatar.{ext}"]; avatar == nil || avatar.Uri != `/api/v1/users/{^[^/]+$}/{^avatar\.[^/]+$}` {
+		t.Errorf("部分路径参数转换错误: %+v", avatar)
+	}
+	fields := make(map[string]*domain.OpenApiField)
+	for _, f := range user.Fields {
+		fields[f.FieldName] = f
+	}
+	if len(fields) != 3 || fields["realName"] == nil || fields["contact"] == nil || fields["idCard"] == nil {
+		t.Fatalf("用户接口的敏感字段错误: %+v", user.Fields)
+	}
+	if fields["realName"].Comment != "真实姓名" || fields["contact"].Level1 == "" {
+		t.Errorf("建议的脱敏规则错误: %+v %+v", fields["realName"], fields["contact"])
+	}
+	orderFields := make(map[string]*domain.OpenApiField)
+	for _, f := range routes["/orders"].Fields {
+		orderFields[f.FieldName] = f
+	}
+	if orderFields["receiver_phone"] == nil || orderFields["idCard"] == nil || orderFields["address"] == nil || !orderFields["address"].Exists {
+		t.Errorf("订单接口的敏感字段错误: %+v", routes["/orders"].Fields)
+	}
+
+	// 只应用审核通过的字段
+	user.Fields = []*domain.OpenApiField{fields["idCard"]}
+	result, err := OpenApiService.Apply(&domain.OpenApiApplyRequest{ServiceID: serviceID, UpstreamID: 20, Routes: []*domain.OpenApiRoute{user}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.CreatedRoutes != 1 || result.CreatedFields != 1 || result.CreatedTargets != 1 {
+		t.Errorf("应用结果错误: %+v", result)
+	}
+
+	// 再次生成时关联已存在的路由，重复应用不会新增
+	plan, err = OpenApiService.Plan(serviceID, []byte(testOpenApiDocument), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range plan.Routes {
+		if r.Source == "/users/{id}" {
+			if r.RouteID == 0 {
+				t.Fatal("应关联已存在的路由")
+			}
+			result, err = OpenApiService.Apply(&domain.OpenApiApplyRequest{ServiceID: serviceID, Routes: []*domain.OpenApiRoute{r}})
+			if err != nil || result.CreatedRoutes != 0 || result.CreatedFields != 2 {
+				t.Errorf("重复应用结果错误: %+v %v", result, err)
+			}
+		}
+	}
+
+	// 与已有路由冲突时不应用
+	conflict := &domain.OpenApiRoute{Uri: "/api/v1/users/{^[^/]+$}", Methods: "GET"}
+	if _, err = OpenApiService.Apply(&domain.OpenApiApplyRequest{ServiceID: serviceID, Routes: []*domain.OpenApiRoute{conflict}}); err == nil {
+		t.Error("冲突的路由应校验失败")
+	}
+}
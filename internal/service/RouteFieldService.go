@@ -130,3 +130,11 @@ func (u *routeFieldService) GetByRouteID(routeID uint64) (instances []*model.Rou
 	}
 	return
 }
+
+// ListAll 获取全部路由字段，用于从数据库同步反向代理配置
+func (u *routeFieldService) ListAll() (instances []*model.RouteField, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
@@ -6,7 +6,9 @@ import (
 	"security-gateway/internal/domain"
 	"security-gateway/internal/model"
 	"security-gateway/pkg/database"
+	"security-gateway/pkg/server"
 	"security-gateway/pkg/util"
+	"strconv"
 )
 
 var RouteService = &routeService{}
@@ -17,12 +19,15 @@ func (u *routeService) Add(instance *model.Route) (duplicated, success bool, err
 	if instance.ServiceID == nil || instance.Uri == nil || *(instance.ServiceID) == 0 || *(instance.Uri) == "" {
 		return
 	}
-	// 检查是否有名称或者url重复
+	// 检查是否有路径和匹配条件都相同的路由
+	methods, headers, queries, cookies := instance.PredicateValues()
+	instance.Methods, instance.MatchHeaders, instance.MatchQueries, instance.MatchCookies = &methods, &headers, &queries, &cookies
 	var c int64
 	err = database.DB.Model(&model.Route{}).Where(&model.Route{
 		ServiceID: instance.ServiceID,
 		Uri:       instance.Uri,
-	}).Count(&c).Error
+	}).Where("coalesce(methods, '') = ? and coalesce(match_headers, '') = ? and coalesce(match_queries, '') = ? and coalesce(match_cookies, '') = ?",
+		methods, headers, queries, cookies).Count(&c).Error
 	if err != nil {
 		logger.Errorln(err)
 		return
@@ -69,6 +74,83 @@ func (u *routeService) Update(instance *model.Route) (duplicated, success bool,
 	return
 }
 
+// Validate 校验新增或修改后的路由：路径格式及正则表达式、与同一服务下其他路由是否重复或相互遮蔽、
+// 服务端口是否与管理接口冲突，返回全部校验错误。修改时未传的字段使用已保存的值
+func (u *routeService) Validate(instance *model.Route) (errs []string, err error) {
+	route := *instance
+	if instance.ID != 0 {
+		existing := new(model.Route)
+		if err = database.DB.Where("id = ?", instance.ID).Limit(1).Find(existing).Error; err != nil {
+			logger.Errorln(err)
+			return
+		}
+		if existing.ID != 0 {
+			// 与Updates一致，空值不会更新
+			route = *existing
+			if instance.ServiceID != nil && *(instance.ServiceID) != 0 {
+				route.ServiceID = instance.ServiceID
+			}
+			if instance.Uri != nil && *(instance.Uri) != "" {
+				route.Uri = instance.Uri
+			}
+			if instance.Methods != nil {
+				route.Methods = instance.Methods
+			}
+			if instance.MatchHeaders != nil {
+				route.MatchHeaders = instance.MatchHeaders
+			}
+			if instance.MatchQueries != nil {
+				route.MatchQueries = instance.MatchQueries
+			}
+			if instance.MatchCookies != nil {
+				route.MatchCookies = instance.MatchCookies
+			}
+			if instance.Priority != 0 {
+				route.Priority = instance.Priority
+			}
+		}
+	}
+	if route.ServiceID == nil || route.Uri == nil {
+		return
+	}
+
+	serv := new(model.Service)
+	if err = database.DB.Where("id = ?", *(route.ServiceID)).Limit(1).Find(serv).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if serv.Port != nil {
+		if e := ServiceService.CheckPort(*(serv.Port)); e != nil {
+			errs = append(errs, "服务"+e.Error())
+		}
+	}
+
+	var others []*model.Route
+	if err = database.DB.Where("service_id = ? and id <> ?", *(route.ServiceID), route.ID).Find(&others).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	specs := make([]*server.RouteSpec, 0, len(others))
+	for _, other := range others {
+		if other.Uri != nil {
+			specs = append(specs, routeSpec(other))
+		}
+	}
+	errs = append(errs, server.ValidateRoute(routeSpec(&route), specs)...)
+	return
+}
+
+// routeSpec 路由校验使用的路径及匹配条件，新增的路由没有ID
+func routeSpec(route *model.Route) *server.RouteSpec {
+	spec := &server.RouteSpec{Path: *(route.Uri)}
+	if route.ID != 0 {
+		spec.Key = strconv.FormatUint(route.ID, 10)
+	}
+	methods, headers, queries, cookies := route.PredicateValues()
+	spec.Predicate = server.ParsePredicate(methods, headers, queries, cookies, route.Priority)
+	return spec
+}
+
 func (u *routeService) Delete(id uint64) (success bool, err error) {
 	if id == 0 {
 		logger.Error("ID is required")
@@ -188,3 +270,11 @@ func (u *routeService) ListWithTargets(page, pageSize int, condition *model.Rout
 	err = nil
 	return
 }
+
+// ListAll 获取全部路由，用于从数据库同步反向代理配置
+func (u *routeService) ListAll() (instances []*model.Route, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
@@ -167,3 +167,11 @@ func (u *routeTargetService) GetByRouteIDAndUpstreamID(routeID uint64, upstreamI
 	}
 	return instanceList[0], nil
 }
+
+// ListAll 获取全部路由目标，用于从数据库同步反向代理配置
+func (u *routeTargetService) ListAll() (instances []*model.RouteTarget, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
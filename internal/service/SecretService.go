@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"security-gateway/internal/domain"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+)
+
+var SecretService = &secretService{}
+
+type secretService struct{}
+
+// secretColumns 使用serializer:secret加密保存的字段
+var secretColumns = []struct {
+	resource string
+	model    interface{}
+	columns  []string
+}{
+	{"certificate", &model.Certificate{}, []string{"key_pem", "sign_key_pem", "enc_key_pem"}},
+	{"userInfoRoute", &model.UserInfoRoute{}, []string{"jwt_key", "client_secret"}},
+}
+
+// SecretRotateResult 重新加密的记录数量，按资源类型统计
+type SecretRotateResult struct {
+	KeyID   string         `json:"keyId"`
+	Rotated map[string]int `json:"rotated"`
+}
+
+// Rotate 使用当前的主密钥重新加密全部敏感字段及配置版本中的密钥，加密前保存的明文直接加密，dryRun时只统计需要重新加密的记录。
+// 按map读取及修改不经过字段序列化，直接处理数据库中保存的值
+func (s *secretService) Rotate(dryRun bool) (result *SecretRotateResult, err error) {
+	if !secret.Enabled() {
+		return nil, secret.ErrNoMasterKey
+	}
+	result = &SecretRotateResult{KeyID: secret.ActiveKeyID(), Rotated: make(map[string]int)}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, sc := range secretColumns {
+			var rows []map[string]interface{}
+			if e := tx.Model(sc.model).Select(append([]string{"id"}, sc.columns...)).Find(&rows).Error; e != nil {
+				return e
+			}
+			for _, row := range rows {
+				updates := make(map[string]interface{})
+				for _, column := range sc.columns {
+					value := columnString(row[column])
+					if !secret.NeedsRotate(value) {
+						continue
+					}
+					rotated, e := secret.Rotate(value)
+					if e != nil {
+						return fmt.Errorf("%s %v %s: %w", sc.resource, row["id"], column, e)
+					}
+					updates[column] = rotated
+				}
+				if len(updates) == 0 {
+					continue
+				}
+				result.Rotated[sc.resource]++
+				if dryRun {
+					continue
+				}
+				if e := tx.Model(sc.model).Where("id = ?", row["id"]).UpdateColumns(updates).Error; e != nil {
+					return e
+				}
+			}
+		}
+		return s.rotateSnapshots(tx, result, dryRun)
+	})
+	if err != nil {
+		logger.Errorln(err)
+		return nil, err
+	}
+	return
+}
+
+// rotateSnapshots 重新加密配置版本中保存的用户信息路由密钥，配置版本的摘要按密钥的SM3摘要计算，重新加密后不变，
+// 轮换后回滚到轮换前的版本不再需要旧的主密钥
+func (s *secretService) rotateSnapshots(tx *gorm.DB, result *SecretRotateResult, dryRun bool) error {
+	var snapshots []*model.ConfigSnapshot
+	if err := tx.Select("id", "version", "content").Find(&snapshots).Error; err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		c, err := domain.ParseGatewayConfig([]byte(snapshot.Content))
+		if err != nil {
+			return fmt.Errorf("configSnapshot %d: %w", snapshot.Version, err)
+		}
+		changed := false
+		rotated, err := c.MapSecrets(func(value string) (string, error) {
+			if !secret.NeedsRotate(value) {
+				return value, nil
+			}
+			changed = true
+			return secret.Rotate(value)
+		})
+		if err != nil {
+			return fmt.Errorf("configSnapshot %d: %w", snapshot.Version, err)
+		}
+		if !changed {
+			continue
+		}
+		result.Rotated["configSnapshot"]++
+		if dryRun {
+			continue
+		}
+		content, err := json.Marshal(rotated)
+		if err != nil {
+			return err
+		}
+		if err = tx.Model(&model.ConfigSnapshot{}).Where("id = ?", snapshot.ID).UpdateColumn("content", string(content)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func columnString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
+	"strings"
+	"testing"
+)
+
+func TestSecretRotate(t *testing.T) {
+	initTestDatabase(t)
+	t.Cleanup(func() { _ = secret.Initial() })
+	useMasterKey := func(keys string) {
+		t.Helper()
+		t.Setenv("SG_MASTER_KEY", keys)
+		if err := secret.Initial(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	useMasterKey("k1:000102030405060708090a0b0c0d0e0f")
+	database.DB.Create(&model.UserInfoRoute{ID: 1, ServiceID: 1, JwtKey: "jwt-key"})
+	v1, _, err := ConfigSnapshotService.Snapshot("sysadmin", "", "初始")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useMasterKey("k2:101112131415161718191a1b1c1d1e1f,k1:000102030405060708090a0b0c0d0e0f")
+	result, err := SecretService.Rotate(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rotated["userInfoRoute"] != 1 || result.Rotated["configSnapshot"] != 1 {
+		t.Errorf("重新加密的数量错误: %+v", result.Rotated)
+	}
+	snapshot, _ := ConfigSnapshotService.GetByVersion(v1.Version)
+	if !strings.Contains(snapshot.Content, secret.Prefix+"sm4:k2:") || strings.Contains(snapshot.Content, ":k1:") {
+		t.Errorf("配置版本中的密钥应使用新密钥加密: %s", snapshot.Content)
+	}
+	if snapshot.Digest != v1.Digest {
+		t.Errorf("重新加密后配置版本的摘要不应变化")
+	}
+	if result, _ = SecretService.Rotate(true); len(result.Rotated) != 0 {
+		t.Errorf("已重新加密的记录不应再次加密: %+v", result.Rotated)
+	}
+
+	// 移除旧密钥后可以回滚到轮换前的版本
+	useMasterKey("k2:101112131415161718191a1b1c1d1e1f")
+	database.DB.Model(&model.UserInfoRoute{ID: 1}).Update("jwt_key", "changed")
+	if _, _, err = ConfigSnapshotService.Rollback(v1.Version, "sysadmin", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	uir := new(model.UserInfoRoute)
+	if err = database.DB.First(uir, 1).Error; err != nil || uir.JwtKey != "jwt-key" {
+		t.Errorf("回滚后的密钥错误: %q, %v", uir.JwtKey, err)
+	}
+}
@@ -130,3 +130,11 @@ func (u *serviceFieldService) GetByServiceID(serviceID uint64) (instances []*mod
 	}
 	return
 }
+
+// ListAll 获取全部服务字段，用于从数据库同步反向代理配置
+func (u *serviceFieldService) ListAll() (instances []*model.ServiceField, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
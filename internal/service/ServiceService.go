@@ -1,9 +1,11 @@
 package service
 
 import (
+	"fmt"
 	logger "github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 	"security-gateway/internal/model"
+	"security-gateway/pkg/config"
 	"security-gateway/pkg/database"
 	"security-gateway/pkg/util"
 )
@@ -179,6 +181,14 @@ func (u *serviceService) GetByDomainAndPort(domain string, port uint16) (*model.
 	return instance, nil
 }
 
+// CheckPort 检查服务端口是否与管理接口的端口(server.port)冲突
+func (u *serviceService) CheckPort(port uint16) error {
+	if int(port) == config.GetInt("server.port", 8080) {
+		return fmt.Errorf("端口%d与管理接口端口冲突", port)
+	}
+	return nil
+}
+
 func (u *serviceService) GetAllPorts() (ports []uint16, err error) {
 	err = database.DB.Model(&model.Service{}).Distinct().Pluck("port", &ports).Error
 	if err != nil {
@@ -208,3 +218,11 @@ func (u *serviceService) UpdateCert(instance *model.Service) (success bool, err
 	success = true
 	return
 }
+
+// ListAll 获取全部服务，用于从数据库同步反向代理配置
+func (u *serviceService) ListAll() (instances []*model.Service, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/util"
+	"time"
+)
+
+var TokenService = &tokenService{}
+
+type tokenService struct{}
+
+// Save 保存token，已存在时更新密级、用户名和过期时间。
+// 查询条件不使用结构体，结构体条件会忽略零值，默认域名为空字符串
+func (u *tokenService) Save(instance *model.Token) (err error) {
+	existing := new(model.Token)
+	err = database.DB.Where("port = ? AND domain = ? AND token_hash = ?", instance.Port, instance.Domain, instance.TokenHash).Limit(1).Find(existing).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if existing.ID != 0 {
+		instance.ID = existing.ID
+		err = database.DB.Model(&model.Token{ID: existing.ID}).Updates(map[string]interface{}{
+			"username":     instance.Username,
+			"secret_level": instance.SecretLevel,
+			"expire_time":  instance.ExpireTime,
+			"fixed_expire": instance.FixedExpire,
+		}).Error
+	} else {
+		instance.ID = util.SnowflakeId()
+		err = database.DB.Create(instance).Error
+	}
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Get 获取未过期的token，过期时间未固定时延长至expireTime
+func (u *tokenService) Get(port uint16, domain, tokenHash string, expireTime int64) (instance *model.Token, err error) {
+	instance = new(model.Token)
+	err = database.DB.Where("port = ? AND domain = ? AND token_hash = ?", port, domain, tokenHash).
+		Where("expire_time > ?", time.Now().UnixMilli()).Limit(1).Find(instance).Error
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if instance.ID == 0 {
+		instance = nil
+		return
+	}
+	if instance.FixedExpire {
+		return
+	}
+	if err = database.DB.Model(&model.Token{ID: instance.ID}).Update("expire_time", expireTime).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Touch 批量延长未固定过期时间的token
+func (u *tokenService) Touch(tokenHashes []string, expireTime int64) (err error) {
+	err = database.DB.Model(&model.Token{}).Where("token_hash IN ?", tokenHashes).Where("fixed_expire = ?", false).Update("expire_time", expireTime).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// UpdateUserLevel 修改用户在服务下所有token的密级
+func (u *tokenService) UpdateUserLevel(port uint16, domain, username string, secretLevel int) (err error) {
+	err = database.DB.Model(&model.Token{}).Where("port = ? AND domain = ? AND username = ?", port, domain, username).
+		Update("secret_level", secretLevel).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// UpdateUserAllLevel 修改用户在所有服务下token的密级
+func (u *tokenService) UpdateUserAllLevel(username string, secretLevel int) (err error) {
+	if username == "" {
+		return
+	}
+	err = database.DB.Model(&model.Token{}).Where(&model.Token{Username: username}).
+		Update("secret_level", secretLevel).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// Delete 删除token
+func (u *tokenService) Delete(port uint16, domain, tokenHash string) (err error) {
+	err = database.DB.Where("port = ? AND domain = ? AND token_hash = ?", port, domain, tokenHash).Delete(&model.Token{}).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// ListByUsername 获取用户在所有服务下未过期的token
+func (u *tokenService) ListByUsername(username string) (list []*model.Token, err error) {
+	if username == "" {
+		return
+	}
+	err = database.DB.Where(&model.Token{Username: username}).Where("expire_time > ?", time.Now().UnixMilli()).
+		Order("create_time desc").Find(&list).Error
+	if err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
+
+// DeleteByUsername 删除用户的token，tokenHashes为空时删除用户所有token，返回删除的数量
+func (u *tokenService) DeleteByUsername(username string, tokenHashes []string) (count int64, err error) {
+	if username == "" {
+		return
+	}
+	tx := database.DB.Where(&model.Token{Username: username})
+	if len(tokenHashes) > 0 {
+		tx = tx.Where("token_hash IN ?", tokenHashes)
+	}
+	result := tx.Delete(&model.Token{})
+	if err = result.Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	count = result.RowsAffected
+	return
+}
+
+// DeleteExpired 删除已过期的token，返回删除的数量
+func (u *tokenService) DeleteExpired() (count int64, err error) {
+	result := database.DB.Where("expire_time <= ?", time.Now().UnixMilli()).Delete(&model.Token{})
+	if err = result.Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
+	count = result.RowsAffected
+	return
+}
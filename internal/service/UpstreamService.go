@@ -197,3 +197,11 @@ func (u *upstreamService) ListByRoute(page, pageSize int, routeId uint64) (insta
 
 	return
 }
+
+// ListAll 获取全部上游，用于从数据库同步反向代理配置
+func (u *upstreamService) ListAll() (instances []*model.Upstream, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
@@ -4,6 +4,7 @@ import (
 	logger "github.com/sirupsen/logrus"
 	"security-gateway/internal/model"
 	"security-gateway/pkg/database"
+	"security-gateway/pkg/secret"
 	"security-gateway/pkg/util"
 	"strings"
 )
@@ -33,6 +34,7 @@ func (u *userInfoRouteService) Add(instance *model.UserInfoRoute) (duplicated, s
 
 	instance.ID = util.SnowflakeId()
 	instance.Method = strings.ToUpper(instance.Method)
+	instance.LogoutMethod = strings.ToUpper(instance.LogoutMethod)
 	if err = database.DB.Create(instance).Error; err != nil {
 		logger.Errorln(err)
 		return
@@ -59,11 +61,37 @@ func (u *userInfoRouteService) Update(instance *model.UserInfoRoute) (duplicated
 	}
 
 	instance.Method = strings.ToUpper(instance.Method)
+	instance.LogoutMethod = strings.ToUpper(instance.LogoutMethod)
 
 	if err = database.DB.Model(&model.UserInfoRoute{ID: instance.ID}).Updates(instance).Error; err != nil {
 		logger.Errorln(err)
 		return
 	}
+	// token类型、JWT、令牌内省及退出登录配置允许清空，按map修改时不经过字段序列化，密钥需先加密
+	var jwtKey, clientSecret string
+	if jwtKey, err = secret.Encrypt(instance.JwtKey); err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if clientSecret, err = secret.Encrypt(instance.ClientSecret); err != nil {
+		logger.Errorln(err)
+		return
+	}
+	if err = database.DB.Model(&model.UserInfoRoute{ID: instance.ID}).Updates(map[string]interface{}{
+		"token_type":        instance.TokenType,
+		"jwt_algorithms":    instance.JwtAlgorithms,
+		"jwt_key":           jwtKey,
+		"jwks_url":          instance.JwksUrl,
+		"introspection_url": instance.IntrospectionUrl,
+		"client_id":         instance.ClientId,
+		"client_secret":     clientSecret,
+		"logout_path":       instance.LogoutPath,
+		"logout_method":     instance.LogoutMethod,
+		"logout_check":      instance.LogoutCheck,
+	}).Error; err != nil {
+		logger.Errorln(err)
+		return
+	}
 	success = true
 	return
 }
@@ -146,3 +174,11 @@ func (u *userInfoRouteService) GetByServiceID(serviceID uint64) (*model.UserInfo
 	}
 	return result[0], nil
 }
+
+// ListAll 获取全部用户信息接口，用于从数据库同步反向代理配置
+func (u *userInfoRouteService) ListAll() (instances []*model.UserInfoRoute, err error) {
+	if err = database.DB.Find(&instances).Error; err != nil {
+		logger.Errorln(err)
+	}
+	return
+}
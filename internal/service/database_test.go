@@ -0,0 +1,26 @@
+package service
+
+import (
+	"fmt"
+	"security-gateway/internal/model"
+	"security-gateway/pkg/database"
+	"security-gateway/pkg/util"
+	"strings"
+	"testing"
+)
+
+// initTestDatabase 每个测试使用独立的内存SQLite数据库，cache=shared使连接池中的连接共享同一个数据库，测试结束时关闭
+func initTestDatabase(t *testing.T) {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	if err := database.InitDB("sqlite", dsn, "", "", "", 0, "t_", "error"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(database.Close)
+	if err := database.AutoMigrate(model.Models...); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.InitNode(1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+package task
+
+import (
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"time"
+)
+
+// StartCertificateExpiryTask 定时检查证书到期情况，按阈值输出日志并发送通知，启动时检查一次
+func StartCertificateExpiryTask() error {
+	check := func() {
+		if _, err := service.CertificateService.CheckExpiry(time.Now()); err != nil {
+			logger.Error("检查证书到期情况失败: ", err)
+		}
+	}
+	// 默认每天9点执行一次
+	if _, err := c.AddFunc(config.GetString("task.certificateExpiry", "0 0 9 * * *"), check); err != nil {
+		return err
+	}
+	go check()
+	return nil
+}
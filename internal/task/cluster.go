@@ -0,0 +1,18 @@
+package task
+
+import (
+	"security-gateway/internal/proxy"
+	"security-gateway/internal/service"
+	"security-gateway/pkg/config"
+	"security-gateway/pkg/util"
+)
+
+// StartClusterTask 定时上报节点心跳及已应用的配置版本，并检查配置版本，Redis通知丢失时也能在数秒内同步
+func StartClusterTask() error {
+	// 默认每5秒执行一次
+	_, err := c.AddFunc(config.GetString("task.cluster", "*/5 * * * * *"), func() {
+		_ = service.ClusterService.Heartbeat(util.NodeId(), proxy.Manager.AppliedVersion())
+		proxy.Manager.CheckVersion()
+	})
+	return err
+}
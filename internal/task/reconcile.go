@@ -0,0 +1,15 @@
+package task
+
+import (
+	"security-gateway/internal/proxy"
+	"security-gateway/pkg/config"
+)
+
+// StartReconcileTask 定时从数据库同步反向代理配置，补偿遗漏的变化通知
+func StartReconcileTask() error {
+	// 默认每1分钟执行一次
+	_, err := c.AddFunc(config.GetString("task.reconcile", "30 * * * * *"), func() {
+		proxy.Manager.Trigger()
+	})
+	return err
+}
@@ -0,0 +1,18 @@
+package task
+
+import (
+	logger "github.com/sirupsen/logrus"
+	"security-gateway/internal/proxy"
+	"security-gateway/pkg/config"
+)
+
+// StartTokenCleanupTask 定时清理已过期的token
+func StartTokenCleanupTask() error {
+	// 默认每1小时执行一次
+	_, err := c.AddFunc(config.GetString("task.tokenCleanup", "0 0 * * * *"), func() {
+		if _, err := proxy.CleanupTokens(); err != nil {
+			logger.Error("清理过期token失败: ", err)
+		}
+	})
+	return err
+}
@@ -0,0 +1,25 @@
+import {PaginationResponse, Response} from "@/types/common";
+import {get, post} from "./api";
+import {Admin} from "@/types/admin";
+
+export async function getAdminList(
+    params: Admin
+): Promise<Response<PaginationResponse<Admin>>> {
+    return get("/api/v1/admin/list", params);
+}
+
+export async function addAdmin(data: Admin): Promise<Response<Admin>> {
+    return post("/api/v1/admin/add", data);
+}
+
+export async function updateAdmin(data: Admin): Promise<Response<boolean>> {
+    return post("/api/v1/admin/update", data);
+}
+
+export async function resetAdminPassword(id: string, password: string): Promise<Response<boolean>> {
+    return post("/api/v1/admin/resetPassword", {id, password});
+}
+
+export async function deleteAdmin(id: string): Promise<Response<boolean>> {
+    return post(`/api/v1/admin/delete/${id}`);
+}
@@ -0,0 +1,23 @@
+import {PaginationResponse, Response} from "@/types/common";
+import {get} from "./api";
+import {AuditLog, AuditVerifyResult} from "@/types/audit";
+import http from "@/utils/http";
+
+export async function getAuditLogList(
+    params: AuditLog
+): Promise<Response<PaginationResponse<AuditLog>>> {
+    return get("/api/v1/audit/list", params);
+}
+
+export async function verifyAuditLog(): Promise<Response<AuditVerifyResult>> {
+    return get("/api/v1/audit/verify");
+}
+
+// 导出审计日志文件，format为json或csv
+export async function exportAuditLog(params: AuditLog, format: string): Promise<Blob> {
+    const response = await http.get("/api/v1/audit/export", {
+        params: {...params, format},
+        responseType: "blob",
+    });
+    return response.data;
+}
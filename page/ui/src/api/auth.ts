@@ -0,0 +1,15 @@
+import {Response} from "@/types/common";
+import {get, post} from "./api";
+import {LoginResult, Profile} from "@/types/admin";
+
+export async function login(username: string, password: string): Promise<Response<LoginResult>> {
+    return post("/api/v1/auth/login", {username, password});
+}
+
+export async function getProfile(): Promise<Response<Profile>> {
+    return get("/api/v1/auth/profile");
+}
+
+export async function changePassword(oldPassword: string, newPassword: string): Promise<Response<LoginResult>> {
+    return post("/api/v1/auth/password", {oldPassword, newPassword});
+}
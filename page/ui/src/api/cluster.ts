@@ -0,0 +1,7 @@
+import {Response} from "@/types/common";
+import {ClusterNodes} from "@/types/cluster";
+import {get} from "./api";
+
+export async function getClusterNodes(): Promise<Response<ClusterNodes>> {
+  return get("/api/v1/cluster/nodes");
+}
@@ -0,0 +1,41 @@
+import {PaginationResponse, Response} from "@/types/common";
+import {get, post} from "./api";
+import {ConfigDiff, ConfigImportResult, ConfigSnapshot} from "@/types/config";
+import http from "@/utils/http";
+
+export async function getConfigVersions(
+    params: ConfigSnapshot
+): Promise<Response<PaginationResponse<ConfigSnapshot>>> {
+    return get("/api/v1/config/versions", params);
+}
+
+export async function getConfigVersion(version: number): Promise<Response<ConfigSnapshot>> {
+    return get(`/api/v1/config/version/${version}`);
+}
+
+// to为空时与当前配置比较
+export async function diffConfig(from: number, to?: number): Promise<Response<ConfigDiff>> {
+    return get("/api/v1/config/diff", {from, to});
+}
+
+export async function rollbackConfig(version: number): Promise<Response<any>> {
+    return post("/api/v1/config/rollback", {version});
+}
+
+// 导出声明式配置文档，format为yaml或json
+export async function exportConfig(format: string): Promise<Blob> {
+    const response = await http.get("/api/v1/config/export", {
+        params: {format},
+        responseType: "blob",
+    });
+    return response.data;
+}
+
+// 导入声明式配置文档，dryRun时只返回计划的变化
+export async function importConfig(content: string, format: string, dryRun: boolean): Promise<Response<ConfigImportResult>> {
+    const response = await http.post("/api/v1/config/import", content, {
+        params: {format, dryRun},
+        headers: {"Content-Type": "text/plain"},
+    });
+    return response.data;
+}
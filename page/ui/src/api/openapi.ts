@@ -0,0 +1,18 @@
+import {Response} from "@/types/common";
+import {post} from "./api";
+import {OpenApiApplyRequest, OpenApiApplyResult, OpenApiImportPlan} from "@/types/openapi";
+import http from "@/utils/http";
+
+// 上传OpenAPI 3文档，返回生成的路由及建议的脱敏字段，prefix为空时使用文档中服务地址的路径
+export async function planOpenApi(serviceId: string, content: string, prefix?: string): Promise<Response<OpenApiImportPlan>> {
+    const response = await http.post("/api/v1/openapi/plan", content, {
+        params: prefix === undefined ? {serviceId} : {serviceId, prefix},
+        headers: {"Content-Type": "text/plain"},
+    });
+    return response.data;
+}
+
+// 应用审核后的路由及脱敏字段，新建路由需要系统管理员，新增脱敏字段需要安全管理员
+export async function applyOpenApi(data: OpenApiApplyRequest): Promise<Response<OpenApiApplyResult>> {
+    return post("/api/v1/openapi/apply", data);
+}
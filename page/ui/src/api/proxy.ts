@@ -0,0 +1,18 @@
+import {Response} from "@/types/common";
+import {ReconcileResult, RouteMatch} from "@/types/proxy";
+import {get, post} from "./api";
+
+export async function resyncProxy(): Promise<Response<ReconcileResult>> {
+  return post("/api/v1/proxy/resync");
+}
+
+export async function getProxyStatus(): Promise<Response<ReconcileResult>> {
+  return get("/api/v1/proxy/status");
+}
+
+// resolveRoute 查看请求会由哪个路由处理，headers格式为"名称: 值"
+export async function resolveRoute(url: string, method: string, headers: string[] = []): Promise<Response<RouteMatch>> {
+  const params = new URLSearchParams({url, method});
+  headers.forEach(h => params.append("header", h));
+  return get("/api/v1/proxy/resolve", params);
+}
@@ -1,6 +1,18 @@
 import {createRouter, createWebHashHistory, RouteRecordRaw} from "vue-router";
+import {useAdminStore} from "@/store/modules/admin";
+import {getProfile} from "@/api/auth";
 
 export const routes: Array<RouteRecordRaw> = [
+  // 登录
+  {
+    path: "/login",
+    name: "Login",
+    meta: {
+      title: "登录",
+      public: true,
+    },
+    component: () => import("@/views/Login.vue"),
+  },
   // 首页
   {
     path: "/",
@@ -59,6 +71,30 @@ export const routes: Array<RouteRecordRaw> = [
         },
         component: () => import("@/views/ProxyTraceLog.vue"),
       },
+      {
+        path: "configVersion",
+        name: "ConfigVersion",
+        meta: {
+          title: "配置版本",
+        },
+        component: () => import("@/views/ConfigVersion.vue"),
+      },
+      {
+        path: "audit",
+        name: "Audit",
+        meta: {
+          title: "审计日志",
+        },
+        component: () => import("@/views/Audit.vue"),
+      },
+      {
+        path: "admin",
+        name: "Admin",
+        meta: {
+          title: "管理员",
+        },
+        component: () => import("@/views/Admin.vue"),
+      },
     ],
   },
 ];
@@ -68,9 +104,28 @@ const router = createRouter({
   routes,
 });
 
-router.beforeEach((to, _, next) => {
+// 是否启用了管理接口认证，首次进入时查询
+let authEnabled: boolean | undefined = undefined;
+
+router.beforeEach(async (to, _, next) => {
   document.title = (to.meta.title as string) + import.meta.env.VITE_APP_TITLE;
-  next();
+  if (to.meta.public || useAdminStore().isLoggedIn()) {
+    next();
+    return;
+  }
+  if (authEnabled === undefined) {
+    try {
+      const resp = await getProfile();
+      authEnabled = resp.code !== 0 || resp.data.authEnabled;
+    } catch (error) {
+      authEnabled = true;
+    }
+  }
+  if (!authEnabled) {
+    next();
+    return;
+  }
+  next({name: "Login", query: {redirect: to.fullPath}});
 });
 
 export default router;
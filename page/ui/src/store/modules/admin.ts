@@ -0,0 +1,30 @@
+import {defineStore} from "pinia";
+import {ref} from "vue";
+import {Admin, LoginResult} from "@/types/admin";
+
+// 当前登录的管理员及登录token
+export const useAdminStore = defineStore(
+    "admin",
+    () => {
+      const token = ref<string>("");
+      const expireTime = ref<number>(0);
+      const admin = ref<Admin>({});
+
+      const setLogin = (result: LoginResult) => {
+        token.value = result.token;
+        expireTime.value = result.expireTime;
+        admin.value = result.admin;
+      };
+      const logout = () => {
+        token.value = "";
+        expireTime.value = 0;
+        admin.value = {};
+      };
+      const isLoggedIn = () => !!token.value && expireTime.value > Date.now();
+
+      return {token, expireTime, admin, setLogin, logout, isLoggedIn};
+    },
+    {
+      persist: true,
+    }
+);
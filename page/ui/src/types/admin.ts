@@ -0,0 +1,37 @@
+export type Admin = {
+    id?: string;
+    username?: string;
+    name?: string;
+    role?: string;
+    disabled?: boolean;
+    loginFailures?: number;
+    lockedUntil?: number;
+    lastLoginTime?: number;
+    lastLoginIp?: string;
+    passwordTime?: number;
+    createTime?: number;
+    // 新增时的密码
+    password?: string;
+
+    // 分页查询
+    page?: number;
+    pageSize?: number;
+};
+
+export type LoginResult = {
+    token: string;
+    expireTime: number;
+    admin: Admin;
+};
+
+export type Profile = {
+    authEnabled: boolean;
+    admin?: Admin;
+};
+
+// 三员分立的管理员角色
+export const AdminRoles: { [key: string]: string } = {
+    system: "系统管理员",
+    security: "安全管理员",
+    audit: "安全审计员",
+};
@@ -0,0 +1,52 @@
+export type AuditLog = {
+    id?: string;
+    seq?: number;
+    actor?: string;
+    actorRole?: string;
+    ip?: string;
+    action?: string;
+    resource?: string;
+    resourceId?: string;
+    before?: string;
+    after?: string;
+    time?: number;
+    prevHash?: string;
+    hash?: string;
+
+    // 查询条件
+    startTime?: number;
+    endTime?: number;
+    page?: number;
+    pageSize?: number;
+};
+
+export type AuditVerifyResult = {
+    valid: boolean;
+    total: number;
+    lastSeq: number;
+    lastHash: string;
+    brokenSeq?: number;
+    reason?: string;
+};
+
+export const AuditActions: { [key: string]: string } = {
+    create: "新增",
+    update: "修改",
+    delete: "删除",
+};
+
+export const AuditResources: { [key: string]: string } = {
+    service: "服务",
+    route: "路由",
+    routeTarget: "路由上游",
+    upstream: "上游",
+    serviceField: "服务脱敏字段",
+    routeField: "路由脱敏字段",
+    user: "用户",
+    userServiceLevel: "用户服务密级",
+    userInfoRoute: "用户信息路由",
+    certificate: "证书",
+    serviceCertificate: "服务证书",
+    admin: "管理员",
+    token: "用户token",
+};
@@ -1,6 +1,8 @@
 export type Certificate = {
     id?: string;
     certName?: string;
+    // 证书类型，1-服务证书 2-CA证书
+    certType?: number;
     serveDomain?: string;
     certDesc?: string;
     certPem?: string;
@@ -9,6 +11,15 @@ export type Certificate = {
     signKeyPem?: string;
     encCertPem?: string;
     encKeyPem?: string;
+    // 保存时解析的证书信息，过期时间取全部证书中最早的
+    subject?: string;
+    issuer?: string;
+    dnsNames?: string;
+    serialNumber?: string;
+    keyAlgorithm?: string;
+    fingerprint?: string;
+    notBefore?: number;
+    notAfter?: number;
     createTime?: string;
 
     // 分页
@@ -0,0 +1,16 @@
+export interface NodeStatus {
+    id: string;
+    hostname: string;
+    addr: string;
+    configVersion: number;
+    startTime: number;
+    heartbeatTime: number;
+    alive: boolean;
+    synced: boolean;
+    current: boolean;
+}
+
+export interface ClusterNodes {
+    version: number;
+    nodes: NodeStatus[];
+}
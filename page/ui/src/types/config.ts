@@ -0,0 +1,67 @@
+export type ConfigSnapshot = {
+    id?: string;
+    version?: number;
+    actor?: string;
+    ip?: string;
+    summary?: string;
+    digest?: string;
+    content?: string;
+    createTime?: number;
+
+    // 分页查询
+    page?: number;
+    pageSize?: number;
+};
+
+export type ConfigChange = {
+    resource: string;
+    id: string;
+    type: "added" | "removed" | "modified";
+    fields?: string[];
+    before?: { [key: string]: any };
+    after?: { [key: string]: any };
+};
+
+export type ConfigDiff = {
+    from: number;
+    to: number;
+    changes: ConfigChange[];
+};
+
+export const ConfigResources: { [key: string]: string } = {
+    services: "服务",
+    routes: "路由",
+    routeTargets: "路由上游",
+    upstreams: "上游",
+    serviceFields: "服务脱敏字段",
+    routeFields: "路由脱敏字段",
+    userInfoRoutes: "用户信息路由",
+    serviceCertificates: "服务证书",
+};
+
+export const ConfigChangeTypes: { [key: string]: string } = {
+    added: "新增",
+    removed: "删除",
+    modified: "修改",
+};
+
+export type ConfigPlanItem = {
+    resource: string;
+    key: string;
+    action: "create" | "update" | "unchanged";
+    fields?: string[];
+};
+
+export type ConfigImportResult = {
+    dryRun: boolean;
+    created: number;
+    updated: number;
+    unchanged: number;
+    items: ConfigPlanItem[];
+};
+
+export const ConfigPlanActions: { [key: string]: string } = {
+    create: "新增",
+    update: "修改",
+    unchanged: "无变化",
+};
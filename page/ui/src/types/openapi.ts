@@ -0,0 +1,43 @@
+export interface OpenApiField {
+    fieldName: string;
+    comment: string;
+    // 判断为敏感字段的原因
+    reason?: string;
+    level1: string;
+    level2: string;
+    level3: string;
+    level4: string;
+    // 路由或服务已配置同名的脱敏字段
+    exists?: boolean;
+}
+
+export interface OpenApiRoute {
+    uri: string;
+    methods: string;
+    // 文档中的路径
+    source?: string;
+    summary?: string;
+    // 服务下已存在的路由
+    routeId?: string;
+    errors?: string[];
+    fields?: OpenApiField[];
+}
+
+export interface OpenApiImportPlan {
+    serviceId: string;
+    routes: OpenApiRoute[];
+    warnings?: string[];
+}
+
+export interface OpenApiApplyRequest {
+    serviceId: string;
+    // 新建的路由转发到的上游
+    upstreamId?: string;
+    routes: OpenApiRoute[];
+}
+
+export interface OpenApiApplyResult {
+    createdRoutes: number;
+    createdFields: number;
+    createdTargets: number;
+}
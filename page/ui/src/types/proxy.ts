@@ -0,0 +1,45 @@
+import {UserInfoRoute} from "@/types/userInfoRoute";
+
+export interface ReconcileResult {
+    time: number;
+    duration: number;
+    addedServices: number;
+    updatedServices: number;
+    removedServices: number;
+    proxyServices: number;
+    certificates: number;
+    openedPorts?: number[];
+    closedPorts?: number[];
+}
+
+export interface DesensitizeField {
+    name: string;
+    isServiceField: boolean;
+    level1DesensitizeRule: string;
+    level2DesensitizeRule: string;
+    level3DesensitizeRule: string;
+    level4DesensitizeRule: string;
+}
+
+export interface ResolvedUpstream {
+    upstreamId: string;
+    name: string;
+    targetUrl: string;
+    weight: number;
+    // 0-未检测 1-正常 2-健康检测失败
+    status: number;
+    lastCheckTime: number;
+    requestUrl: string;
+}
+
+export interface RouteMatch {
+    port: number;
+    domain: string;
+    routeId: string;
+    path: string;
+    loadBalance: number;
+    rewrittenUri: string;
+    upstreams?: ResolvedUpstream[];
+    fields?: Record<string, DesensitizeField>;
+    userInfoRoute?: UserInfoRoute;
+}
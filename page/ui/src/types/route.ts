@@ -5,6 +5,16 @@ export type Route = {
     serviceId?: string;
     uri?: string;
     loadBalance?: number;
+    timeout?: number;
+    // 匹配条件，methods以逗号分隔，其他条件格式为name=value，多个以分号分隔
+    methods?: string;
+    matchHeaders?: string;
+    matchQueries?: string;
+    matchCookies?: string;
+    priority?: number;
+    // 路径重写，1-保留 2-去除前缀 3-替换前缀 4-正则重写
+    rewriteType?: number;
+    rewriteValue?: string;
     createTime?: string;
 
     // 分页
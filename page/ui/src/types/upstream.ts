@@ -7,6 +7,19 @@ export type Upstream = {
     status?: number;
     lastCheckTime?: number;
 
+    // 传输层参数(毫秒)，0表示使用默认配置
+    connectTimeout?: number;
+    responseHeaderTimeout?: number;
+    maxIdleConnsPerHost?: number;
+    idleConnTimeout?: number;
+
+    // 上游TLS，默认校验证书
+    tlsInsecureSkipVerify?: boolean;
+    tlsCaCertificateId?: string;
+    tlsServerName?: string;
+    tlsClientCertificateId?: string;
+    tlsGmMode?: boolean;
+
     createTime?: string;
 
     // 分页查询参数
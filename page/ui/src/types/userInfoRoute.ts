@@ -7,4 +7,14 @@ export type UserInfoRoute = {
     uniKeyPath?: string;
     matchKey?: string;
     tokenPosition?: string;
+    tokenType?: string;
+    jwtAlgorithms?: string;
+    jwtKey?: string;
+    jwksUrl?: string;
+    introspectionUrl?: string;
+    clientId?: string;
+    clientSecret?: string;
+    logoutPath?: string;
+    logoutMethod?: string;
+    logoutCheck?: string;
 };
@@ -1,5 +1,6 @@
 import axios, {AxiosInstance} from "axios";
-// import { useUserStore } from "@/store/modules/user";
+import {useAdminStore} from "@/store/modules/admin";
+import router from "@/router";
 
 const instance: AxiosInstance = axios.create({
   baseURL: import.meta.env.VITE_APP_API_BASE_URL,
@@ -10,44 +11,12 @@ const instance: AxiosInstance = axios.create({
   },
 });
 
-// let tokenState = 0; // 0: 未获取token，1: 正在获取token，2: 已获取token
-// let requestQueue: any[] = [];
-
 instance.interceptors.request.use(
     async (config) => {
-      return config;
       // 除了登录接口，其他接口都需要携带token
-      // if (tokenState === 2 || config.url === "/zzd/sso/ssoLogin") {
-      //   const token = useUserStore().token;
-      //   token && (config.headers.Authorization = `Bearer ${token}`);
-      //   return config;
-      // } else {
-      //   if (tokenState === 0) {
-      //     tokenState = 1;
-      //     try {
-      //       const token = await useUserStore().getToken();
-      //       token && (config.headers.Authorization = `Bearer ${token}`);
-      //       tokenState = 2;
-      //       requestQueue.forEach((cb) => cb());
-      //       requestQueue = [];
-      //     } catch (error) {
-      //       console.error(error);
-      //     } finally {
-      //       if (tokenState !== 2) {
-      //         tokenState = 0;
-      //       }
-      //       return config;
-      //     }
-      //   } else {
-      //     return new Promise((resolve) => {
-      //       requestQueue.push(() => {
-      //         const token = useUserStore().token;
-      //         token && (config.headers.Authorization = `Bearer ${token}`);
-      //         resolve(config);
-      //       });
-      //     });
-      //   }
-      // }
+      const token = useAdminStore().token;
+      token && (config.headers.Authorization = `Bearer ${token}`);
+      return config;
     },
     (error: any) => {
       return Promise.reject(error);
@@ -59,6 +28,18 @@ instance.interceptors.response.use(
       return response;
     },
     (error) => {
+      // 登录失效，跳转登录页，未登录时由路由守卫处理
+      const store = useAdminStore();
+      if (error.response?.status === 401 && store.token) {
+        store.logout();
+        if (router.currentRoute.value.name !== "Login") {
+          router.push({name: "Login", query: {redirect: router.currentRoute.value.fullPath}});
+        }
+      }
+      // 无权限时仍返回接口的提示信息
+      if (error.response?.status === 403 && error.response.data) {
+        return error.response;
+      }
       return Promise.reject(error);
     }
 );
@@ -12,11 +12,21 @@ var Redis *redis.Pool
 var enabled bool
 
 func init() {
+	config.DefaultInstance.SetDefault("redis.enabled", true)
 	config.DefaultInstance.SetDefault("redis.app", "gateway")
 	config.DefaultInstance.SetDefault("redis.host", "localhost")
 	config.DefaultInstance.SetDefault("redis.port", "6379")
 	config.DefaultInstance.SetDefault("redis.db", "0")
 
+	// 前缀在初始化时确定，连接在Initial中创建，未启用Redis时不会连接
+	Prefix = config.GetString("redis.app")
+}
+
+// Initial 根据配置创建Redis连接池，redis.enabled为false时不使用Redis
+func Initial() {
+	if !config.GetBool("redis.enabled") {
+		return
+	}
 	InitRedis(
 		config.GetString("redis.app"),
 		config.GetString("redis.host"),
@@ -58,7 +68,9 @@ func Get() redis.Conn {
 }
 
 func Close() {
-	_ = Redis.Close()
+	if Redis != nil {
+		_ = Redis.Close()
+	}
 }
 
 func Enabled() bool {
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/gomodule/redigo/redis"
+	logger "github.com/sirupsen/logrus"
+	"time"
+)
+
+// ErrDisabled 未启用Redis
+var ErrDisabled = errors.New("redis未启用")
+
+// Publish 发布消息到频道
+func Publish(channel string, message interface{}) error {
+	if !enabled {
+		return ErrDisabled
+	}
+	conn := Get()
+	defer conn.Close()
+	_, err := conn.Do("PUBLISH", channel, message)
+	return err
+}
+
+// Subscribe 订阅频道，连接断开后间隔retry重新订阅，直到ctx结束，未启用Redis时直接返回
+func Subscribe(ctx context.Context, channel string, retry time.Duration, handler func(data []byte)) {
+	if !enabled {
+		return
+	}
+	for {
+		err := subscribe(ctx, channel, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Warnf("订阅频道 %s 失败，%s后重试: %v", channel, retry, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func subscribe(ctx context.Context, channel string, handler func(data []byte)) error {
+	psc := redis.PubSubConn{Conn: Get()}
+	defer psc.Close()
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				handler(v.Data)
+			case error:
+				done <- v
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		_ = psc.Unsubscribe()
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
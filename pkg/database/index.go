@@ -11,6 +11,7 @@ import (
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 	"security-gateway/pkg/config"
+	"security-gateway/pkg/secret"
 	"strings"
 	"time"
 )
@@ -33,13 +34,18 @@ func initDatabaseDefault() {
 
 var DB *gorm.DB
 
+// Initial 按配置连接数据库，数据库密码可以为主密钥加密后的值(security-gateway secret encrypt)，需先调用secret.Initial
 func Initial() error {
 	initDatabaseDefault()
+	password, err := secret.Decrypt(config.GetString("database.password"))
+	if err != nil {
+		return fmt.Errorf("数据库密码解密失败: %w", err)
+	}
 	return InitDB(
 		config.GetString("database.driver"),
 		config.GetString("database.host"),
 		config.GetString("database.user"),
-		config.GetString("database.password"),
+		password,
 		config.GetString("database.db"),
 		config.GetInt("database.port"),
 		config.GetString("database.prefix"),
@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"github.com/tjfoc/gmsm/sm2"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// JwksRefreshInterval JWKS定时重新加载的间隔
+	JwksRefreshInterval = 10 * time.Minute
+	// JwksMinInterval 遇到未知kid时重新加载的最小间隔，避免无效token频繁请求JWKS
+	JwksMinInterval = 30 * time.Second
+)
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// KeySet JWKS密钥集合，首次使用时加载，定时或遇到未知kid时重新加载
+type KeySet struct {
+	source string
+
+	mu       sync.RWMutex
+	keys     map[string]interface{}
+	loadTime time.Time
+	// 最近一次尝试加载的时间及错误，加载失败时也会更新
+	fetchTime time.Time
+	fetchErr  error
+	// 正在加载时不为空，加载完成后关闭，同一时间只加载一次
+	loading chan struct{}
+}
+
+// NewKeySet source为http(s)地址、file://地址或文件路径
+func NewKeySet(source string) *KeySet {
+	return &KeySet{source: source}
+}
+
+// Key 根据kid获取密钥，kid为空且只有一个密钥时使用该密钥
+func (ks *KeySet) Key(kid string) (interface{}, error) {
+	ks.mu.RLock()
+	_, ok := ks.lookup(kid)
+	stale := time.Since(ks.loadTime) > JwksRefreshInterval
+	ks.mu.RUnlock()
+	if !ok || stale {
+		// 已有密钥时重新加载失败继续使用原密钥
+		if err := ks.refresh(!ok); err != nil && !ok {
+			return nil, fmt.Errorf("加载JWKS失败: %w", err)
+		}
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: kid=%s", ErrKeyNotFound, kid)
+	}
+	return key, nil
+}
+
+// refresh 重新加载JWKS，距上次加载不足JwksMinInterval时跳过。加载时不持有锁，不阻塞使用已有密钥的请求；
+// 其他请求正在加载时，wait为true则等待加载完成，否则直接返回
+func (ks *KeySet) refresh(wait bool) error {
+	ks.mu.Lock()
+	if loading := ks.loading; loading != nil {
+		ks.mu.Unlock()
+		if !wait {
+			return nil
+		}
+		<-loading
+		ks.mu.RLock()
+		defer ks.mu.RUnlock()
+		return ks.fetchErr
+	}
+	now := time.Now()
+	if now.Sub(ks.fetchTime) <= JwksMinInterval {
+		ks.mu.Unlock()
+		return nil
+	}
+	loading := make(chan struct{})
+	ks.loading = loading
+	ks.fetchTime = now
+	ks.mu.Unlock()
+
+	keys, err := LoadKeySet(ks.source)
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if err == nil {
+		ks.keys = keys
+		ks.loadTime = now
+	}
+	ks.fetchErr = err
+	ks.loading = nil
+	close(loading)
+	return err
+}
+
+func (ks *KeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, key := range ks.keys {
+			return key, true
+		}
+	}
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// LoadKeySet 读取并解析JWKS
+func LoadKeySet(source string) (keys map[string]interface{}, err error) {
+	var data []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		var resp *http.Response
+		if resp, err = jwksClient.Get(source); err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("JWKS地址返回状态码: %d", resp.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+			return
+		}
+	} else if data, err = os.ReadFile(strings.TrimPrefix(source, "file://")); err != nil {
+		return
+	}
+	return ParseKeySet(data)
+}
+
+// ParseKeySet 解析JWKS文档，支持oct、RSA、EC(P-256/P-384/P-521/SM2)类型，无法解析的密钥忽略
+func ParseKeySet(data []byte) (keys map[string]interface{}, err error) {
+	if !gjson.ValidBytes(data) {
+		return nil, errors.New("JWKS格式错误")
+	}
+	keys = make(map[string]interface{})
+	for _, jwk := range gjson.GetBytes(data, "keys").Array() {
+		if use := jwk.Get("use").String(); use != "" && use != "sig" {
+			continue
+		}
+		if key, e := parseJwk(jwk); e == nil {
+			keys[jwk.Get("kid").String()] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS中没有可用的密钥")
+	}
+	return
+}
+
+func parseJwk(jwk gjson.Result) (interface{}, error) {
+	switch jwk.Get("kty").String() {
+	case "oct":
+		return decodeBase64Url(jwk.Get("k").String())
+	case "RSA":
+		n, err := decodeBigInt(jwk.Get("n").String())
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.Get("e").String())
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		x, err := decodeBigInt(jwk.Get("x").String())
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Get("y").String())
+		if err != nil {
+			return nil, err
+		}
+		switch jwk.Get("crv").String() {
+		case "P-256":
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+		case "P-384":
+			return &ecdsa.PublicKey{Curve: elliptic.P384(), X: x, Y: y}, nil
+		case "P-521":
+			return &ecdsa.PublicKey{Curve: elliptic.P521(), X: x, Y: y}, nil
+		case "SM2":
+			return &sm2.PublicKey{Curve: sm2.P256Sm2(), X: x, Y: y}, nil
+		}
+	}
+	return nil, errors.New("不支持的JWK")
+}
+
+func decodeBase64Url(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := decodeBase64Url(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("JWK参数为空")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
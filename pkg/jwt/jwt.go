@@ -0,0 +1,274 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	stdx509 "crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed        = errors.New("JWT格式错误")
+	ErrAlgorithm        = errors.New("JWT签名算法不允许")
+	ErrSignature        = errors.New("JWT签名校验失败")
+	ErrExpired          = errors.New("JWT已过期")
+	ErrNotValidYet      = errors.New("JWT尚未生效")
+	ErrKeyNotFound      = errors.New("未找到JWT校验密钥")
+	ErrKeyTypeMismatch  = errors.New("JWT签名算法与密钥类型不匹配")
+	ErrVerifierRequired = errors.New("未配置JWT校验密钥或JWKS")
+)
+
+// Leeway 校验有效期时允许的时钟偏差
+var Leeway = 60 * time.Second
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// Verifier 校验JWT签名及有效期，支持HS/RS/PS/ES及SM2(SM3withSM2)算法，密钥来自配置或JWKS
+type Verifier struct {
+	// 允许的签名算法，避免使用公钥作为HMAC密钥等算法混淆攻击
+	algorithms map[string]struct{}
+	key        interface{}
+	jwks       *KeySet
+}
+
+// NewVerifier 创建校验器，algorithms为逗号分隔的允许算法，key为HMAC密钥或PEM格式的公钥/证书，jwks为JWKS的地址或文件路径
+func NewVerifier(algorithms, key, jwks string) (v *Verifier, err error) {
+	v = &Verifier{algorithms: make(map[string]struct{})}
+	for _, alg := range strings.Split(algorithms, ",") {
+		alg = strings.TrimSpace(alg)
+		if alg == "" {
+			continue
+		}
+		if _, err = algorithmFamily(alg); err != nil {
+			return nil, err
+		}
+		v.algorithms[alg] = struct{}{}
+	}
+	if len(v.algorithms) == 0 {
+		return nil, fmt.Errorf("%w: 未配置允许的签名算法", ErrAlgorithm)
+	}
+	switch {
+	case jwks != "":
+		v.jwks = NewKeySet(jwks)
+	case key != "":
+		if v.key, err = ParseKey(key); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrVerifierRequired
+	}
+	return
+}
+
+// Verify 校验token，返回JSON格式的声明，token可带有Bearer前缀
+func (v *Verifier) Verify(token string) (claims []byte, err error) {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || !gjson.ValidBytes(header) {
+		return nil, ErrMalformed
+	}
+	claims, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !gjson.ValidBytes(claims) {
+		return nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	alg := gjson.GetBytes(header, "alg").String()
+	if _, ok := v.algorithms[alg]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+	}
+	key := v.key
+	if v.jwks != nil {
+		if key, err = v.jwks.Key(gjson.GetBytes(header, "kid").String()); err != nil {
+			return nil, err
+		}
+	}
+	if err = verifySignature(alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	// 有效期
+	now := time.Now()
+	if exp := gjson.GetBytes(claims, "exp"); exp.Exists() && now.Add(-Leeway).Unix() >= exp.Int() {
+		return nil, ErrExpired
+	}
+	if nbf := gjson.GetBytes(claims, "nbf"); nbf.Exists() && now.Add(Leeway).Unix() < nbf.Int() {
+		return nil, ErrNotValidYet
+	}
+	return claims, nil
+}
+
+// SignHMAC 使用HMAC算法(HS256/HS384/HS512)签发JWT，claims为JSON格式的声明
+func SignHMAC(alg string, secret, claims []byte) (string, error) {
+	if family, err := algorithmFamily(alg); err != nil || family != "HS" {
+		return "", fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+	}
+	if !gjson.ValidBytes(claims) {
+		return "", ErrMalformed
+	}
+	header := fmt.Sprintf(`{"alg":"%s","typ":"JWT"}`, alg)
+	input := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(hashes[alg[2:]].New, secret)
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// algorithmFamily 签名算法的类别：HS、RS、PS、ES、SM2
+func algorithmFamily(alg string) (string, error) {
+	if alg == "SM2" || alg == "SM3withSM2" {
+		return "SM2", nil
+	}
+	if len(alg) == 5 {
+		if _, ok := hashes[alg[2:]]; ok {
+			switch alg[:2] {
+			case "HS", "RS", "PS", "ES":
+				return alg[:2], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrAlgorithm, alg)
+}
+
+func verifySignature(alg string, key interface{}, signingInput, signature []byte) error {
+	family, err := algorithmFamily(alg)
+	if err != nil {
+		return err
+	}
+	if family == "SM2" {
+		pub, ok := key.(*sm2.PublicKey)
+		if !ok {
+			return ErrKeyTypeMismatch
+		}
+		if len(signature) == 64 {
+			// r||s
+			r := new(big.Int).SetBytes(signature[:32])
+			s := new(big.Int).SetBytes(signature[32:])
+			if sm2.Sm2Verify(pub, signingInput, nil, r, s) {
+				return nil
+			}
+		} else if pub.Verify(signingInput, signature) {
+			// ASN.1 DER
+			return nil
+		}
+		return ErrSignature
+	}
+
+	hash := hashes[alg[2:]]
+	h := hash.New()
+	h.Write(signingInput)
+	digest := h.Sum(nil)
+	switch family {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrKeyTypeMismatch
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(signingInput)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrSignature
+		}
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyTypeMismatch
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest, signature) != nil {
+			return ErrSignature
+		}
+	case "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrKeyTypeMismatch
+		}
+		if rsa.VerifyPSS(pub, hash, digest, signature, nil) != nil {
+			return ErrSignature
+		}
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrKeyTypeMismatch
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return ErrSignature
+		}
+	}
+	return nil
+}
+
+// ParseKey 解析校验密钥，PEM格式时解析为公钥(支持PUBLIC KEY、RSA PUBLIC KEY及证书，包括SM2)，否则作为HMAC密钥
+func ParseKey(key string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(strings.TrimSpace(key)))
+	if block == nil {
+		return []byte(key), nil
+	}
+	var pub interface{}
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, err
+		}
+		pub = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		if pub, err = stdx509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	case "PUBLIC KEY":
+		if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s", block.Type)
+	}
+	return normalizeKey(pub), nil
+}
+
+// normalizeKey 使用SM2曲线的ECDSA公钥转换为SM2公钥
+func normalizeKey(pub interface{}) interface{} {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve == sm2.P256Sm2() {
+			return &sm2.PublicKey{Curve: k.Curve, X: k.X, Y: k.Y}
+		}
+	case ecdsa.PublicKey:
+		return normalizeKey(&k)
+	case sm2.PublicKey:
+		return &k
+	}
+	return pub
+}
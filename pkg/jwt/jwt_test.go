@@ -0,0 +1,235 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	stdx509 "crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, alg, kid string, claims string, sign func(input []byte) []byte) string {
+	t.Helper()
+	header := fmt.Sprintf(`{"alg":"%s","typ":"JWT","kid":"%s"}`, alg, kid)
+	input := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func sha256Digest(input []byte) []byte {
+	sum := sha256.Sum256(input)
+	return sum[:]
+}
+
+func pemPublicKey(t *testing.T, der []byte) string {
+	t.Helper()
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestVerifier(t *testing.T) {
+	claims := fmt.Sprintf(`{"sub":"alice","uid":"u-1","exp":%d}`, time.Now().Add(time.Hour).Unix())
+
+	// HS256
+	secret := []byte("secret")
+	hs := signToken(t, "HS256", "", claims, func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	})
+
+	// RS256
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rs := signToken(t, "RS256", "", claims, func(input []byte) []byte {
+		sig, _ := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sha256Digest(input))
+		return sig
+	})
+	rsaDer, _ := stdx509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+
+	// ES256
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	es := signToken(t, "ES256", "", claims, func(input []byte) []byte {
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, sha256Digest(input))
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+	ecDer, _ := stdx509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+
+	// SM2
+	sm2Key, _ := sm2.GenerateKey(rand.Reader)
+	sm := signToken(t, "SM2", "", claims, func(input []byte) []byte {
+		r, s, _ := sm2.Sm2Sign(sm2Key, input, nil, rand.Reader)
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+	sm2Der, _ := x509.MarshalSm2PublicKey(&sm2Key.PublicKey)
+
+	cases := []struct {
+		name, alg, key, token string
+	}{
+		{"HS256", "HS256", string(secret), hs},
+		{"RS256", "RS256", pemPublicKey(t, rsaDer), rs},
+		{"ES256", "ES256", pemPublicKey(t, ecDer), es},
+		{"SM2", "SM2", pemPublicKey(t, sm2Der), sm},
+	}
+	for _, c := range cases {
+		v, err := NewVerifier(c.alg, c.key, "")
+		if err != nil {
+			t.Fatalf("%s: 创建校验器失败: %v", c.name, err)
+		}
+		got, err := v.Verify("Bearer " + c.token)
+		if err != nil {
+			t.Errorf("%s: 校验失败: %v", c.name, err)
+			continue
+		}
+		if string(got) != claims {
+			t.Errorf("%s: 声明错误: %s", c.name, got)
+		}
+		// 篡改签名
+		if _, err = v.Verify(c.token[:len(c.token)-2] + "AA"); err == nil {
+			t.Errorf("%s: 篡改后的token应校验失败", c.name)
+		}
+	}
+
+	// 不允许的算法，使用RSA公钥作为HMAC密钥
+	v, _ := NewVerifier("RS256", pemPublicKey(t, rsaDer), "")
+	forged := signToken(t, "HS256", "", claims, func(input []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(pemPublicKey(t, rsaDer)))
+		mac.Write(input)
+		return mac.Sum(nil)
+	})
+	if _, err := v.Verify(forged); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("未允许的算法应校验失败: %v", err)
+	}
+
+	// 过期
+	v, _ = NewVerifier("HS256", string(secret), "")
+	expired := signToken(t, "HS256", "", fmt.Sprintf(`{"exp":%d}`, time.Now().Add(-time.Hour).Unix()), func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	})
+	if _, err := v.Verify(expired); !errors.Is(err, ErrExpired) {
+		t.Errorf("过期的token应校验失败: %v", err)
+	}
+}
+
+func TestSignHMAC(t *testing.T) {
+	claims := `{"sub":"admin"}`
+	token, err := SignHMAC("HS256", []byte("secret"), []byte(claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := NewVerifier("HS256", "secret", "")
+	if got, err := v.Verify(token); err != nil || string(got) != claims {
+		t.Errorf("签发的token校验失败: %s %v", got, err)
+	}
+	if _, err = SignHMAC("RS256", []byte("secret"), []byte(claims)); !errors.Is(err, ErrAlgorithm) {
+		t.Errorf("非HMAC算法应签发失败: %v", err)
+	}
+}
+
+func TestKeySet(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"EC","crv":"P-256","kid":"k1","x":"%s","y":"%s"},{"kty":"oct","kid":"k2","k":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+		base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+		base64.RawURLEncoding.EncodeToString([]byte("secret")))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(jwks), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewVerifier("ES256", "", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := signToken(t, "ES256", "k1", `{"sub":"alice"}`, func(input []byte) []byte {
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, sha256Digest(input))
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig
+	})
+	if _, err = v.Verify(token); err != nil {
+		t.Errorf("JWKS校验失败: %v", err)
+	}
+
+	// kid对应HMAC密钥，与ES256不匹配
+	token = signToken(t, "ES256", "k2", `{"sub":"alice"}`, func(input []byte) []byte { return make([]byte, 64) })
+	if _, err = v.Verify(token); !errors.Is(err, ErrKeyTypeMismatch) {
+		t.Errorf("密钥类型不匹配时应校验失败: %v", err)
+	}
+	token = signToken(t, "ES256", "k3", `{"sub":"alice"}`, func(input []byte) []byte { return make([]byte, 64) })
+	if _, err = v.Verify(token); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("未知kid应校验失败: %v", err)
+	}
+}
+
+func TestKeySetRefresh(t *testing.T) {
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"oct","kid":"k1","k":"%s"}]}`, base64.RawURLEncoding.EncodeToString([]byte("secret")))
+	block := make(chan struct{})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-block
+		}
+		_, _ = w.Write([]byte(jwks))
+	}))
+	defer server.Close()
+
+	ks := NewKeySet(server.URL)
+	if _, err := ks.Key("k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 定时重新加载时JWKS地址响应慢，不影响使用已有密钥
+	ks.mu.Lock()
+	ks.loadTime = ks.loadTime.Add(-JwksRefreshInterval - time.Second)
+	ks.fetchTime = ks.loadTime
+	ks.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = ks.Key("k1")
+	}()
+	for atomic.LoadInt32(&requests) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	if _, err := ks.Key("k1"); err != nil || time.Since(start) > time.Second {
+		t.Errorf("加载JWKS时不应阻塞已有密钥: %v", err)
+	}
+	// 未知kid等待正在进行的加载，不重复请求
+	unknown := make(chan error)
+	go func() {
+		_, err := ks.Key("k2")
+		unknown <- err
+	}()
+	close(block)
+	if err := <-unknown; !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("未知kid应校验失败: %v", err)
+	}
+	<-done
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("JWKS请求次数应为2，实际为%d", n)
+	}
+}
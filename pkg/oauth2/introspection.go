@@ -0,0 +1,132 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInactive = errors.New("token无效或已过期")
+	// InactiveCacheTTL 无效token的缓存时间，避免无效token频繁调用内省接口
+	InactiveCacheTTL = 30 * time.Second
+	// inactiveCacheCapacity 无效token缓存的最大数量，超出时清空
+	inactiveCacheCapacity = 10000
+)
+
+// Introspector 调用RFC 7662令牌内省接口校验token
+type Introspector struct {
+	endpoint     string
+	clientId     string
+	clientSecret string
+	client       *http.Client
+
+	mu       sync.Mutex
+	inactive map[[sha256.Size]byte]time.Time
+}
+
+// NewIntrospector endpoint为内省接口地址，clientId和clientSecret用于HTTP Basic认证，为空时不认证
+func NewIntrospector(endpoint, clientId, clientSecret string, timeout time.Duration) (*Introspector, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("令牌内省地址错误: %s", endpoint)
+	}
+	return &Introspector{
+		endpoint:     endpoint,
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		client:       &http.Client{Timeout: timeout},
+		inactive:     make(map[[sha256.Size]byte]time.Time),
+	}, nil
+}
+
+// Introspect 内省token，token有效时返回JSON格式的内省结果，无效时返回ErrInactive，token可带有Bearer前缀
+func (i *Introspector) Introspect(token string) (result []byte, err error) {
+	if token = trimBearer(token); token == "" {
+		return nil, ErrInactive
+	}
+	sum := sha256.Sum256([]byte(token))
+	if i.isInactive(sum) {
+		return nil, ErrInactive
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+	req, err := http.NewRequest(http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.clientId != "" {
+		req.SetBasicAuth(url.QueryEscape(i.clientId), url.QueryEscape(i.clientSecret))
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("令牌内省接口返回状态码: %d", resp.StatusCode)
+	}
+	if result, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20)); err != nil {
+		return
+	}
+	if !gjson.ValidBytes(result) {
+		return nil, errors.New("令牌内省接口返回格式错误")
+	}
+
+	// active为false或exp已过期时token无效
+	if !gjson.GetBytes(result, "active").Bool() {
+		i.setInactive(sum)
+		return nil, ErrInactive
+	}
+	if exp := gjson.GetBytes(result, "exp"); exp.Exists() && exp.Int() <= time.Now().Unix() {
+		i.setInactive(sum)
+		return nil, ErrInactive
+	}
+	return result, nil
+}
+
+// Reject 内省结果有效但调用方不接受的token(如没有对应的用户)，与无效token一样缓存InactiveCacheTTL
+func (i *Introspector) Reject(token string) {
+	if token = trimBearer(token); token != "" {
+		i.setInactive(sha256.Sum256([]byte(token)))
+	}
+}
+
+func trimBearer(token string) string {
+	token = strings.TrimSpace(token)
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
+
+func (i *Introspector) isInactive(sum [sha256.Size]byte) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	until, ok := i.inactive[sum]
+	if ok && time.Now().After(until) {
+		delete(i.inactive, sum)
+		return false
+	}
+	return ok
+}
+
+func (i *Introspector) setInactive(sum [sha256.Size]byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.inactive) >= inactiveCacheCapacity {
+		i.inactive = make(map[[sha256.Size]byte]time.Time)
+	}
+	i.inactive[sum] = time.Now().Add(InactiveCacheTTL)
+}
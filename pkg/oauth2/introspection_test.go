@@ -0,0 +1,67 @@
+package oauth2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIntrospector(t *testing.T) {
+	var calls atomic.Int32
+	exp := time.Now().Add(time.Hour).Unix()
+	// 本地的令牌内省接口
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("token") {
+		case "active-token":
+			_, _ = fmt.Fprintf(w, `{"active":true,"sub":"u-1","username":"alice","exp":%d}`, exp)
+		case "expired-token":
+			_, _ = fmt.Fprintf(w, `{"active":true,"sub":"u-2","exp":%d}`, time.Now().Add(-time.Hour).Unix())
+		default:
+			_, _ = fmt.Fprint(w, `{"active":false}`)
+		}
+	}))
+	defer server.Close()
+
+	i, err := NewIntrospector(server.URL, "gateway", "secret", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := i.Introspect("Bearer active-token")
+	if err != nil {
+		t.Fatalf("有效token内省失败: %v", err)
+	}
+	if gjson.GetBytes(result, "sub").String() != "u-1" || gjson.GetBytes(result, "exp").Int() != exp {
+		t.Errorf("内省结果错误: %s", result)
+	}
+	if _, err = i.Introspect("expired-token"); !errors.Is(err, ErrInactive) {
+		t.Errorf("已过期的token应无效: %v", err)
+	}
+
+	// 无效token缓存，不重复调用
+	calls.Store(0)
+	for n := 0; n < 3; n++ {
+		if _, err = i.Introspect("unknown-token"); !errors.Is(err, ErrInactive) {
+			t.Errorf("未知token应无效: %v", err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("无效token应缓存, 实际调用%d次", calls.Load())
+	}
+
+	// 认证失败
+	i, _ = NewIntrospector(server.URL, "gateway", "wrong", time.Second)
+	if _, err = i.Introspect("active-token"); err == nil || errors.Is(err, ErrInactive) {
+		t.Errorf("认证失败应返回错误: %v", err)
+	}
+}
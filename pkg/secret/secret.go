@@ -0,0 +1,363 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	logger "github.com/sirupsen/logrus"
+	"github.com/tjfoc/gmsm/sm3"
+	"github.com/tjfoc/gmsm/sm4"
+	"os"
+	"security-gateway/pkg/config"
+	"strings"
+	"sync/atomic"
+)
+
+// 加密算法
+const (
+	// AlgorithmSM4 SM4-GCM，国密合规
+	AlgorithmSM4 = "sm4"
+	// AlgorithmAES AES-256-GCM
+	AlgorithmAES = "aes"
+)
+
+// Prefix 加密后的值的前缀，没有该前缀的值按明文处理
+const Prefix = "enc:v1:"
+
+var (
+	// ErrNoMasterKey 未配置主密钥，无法解密
+	ErrNoMasterKey = errors.New("未配置主密钥，无法解密")
+	// ErrInvalidCiphertext 密文格式错误
+	ErrInvalidCiphertext = errors.New("密文格式错误")
+)
+
+// Keyring 主密钥，第一个为当前使用的密钥，其余密钥只用于解密轮换前加密的数据
+type Keyring struct {
+	algorithm string
+	keys      []*masterKey
+}
+
+type masterKey struct {
+	id  string
+	key []byte
+}
+
+var current atomic.Pointer[Keyring]
+
+// Initial 按配置加载主密钥: 环境变量secret.masterKeyEnv(默认SG_MASTER_KEY)优先，其次为secret.masterKeyFile文件。
+// 未配置主密钥时不加密，新保存的数据为明文
+func Initial() error {
+	algorithm := config.GetString("secret.algorithm", AlgorithmSM4)
+	var entries []string
+	if value := os.Getenv(config.GetString("secret.masterKeyEnv", "SG_MASTER_KEY")); value != "" {
+		entries = strings.Split(value, ",")
+	} else if file := config.GetString("secret.masterKeyFile"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("读取主密钥文件失败: %w", err)
+		}
+		entries = strings.Split(string(data), "\n")
+	}
+	if len(entries) == 0 {
+		current.Store(nil)
+		logger.Warn("未配置主密钥，证书私钥等敏感字段将以明文保存")
+		return nil
+	}
+	k, err := NewKeyring(algorithm, entries)
+	if err != nil {
+		return err
+	}
+	current.Store(k)
+	return nil
+}
+
+// NewKeyring 解析主密钥，每个主密钥的格式为"ID:密钥"，密钥为hex或base64编码且不少于16字节，
+// 省略ID时使用密钥SM3摘要的前8位，空行及#开头的行忽略
+func NewKeyring(algorithm string, entries []string) (*Keyring, error) {
+	algorithm = strings.ToLower(algorithm)
+	if algorithm != AlgorithmSM4 && algorithm != AlgorithmAES {
+		return nil, fmt.Errorf("不支持的加密算法: %s", algorithm)
+	}
+	k := &Keyring{algorithm: algorithm}
+	ids := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			id, encoded = "", entry
+		}
+		key, err := decodeKey(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, err
+		}
+		if id = strings.TrimSpace(id); id == "" {
+			id = hex.EncodeToString(sm3.Sm3Sum(key))[:8]
+		}
+		if ids[id] {
+			return nil, fmt.Errorf("主密钥ID重复: %s", id)
+		}
+		ids[id] = true
+		k.keys = append(k.keys, &masterKey{id: id, key: key})
+	}
+	if len(k.keys) == 0 {
+		return nil, errors.New("没有有效的主密钥")
+	}
+	return k, nil
+}
+
+func decodeKey(encoded string) (key []byte, err error) {
+	if key, err = hex.DecodeString(encoded); err != nil {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, errors.New("主密钥需为hex或base64编码")
+		}
+	}
+	if len(key) < 16 {
+		return nil, errors.New("主密钥不能少于16字节")
+	}
+	return
+}
+
+// Enabled 是否配置了主密钥
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// ActiveKeyID 当前使用的主密钥ID，未配置时为空
+func ActiveKeyID() string {
+	if k := current.Load(); k != nil {
+		return k.keys[0].id
+	}
+	return ""
+}
+
+// DeriveKey 使用当前的主密钥派生用途为purpose的密钥，返回主密钥ID，未配置主密钥时返回空
+func DeriveKey(purpose string) (keyID string, key []byte) {
+	k := current.Load()
+	if k == nil {
+		return "", nil
+	}
+	return k.keys[0].id, k.keys[0].derive(purpose)
+}
+
+// DeriveKeyByID 使用指定ID的主密钥派生用途为purpose的密钥，主密钥不存在时返回nil
+func DeriveKeyByID(keyID, purpose string) []byte {
+	k := current.Load()
+	if k == nil {
+		return nil
+	}
+	if m := k.find(keyID); m != nil {
+		return m.derive(purpose)
+	}
+	return nil
+}
+
+// Encrypt 使用当前的主密钥加密，未配置主密钥、值为空或已加密时原样返回
+func Encrypt(plaintext string) (string, error) {
+	k := current.Load()
+	if k == nil || plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	return k.Encrypt(plaintext)
+}
+
+// Decrypt 解密，没有加密前缀的值按明文原样返回
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	k := current.Load()
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+	return k.Decrypt(value)
+}
+
+// NeedsRotate 值是否为明文或未使用当前的主密钥及算法加密
+func NeedsRotate(value string) bool {
+	k := current.Load()
+	if k == nil || value == "" {
+		return false
+	}
+	return k.NeedsRotate(value)
+}
+
+// Rotate 使用当前的主密钥重新加密，明文直接加密
+func Rotate(value string) (string, error) {
+	k := current.Load()
+	if k == nil {
+		return "", ErrNoMasterKey
+	}
+	return k.Rotate(value)
+}
+
+// IsEncrypted 值是否为加密后的格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt 信封加密: 随机生成数据密钥加密数据，再使用主密钥派生的密钥加密数据密钥，
+// 格式为 enc:v1:算法:主密钥ID:加密的数据密钥:加密的数据，均为base64编码，nonce在密文之前
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	active := k.keys[0]
+	dek := make([]byte, keySize(k.algorithm))
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.algorithm, active.kek(k.algorithm), dek)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(k.algorithm, dek, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return Prefix + strings.Join([]string{
+		k.algorithm,
+		active.id,
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		base64.RawStdEncoding.EncodeToString(data),
+	}, ":"), nil
+}
+
+// Decrypt 按密文中的算法及主密钥ID解密
+func (k *Keyring) Decrypt(value string) (string, error) {
+	e, err := parseEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	key := k.find(e.keyID)
+	if key == nil {
+		return "", fmt.Errorf("主密钥%s不存在，无法解密", e.keyID)
+	}
+	dek, err := open(e.algorithm, key.kek(e.algorithm), e.wrapped)
+	if err != nil {
+		return "", fmt.Errorf("主密钥%s解密数据密钥失败: %w", e.keyID, err)
+	}
+	plaintext, err := open(e.algorithm, dek, e.data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotate 值是否为明文或未使用当前的主密钥及算法加密
+func (k *Keyring) NeedsRotate(value string) bool {
+	if !IsEncrypted(value) {
+		return value != ""
+	}
+	e, err := parseEnvelope(value)
+	return err != nil || e.keyID != k.keys[0].id || e.algorithm != k.algorithm
+}
+
+// Rotate 解密后使用当前的主密钥重新加密
+func (k *Keyring) Rotate(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+	plaintext := value
+	if IsEncrypted(value) {
+		var err error
+		if plaintext, err = k.Decrypt(value); err != nil {
+			return "", err
+		}
+	}
+	return k.Encrypt(plaintext)
+}
+
+func (k *Keyring) find(id string) *masterKey {
+	for _, key := range k.keys {
+		if key.id == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// kek 主密钥的SM3摘要截取为算法需要的长度作为密钥加密密钥
+func (m *masterKey) kek(algorithm string) []byte {
+	return sm3.Sm3Sum(m.key)[:keySize(algorithm)]
+}
+
+// derive 以主密钥为密钥计算用途的HMAC-SM3作为派生密钥，不同用途的密钥互不相关
+func (m *masterKey) derive(purpose string) []byte {
+	h := hmac.New(sm3.New, m.key)
+	h.Write([]byte("security-gateway:" + purpose))
+	return h.Sum(nil)
+}
+
+type envelope struct {
+	algorithm string
+	keyID     string
+	wrapped   []byte
+	data      []byte
+}
+
+func parseEnvelope(value string) (e *envelope, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 4 {
+		return nil, ErrInvalidCiphertext
+	}
+	e = &envelope{algorithm: parts[0], keyID: parts[1]}
+	if e.algorithm != AlgorithmSM4 && e.algorithm != AlgorithmAES {
+		return nil, fmt.Errorf("不支持的加密算法: %s", e.algorithm)
+	}
+	if e.wrapped, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	if e.data, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return
+}
+
+func keySize(algorithm string) int {
+	if algorithm == AlgorithmAES {
+		return 32
+	}
+	return sm4.BlockSize
+}
+
+func newAEAD(algorithm string, key []byte) (cipher.AEAD, error) {
+	var block cipher.Block
+	var err error
+	if algorithm == AlgorithmAES {
+		block, err = aes.NewCipher(key)
+	} else {
+		block, err = sm4.NewCipher(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(algorithm string, key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(algorithm string, key, ciphertext []byte) ([]byte, error) {
+	aead, err := newAEAD(algorithm, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
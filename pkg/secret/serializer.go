@@ -0,0 +1,41 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+// Serializer gorm字段序列化，保存时加密，读取时解密，字段标签为serializer:secret，只支持string类型
+type Serializer struct{}
+
+func init() {
+	schema.RegisterSerializer("secret", Serializer{})
+}
+
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) (err error) {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		value = string(v)
+	case string:
+		value = v
+	default:
+		return fmt.Errorf("字段%s的值类型错误: %T", field.Name, dbValue)
+	}
+	if value, err = Decrypt(value); err != nil {
+		return fmt.Errorf("字段%s解密失败: %w", field.Name, err)
+	}
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+func (Serializer) Value(_ context.Context, field *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("字段%s的值类型错误: %T", field.Name, fieldValue)
+	}
+	return Encrypt(value)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 域名约定：*.example.com 为通配符域名，匹配任意层级的子域名；{}包裹的为正则表达式，需完整匹配；空字符串为默认服务
+
+type domainRegexp struct {
+	domain string
+	regexp *regexp.Regexp
+}
+
+// DomainIndex 域名索引，查找顺序：精确匹配、最长的通配符后缀、正则表达式、默认
+type DomainIndex struct {
+	exact      map[string]string
+	wildcards  []string
+	regexps    []domainRegexp
+	hasDefault bool
+}
+
+// isRegexDomain 是否是正则域名
+func isRegexDomain(domain string) bool {
+	return len(domain) > 2 && domain[0] == '{' && domain[len(domain)-1] == '}'
+}
+
+func compileDomainRegexp(domain string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)^(?:" + domain[1:len(domain)-1] + ")$")
+}
+
+// ValidateDomain 检查域名配置是否有效
+func ValidateDomain(domain string) error {
+	if isRegexDomain(domain) {
+		if _, err := compileDomainRegexp(domain); err != nil {
+			return fmt.Errorf("域名 %s 正则表达式无效: %v", domain, err)
+		}
+		return nil
+	}
+	if strings.Contains(domain, "*") && (!strings.HasPrefix(domain, "*.") || strings.Count(domain, "*") > 1 || len(domain) < 3) {
+		return fmt.Errorf("通配符域名 %s 无效，只支持 *.example.com 形式", domain)
+	}
+	return nil
+}
+
+// NewDomainIndex 根据已配置的域名构建索引，无效的正则域名会被忽略
+func NewDomainIndex(domains []string) *DomainIndex {
+	d := &DomainIndex{exact: make(map[string]string)}
+	for _, domain := range domains {
+		switch {
+		case domain == "":
+			d.hasDefault = true
+		case isRegexDomain(domain):
+			reg, err := compileDomainRegexp(domain)
+			if err != nil {
+				continue
+			}
+			d.regexps = append(d.regexps, domainRegexp{domain: domain, regexp: reg})
+		case strings.HasPrefix(domain, "*."):
+			d.wildcards = append(d.wildcards, domain)
+		default:
+			d.exact[strings.ToLower(domain)] = domain
+		}
+	}
+	// 通配符后缀越长越优先，正则按字典序，保证结果确定
+	sort.Slice(d.wildcards, func(i, j int) bool {
+		if len(d.wildcards[i]) != len(d.wildcards[j]) {
+			return len(d.wildcards[i]) > len(d.wildcards[j])
+		}
+		return d.wildcards[i] < d.wildcards[j]
+	})
+	sort.Slice(d.regexps, func(i, j int) bool {
+		return d.regexps[i].domain < d.regexps[j].domain
+	})
+	return d
+}
+
+// Match 查找请求域名对应的已配置域名
+func (d *DomainIndex) Match(host string) (domain string, ok bool) {
+	if d == nil {
+		return "", false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if domain, ok = d.exact[host]; ok {
+		return
+	}
+	for _, wildcard := range d.wildcards {
+		if strings.HasSuffix(host, strings.ToLower(wildcard[1:])) {
+			return wildcard, true
+		}
+	}
+	for _, dr := range d.regexps {
+		if dr.regexp.MatchString(host) {
+			return dr.domain, true
+		}
+	}
+	if d.hasDefault {
+		return "", true
+	}
+	return "", false
+}
@@ -0,0 +1,33 @@
+package server
+
+import "testing"
+
+func TestDomainIndex_Match(t *testing.T) {
+	d := NewDomainIndex([]string{"", "api.example.com", "*.example.com", "*.tenant.example.com", "{tenant-\\d+\\.example\\.org}"})
+	tests := []struct {
+		host string
+		want string
+		ok   bool
+	}{
+		{"api.example.com", "api.example.com", true},
+		{"API.Example.com", "api.example.com", true},
+		{"a.tenant.example.com", "*.tenant.example.com", true},
+		{"a.example.com", "*.example.com", true},
+		{"tenant-12.example.org", "{tenant-\\d+\\.example\\.org}", true},
+		{"tenant-x.example.org", "", true},
+		{"example.com", "", true},
+	}
+	for _, tt := range tests {
+		got, ok := d.Match(tt.host)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Match(%s) = %s, %v, want %s, %v", tt.host, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if _, ok := NewDomainIndex([]string{"api.example.com"}).Match("other.com"); ok {
+		t.Errorf("没有默认服务时不应匹配")
+	}
+	if ValidateDomain("a.*.com") == nil || ValidateDomain("{[}") == nil || ValidateDomain("*.example.com") != nil {
+		t.Errorf("域名校验结果错误")
+	}
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Condition 请求条件，Value为空时只要求存在
+type Condition struct {
+	Name  string
+	Value string
+}
+
+// Predicate 路由匹配条件，同一路径下存在多个路由时，根据请求方法、请求头、查询参数、Cookie选择路由
+type Predicate struct {
+	Methods  []string
+	Headers  []Condition
+	Queries  []Condition
+	Cookies  []Condition
+	Priority int
+}
+
+// ParsePredicate 解析路由匹配条件，methods以逗号分隔，其他条件格式为name=value，多个以分号分隔
+func ParsePredicate(methods, headers, queries, cookies string, priority int) *Predicate {
+	p := &Predicate{
+		Headers:  parseConditions(headers, true),
+		Queries:  parseConditions(queries, false),
+		Cookies:  parseConditions(cookies, false),
+		Priority: priority,
+	}
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method != "" {
+			p.Methods = append(p.Methods, method)
+		}
+	}
+	return p
+}
+
+func parseConditions(s string, canonical bool) (conditions []Condition) {
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if canonical {
+			name = http.CanonicalHeaderKey(name)
+		}
+		conditions = append(conditions, Condition{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return
+}
+
+// Specificity 条件数量，优先级相同时条件越多越优先
+func (p *Predicate) Specificity() int {
+	if p == nil {
+		return 0
+	}
+	n := len(p.Headers) + len(p.Queries) + len(p.Cookies)
+	if len(p.Methods) > 0 {
+		n++
+	}
+	return n
+}
+
+// Match 检查请求是否满足全部条件，nil表示不限制
+func (p *Predicate) Match(r *http.Request) bool {
+	if p == nil {
+		return true
+	}
+	if len(p.Methods) > 0 {
+		matched := false
+		for _, method := range p.Methods {
+			if method == r.Method {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, c := range p.Headers {
+		if !matchValues(r.Header.Values(c.Name), c.Value) {
+			return false
+		}
+	}
+	if len(p.Queries) > 0 {
+		query := r.URL.Query()
+		for _, c := range p.Queries {
+			if !matchValues(query[c.Name], c.Value) {
+				return false
+			}
+		}
+	}
+	for _, c := range p.Cookies {
+		cookie, err := r.Cookie(c.Name)
+		if err != nil {
+			return false
+		}
+		if c.Value != "" && cookie.Value != c.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValues(values []string, value string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	if value == "" {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PathPattern 编译后的路由路径，用于匹配请求路径的前缀并提取{}包裹的正则片段
+type PathPattern struct {
+	segments []string
+	regexps  []*regexp.Regexp
+}
+
+// CompilePathPattern 编译路由路径，路径中{}包裹的部分为正则表达式
+func CompilePathPattern(path string) (*PathPattern, error) {
+	p := &PathPattern{}
+	for _, segment := range splitPath(path) {
+		if segment == "" {
+			continue
+		}
+		var reg *regexp.Regexp
+		if segment[0] == '{' && segment[len(segment)-1] == '}' {
+			var err error
+			// 与路由树保持一致的匹配方式
+			reg, err = regexp.Compile(segment[1 : len(segment)-1])
+			if err != nil {
+				return nil, fmt.Errorf("路径片段 %s 正则表达式无效: %v", segment, err)
+			}
+		}
+		p.segments = append(p.segments, segment)
+		p.regexps = append(p.regexps, reg)
+	}
+	return p, nil
+}
+
+// Match 匹配请求路径的前缀，返回剩余路径和{}片段匹配到的值
+func (p *PathPattern) Match(path string) (rest string, captures []string, ok bool) {
+	return p.match(path, false)
+}
+
+// MatchEscaped 匹配编码后的请求路径(URL.EscapedPath)的前缀，各片段解码后比较，
+// 返回的剩余路径和匹配到的值保持编码，%2F等编码的字符不会改变路径片段
+func (p *PathPattern) MatchEscaped(escapedPath string) (rest string, captures []string, ok bool) {
+	return p.match(escapedPath, true)
+}
+
+func (p *PathPattern) match(path string, escaped bool) (rest string, captures []string, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(p.segments) > len(parts) {
+		return "", nil, false
+	}
+	for i, segment := range p.segments {
+		part := parts[i]
+		if escaped {
+			var err error
+			if part, err = url.PathUnescape(part); err != nil {
+				return "", nil, false
+			}
+		}
+		switch {
+		case p.regexps[i] != nil:
+			if !p.regexps[i].MatchString(part) {
+				return "", nil, false
+			}
+			captures = append(captures, parts[i])
+		case segment == "*":
+		case segment != part:
+			return "", nil, false
+		}
+	}
+	rest = "/" + strings.Join(parts[len(p.segments):], "/")
+	return rest, captures, true
+}
+
+var captureRegex = regexp.MustCompile(`\$(\d+|\{\d+\})`)
+
+// ExpandCaptures 使用匹配到的值替换模板中的$1、${1}，不存在的序号替换为空
+func ExpandCaptures(template string, captures []string) string {
+	return captureRegex.ReplaceAllStringFunc(template, func(s string) string {
+		index, err := strconv.Atoi(strings.Trim(s[1:], "{}"))
+		if err != nil || index < 1 || index > len(captures) {
+			return ""
+		}
+		return captures[index-1]
+	})
+}
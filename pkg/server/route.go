@@ -4,14 +4,18 @@ import (
 	logger "github.com/sirupsen/logrus"
 	"net/http"
 	"regexp"
+	"sort"
 )
 
 type Route struct {
+	key  string
 	path string
 	//Handler           fiber.Handler
 	Handler http.HandlerFunc
 	//DesensitizeFields []*DesensitizeField
 	MaskFieldMap map[string]*DesensitizeField
+	// 匹配条件，nil表示只按路径匹配
+	Predicate *Predicate
 }
 
 func (r *Route) UpdateField(field *DesensitizeField) {
@@ -34,6 +38,35 @@ func (r *Route) RemoveRouteFieldAndUpdateServiceField(serviceField *DesensitizeF
 	r.MaskFieldMap[serviceField.Name] = serviceField
 }
 
+// Key 路由标识，同一路径下可以存在多个不同匹配条件的路由
+func (r *Route) Key() string {
+	return r.key
+}
+
+// Path 路由路径
+func (r *Route) Path() string {
+	return r.path
+}
+
+// sortRoutes 同一路径下的路由排序：优先级高的在前，优先级相同时条件多的在前，最后按标识排序
+func sortRoutes(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		pi, pj := routes[i].priority(), routes[j].priority()
+		if pi != pj {
+			return pi > pj
+		}
+		si, sj := routes[i].Predicate.Specificity(), routes[j].Predicate.Specificity()
+		if si != sj {
+			return si > sj
+		}
+		return routes[i].key < routes[j].key
+	})
+}
+
+func (r *Route) priority() int {
+	return r.Predicate.priority()
+}
+
 //	func NewRoute(path string, handler fiber.Handler) *Route {
 //		return &Route{path: path, Handler: handler}
 //	}
@@ -43,28 +76,65 @@ func NewRoute(path string, handler http.HandlerFunc) *Route {
 
 type TreeRoute struct {
 	segment          string                // 当前节点的路径片段，可能为正则表达式， 暂时只考虑*的情况
-	route            *Route                // 当前节点直接匹配到的路由，可能为nil
+	routes           []*Route              // 当前节点直接匹配到的路由，按优先级排序，可能为空
 	regexp           *regexp.Regexp        // 当前节点的正则表达式，可能为nil
 	children         map[string]*TreeRoute // 子节点
 	childrenSegments []string              // 子节点的路径片段，用于排序
 }
 
+// setRoute 设置当前节点的路由，标识相同的路由直接替换
+func (r *TreeRoute) setRoute(route *Route) {
+	for i, rt := range r.routes {
+		if rt.key == route.key {
+			r.routes[i] = route
+			sortRoutes(r.routes)
+			return
+		}
+	}
+	r.routes = append(r.routes, route)
+	sortRoutes(r.routes)
+}
+
+// matchRoute 按优先级返回当前节点第一个满足条件的路由，req为nil时不检查条件
+func (r *TreeRoute) matchRoute(req *http.Request) *Route {
+	for _, route := range r.routes {
+		if req == nil || route.Predicate.Match(req) {
+			return route
+		}
+	}
+	return nil
+}
+
+// deleteRoutes 删除当前节点的路由，key为nil时删除全部
+func (r *TreeRoute) deleteRoutes(key *string) {
+	if key == nil {
+		r.routes = nil
+		return
+	}
+	for i, route := range r.routes {
+		if route.key == *key {
+			r.routes = append(r.routes[:i], r.routes[i+1:]...)
+			return
+		}
+	}
+}
+
 func (r *TreeRoute) AddRoute(route *Route) {
 	// 如果只有一个 / ，直接设置route
 	if route.path == "/" {
-		r.route = route
+		r.setRoute(route)
 		return
 	}
 
 	segments := splitPath(route.path)
 	if len(segments) == 0 {
-		r.route = route
+		r.setRoute(route)
 		return
 	}
 	segment := segments[0]
 	if segment == "" {
 		if len(segments) == 1 {
-			r.route = route
+			r.setRoute(route)
 			return
 		}
 		segment = segments[1]
@@ -86,7 +156,7 @@ func (r *TreeRoute) AddRoute(route *Route) {
 
 func (r *TreeRoute) addRoute(segments []string, route *Route) {
 	if len(segments) == 0 {
-		r.route = route
+		r.setRoute(route)
 		return
 	}
 	segment := segments[0]
@@ -102,7 +172,7 @@ func (r *TreeRoute) addRoute(segments []string, route *Route) {
 	}
 
 	if len(segments) == 1 {
-		r.route = route
+		r.setRoute(route)
 		return
 	}
 	// 如果还有segment，继续往下走
@@ -124,115 +194,123 @@ func (r *TreeRoute) addRoute(segments []string, route *Route) {
 	sortSegments(r.childrenSegments)
 }
 
+// RemoveRoute 删除路径下的全部路由，返回true表示树中已没有任何路由
 func (r *TreeRoute) RemoveRoute(path string) bool {
+	return r.removeRouteByPath(path, nil)
+}
+
+// RemoveRouteByKey 删除路径下指定标识的路由，返回true表示树中已没有任何路由
+func (r *TreeRoute) RemoveRouteByKey(path, key string) bool {
+	return r.removeRouteByPath(path, &key)
+}
+
+func (r *TreeRoute) removeRouteByPath(path string, key *string) bool {
 	segments := splitPath(path)
-	if len(segments) == 0 {
-		// 删除当前节点全部路由
-		r.route = nil
-		r.children = nil
-		r.childrenSegments = nil
-		return true
+	if len(segments) == 0 || path == "/" {
+		r.deleteRoutes(key)
+		return r.isEmpty()
 	}
 	segment := segments[0]
 	if segment == "" {
 		if len(segments) == 1 {
-			r.route = nil
-			r.children = nil
-			r.childrenSegments = nil
-			return true
+			r.deleteRoutes(key)
+			return r.isEmpty()
 		}
 		segment = segments[1]
 	}
 	if child, ok := r.children[segment]; ok {
-		canDel := child.removeRoute(segments[1:])
-		if canDel {
-			// childrenSegments中删除segment
-			for i, s := range r.childrenSegments {
-				if s == segment {
-					r.childrenSegments = append(r.childrenSegments[:i], r.childrenSegments[i+1:]...)
-					break
-				}
-			}
-			// 删除子节点
-			delete(r.children, segment)
+		if child.removeRoute(segments[1:], key) {
+			r.removeChild(segment)
 		}
 	}
-	// 删除子节点后，如果子节点为空，删除子节点
-	if len(r.children) == 0 {
-		r.children = nil
-		r.childrenSegments = nil
-		return true
-	}
-	return false
+	return r.isEmpty()
 }
 
-func (r *TreeRoute) removeRoute(segments []string) bool {
+func (r *TreeRoute) removeRoute(segments []string, key *string) bool {
 	if len(segments) == 1 {
-		r.route = nil
-		r.children = nil
-		r.childrenSegments = nil
-		return true
+		r.deleteRoutes(key)
+		return r.isEmpty()
 	}
 	segment := segments[1]
 	if child, ok := r.children[segment]; ok {
-		canDel := child.removeRoute(segments[1:])
-		if canDel {
-			// childrenSegments中删除segment
-			for i, s := range r.childrenSegments {
-				if s == segment {
-					r.childrenSegments = append(r.childrenSegments[:i], r.childrenSegments[i+1:]...)
-					break
-				}
-			}
-			// 删除子节点
-			delete(r.children, segment)
+		if child.removeRoute(segments[1:], key) {
+			r.removeChild(segment)
 		}
 	}
-	// 删除子节点后，如果子节点为空，删除子节点
+	return r.isEmpty()
+}
+
+func (r *TreeRoute) removeChild(segment string) {
+	// childrenSegments中删除segment
+	for i, s := range r.childrenSegments {
+		if s == segment {
+			r.childrenSegments = append(r.childrenSegments[:i], r.childrenSegments[i+1:]...)
+			break
+		}
+	}
+	// 删除子节点
+	delete(r.children, segment)
 	if len(r.children) == 0 {
 		r.children = nil
 		r.childrenSegments = nil
-		return r.route == nil
 	}
-	return false
 }
 
+func (r *TreeRoute) isEmpty() bool {
+	return len(r.routes) == 0 && len(r.children) == 0
+}
+
+// FindRoute 只按路径查找路由，同一路径下存在多个路由时返回优先级最高的
 func (r *TreeRoute) FindRoute(path string) *Route {
+	return r.findByPath(path, nil)
+}
+
+// MatchRoute 按路径和匹配条件查找路由
+func (r *TreeRoute) MatchRoute(req *http.Request) *Route {
+	return r.findByPath(req.URL.Path, req)
+}
+
+func (r *TreeRoute) findByPath(path string, req *http.Request) *Route {
 	segments := splitPath(path)
 	if len(segments) == 0 {
-		return r.route
+		return r.matchRoute(req)
 	}
 	segment := segments[0]
 	if segment == "" {
 		if len(segments) == 1 {
-			return r.route
+			return r.matchRoute(req)
 		}
 		segment = segments[1]
 	}
 	// 按照r.childrenSegments的顺序查找
 	for _, s := range r.childrenSegments {
 		if s == segment {
-			result := r.children[s].findRoute(segments[1:])
+			result, _ := r.children[s].findRoute(segments[1:], req)
 			if result != nil {
 				return result
 			}
 			break
 		}
 	}
-	return r.route
+	return r.matchRoute(req)
 }
 
-func (r *TreeRoute) findRoute(segments []string) *Route {
-	if len(segments) == 1 {
-		return r.route
-	}
-	segment := segments[1]
-	// 按照r.childrenSegments的顺序查找
-	for _, s := range r.childrenSegments {
-		child := r.children[s]
-		if matchSegment(s, segment, child.regexp) {
-			return r.children[s].findRoute(segments[1:])
+// findRoute 查找路由，rejected表示路径对应的节点有路由但匹配条件均不满足，此时上级节点回退到自己的路由
+func (r *TreeRoute) findRoute(segments []string, req *http.Request) (route *Route, rejected bool) {
+	if len(segments) > 1 {
+		segment := segments[1]
+		// 按照r.childrenSegments的顺序查找
+		for _, s := range r.childrenSegments {
+			child := r.children[s]
+			if matchSegment(s, segment, child.regexp) {
+				if route, rejected = child.findRoute(segments[1:], req); route != nil || !rejected {
+					return
+				}
+				break
+			}
 		}
 	}
-	return r.route
+	route = r.matchRoute(req)
+	rejected = route == nil && (rejected || len(r.routes) > 0)
+	return
 }
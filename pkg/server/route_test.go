@@ -1,6 +1,7 @@
 package server
 
 import (
+	"net/http/httptest"
 	"testing"
 )
 
@@ -103,3 +104,84 @@ func TestTreeRoute(t *testing.T) {
 	}
 
 }
+
+func TestTreeRoute_MatchRoute(t *testing.T) {
+	r := &TreeRoute{}
+	r.AddRoute(&Route{key: "1", path: "/api/orders"})
+	r.AddRoute(&Route{key: "2", path: "/api/orders", Predicate: ParsePredicate("POST", "", "", "", 0)})
+	r.AddRoute(&Route{key: "3", path: "/api/orders", Predicate: ParsePredicate("", "x-api-version=2", "", "", 0)})
+	r.AddRoute(&Route{key: "4", path: "/api/orders", Predicate: ParsePredicate("GET", "", "debug", "", 10)})
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{"无条件", "GET", "/api/orders", nil, "1"},
+		{"请求方法", "POST", "/api/orders/1", nil, "2"},
+		{"请求头", "GET", "/api/orders", map[string]string{"X-Api-Version": "2"}, "3"},
+		{"请求头值不匹配", "GET", "/api/orders", map[string]string{"X-Api-Version": "1"}, "1"},
+		{"优先级", "GET", "/api/orders?debug=1", map[string]string{"X-Api-Version": "2"}, "4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			route := r.MatchRoute(req)
+			if route == nil || route.key != tt.want {
+				t.Errorf("%s %s 应匹配路由 %s, 实际: %v", tt.method, tt.target, tt.want, route)
+			}
+		})
+	}
+
+	// 请求头条件多于请求方法条件时优先
+	r.AddRoute(&Route{key: "5", path: "/api/orders", Predicate: ParsePredicate("POST", "x-api-version=2", "", "", 0)})
+	req := httptest.NewRequest("POST", "/api/orders", nil)
+	req.Header.Set("X-Api-Version", "2")
+	if route := r.MatchRoute(req); route == nil || route.key != "5" {
+		t.Errorf("条件多的路由应优先匹配")
+	}
+
+	r.RemoveRouteByKey("/api/orders", "1")
+	if route := r.MatchRoute(httptest.NewRequest("GET", "/api/orders", nil)); route != nil {
+		t.Errorf("条件均不满足时不应匹配, 实际: %s", route.key)
+	}
+}
+
+func TestTreeRoute_MatchParentRoute(t *testing.T) {
+	r := &TreeRoute{}
+	r.AddRoute(&Route{key: "1", path: "/api"})
+	r.AddRoute(&Route{key: "2", path: "/api/orders", Predicate: ParsePredicate("POST", "", "", "", 0)})
+	r.AddRoute(&Route{key: "3", path: "/api/orders/{\\d+}/items", Predicate: ParsePredicate("POST", "", "", "", 0)})
+
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{"POST", "/api/orders", "2"},
+		{"POST", "/api/orders/1/items", "3"},
+		// 子路径的路由条件均不满足时回退到上级路径的路由
+		{"GET", "/api/orders", "1"},
+		{"GET", "/api/orders/1/items", "1"},
+		// 子路径的节点没有路由时与只按路径查找一致，不回退
+		{"POST", "/api/orders/1", ""},
+		{"GET", "/api/orders/1", ""},
+	}
+	for _, tt := range tests {
+		route := r.MatchRoute(httptest.NewRequest(tt.method, tt.target, nil))
+		if tt.want == "" {
+			if route != nil {
+				t.Errorf("%s %s 不应匹配路由, 实际: %s", tt.method, tt.target, route.key)
+			}
+			continue
+		}
+		if route == nil || route.key != tt.want {
+			t.Errorf("%s %s 应匹配路由 %s, 实际: %v", tt.method, tt.target, tt.want, route)
+		}
+	}
+}
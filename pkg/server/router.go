@@ -24,13 +24,23 @@ type Router struct {
 //	r.tree.AddRoute(route)
 //}
 
-func (r *Router) AddRoute(path string, handler http.HandlerFunc, fields map[string]*DesensitizeField) {
+// AddRoute 添加路由，key为路由标识，同一路径下可以存在多个不同匹配条件的路由，key相同时替换
+func (r *Router) AddRoute(key, path string, predicate *Predicate, handler http.HandlerFunc, fields map[string]*DesensitizeField) {
 	if r.routes == nil {
 		r.routes = make(map[string]*Route)
 	}
+	if old, ok := r.routes[key]; ok && old.path != path {
+		r.tree.RemoveRouteByKey(old.path, key)
+	}
 
-	route := &Route{path, handler, fields}
-	r.routes[path] = route
+	route := &Route{
+		key:          key,
+		path:         path,
+		Handler:      handler,
+		MaskFieldMap: fields,
+		Predicate:    predicate,
+	}
+	r.routes[key] = route
 	// 优化路由树
 	if r.tree == nil {
 		r.tree = &TreeRoute{}
@@ -38,12 +48,14 @@ func (r *Router) AddRoute(path string, handler http.HandlerFunc, fields map[stri
 	r.tree.AddRoute(route)
 }
 
-func (r *Router) RemoveRoute(path string) (noUsed bool) {
-	if r.tree.RemoveRoute(path) {
-		delete(r.routes, path)
-		return true
+// RemoveRoute 删除路由，返回true表示router下已没有任何路由
+func (r *Router) RemoveRoute(key string) (noUsed bool) {
+	route, ok := r.routes[key]
+	if !ok {
+		return len(r.routes) == 0
 	}
-	return false
+	delete(r.routes, key)
+	return r.tree.RemoveRouteByKey(route.path, key)
 }
 
 func (r *Router) FindRoute(path string) *Route {
@@ -53,6 +65,14 @@ func (r *Router) FindRoute(path string) *Route {
 	return r.tree.FindRoute(path)
 }
 
+// MatchRoute 按路径和请求方法、请求头、查询参数、Cookie查找路由
+func (r *Router) MatchRoute(req *http.Request) *Route {
+	if r.tree == nil {
+		return nil
+	}
+	return r.tree.MatchRoute(req)
+}
+
 // UpdateServiceField 更新服务字段，如果有则替换，如果没有则添加
 func (r *Router) UpdateServiceField(field *DesensitizeField) {
 	// 遍历所有路由，更新字段
@@ -70,8 +90,8 @@ func (r *Router) RemoveServiceField(name string) {
 }
 
 // UpdateRouteField 更新路由字段，如果有则替换，如果没有则添加
-func (r *Router) UpdateRouteField(path string, field *DesensitizeField) {
-	route, has := r.routes[path]
+func (r *Router) UpdateRouteField(key string, field *DesensitizeField) {
+	route, has := r.routes[key]
 	if !has {
 		return
 	}
@@ -79,8 +99,8 @@ func (r *Router) UpdateRouteField(path string, field *DesensitizeField) {
 }
 
 // RemoveRouteFieldWithServiceFieldUpdate 删除路由字段并更新服务字段
-func (r *Router) RemoveRouteFieldWithServiceFieldUpdate(path string, serviceField *DesensitizeField) {
-	route, has := r.routes[path]
+func (r *Router) RemoveRouteFieldWithServiceFieldUpdate(key string, serviceField *DesensitizeField) {
+	route, has := r.routes[key]
 	if !has {
 		return
 	}
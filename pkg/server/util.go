@@ -32,30 +32,35 @@ func splitPath(path string) []string {
 // sortSegments 对子节点的路径片段进行排序，按照字典序，*放在最后，{}包裹的正则表达式次之
 func sortSegments(segments []string) {
 	sort.Slice(segments, func(i, j int) bool {
-		iContainsStar := strings.Contains(segments[i], "*")
-		jContainsStar := strings.Contains(segments[j], "*")
-		iContainsRegex := strings.Contains(segments[i], "{") && strings.Contains(segments[i], "}")
-		jContainsRegex := strings.Contains(segments[j], "{") && strings.Contains(segments[j], "}")
-
-		if iContainsStar {
-			return false
-		}
-		if jContainsStar {
-			return true
-		}
-		if iContainsRegex && jContainsRegex {
-			return segments[i] < segments[j]
-		}
-		if iContainsRegex {
-			return false
-		}
-		if jContainsRegex {
-			return true
-		}
-		return segments[i] < segments[j]
+		return segmentLess(segments[i], segments[j])
 	})
 }
 
+// segmentLess 路径片段的查找顺序，查找时使用第一个匹配的片段
+func segmentLess(a, b string) bool {
+	aContainsStar := strings.Contains(a, "*")
+	bContainsStar := strings.Contains(b, "*")
+	aContainsRegex := strings.Contains(a, "{") && strings.Contains(a, "}")
+	bContainsRegex := strings.Contains(b, "{") && strings.Contains(b, "}")
+
+	if aContainsStar {
+		return false
+	}
+	if bContainsStar {
+		return true
+	}
+	if aContainsRegex && bContainsRegex {
+		return a < b
+	}
+	if aContainsRegex {
+		return false
+	}
+	if bContainsRegex {
+		return true
+	}
+	return a < b
+}
+
 func matchSegment(segment, path string, reg *regexp.Regexp) bool {
 	if segment == "*" {
 		return true
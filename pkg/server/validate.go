@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RouteSpec 待校验的路由，Key为路由标识，新增的路由为空
+type RouteSpec struct {
+	Key       string
+	Path      string
+	Predicate *Predicate
+}
+
+func (r *RouteSpec) name() string {
+	if r.Key == "" {
+		return r.Path
+	}
+	return fmt.Sprintf("%s(%s)", r.Path, r.Key)
+}
+
+// ValidatePath 校验路由路径：以/开头，中间没有空片段，{}包裹的正则表达式为完整的路径片段且可以编译，
+// 第一级路径片段按原值查找，不能为正则表达式或*
+func ValidatePath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("路径 %s 需以/开头", path)
+	}
+	segments := pathSegments(path)
+	if len(segments) > 0 && (segments[0] == "*" || strings.ContainsAny(segments[0], "{}\x00")) {
+		return fmt.Errorf("路径 %s 的第一级路径片段不支持正则表达式及*，路由不会被匹配", path)
+	}
+	for i, segment := range segments {
+		if segment == "" {
+			if i < len(segments)-1 {
+				return fmt.Errorf("路径 %s 中存在空的路径片段，路由不会被匹配", path)
+			}
+			continue
+		}
+		if strings.ContainsAny(segment, "{}\x00") {
+			if segment[0] != '{' || segment[len(segment)-1] != '}' || strings.ContainsAny(segment[1:len(segment)-1], "{}\x00") {
+				return fmt.Errorf("路径 %s 格式错误，正则表达式需以{}包裹完整的路径片段且不能包含{}", path)
+			}
+			if _, err := regexp.Compile(segment[1 : len(segment)-1]); err != nil {
+				return fmt.Errorf("路径片段 %s 正则表达式无效: %v", segment, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateRoute 校验服务下新增或修改的路由与其他路由是否冲突：路径及匹配条件重复、被其他路由遮蔽，或使其他路由不会被匹配，返回全部错误
+func ValidateRoute(route *RouteSpec, others []*RouteSpec) (errs []string) {
+	if err := ValidatePath(route.Path); err != nil {
+		return []string{err.Error()}
+	}
+	for _, other := range others {
+		if other.Key != "" && other.Key == route.Key {
+			continue
+		}
+		if ValidatePath(other.Path) != nil {
+			continue
+		}
+		if duplicated(route, other) {
+			errs = append(errs, fmt.Sprintf("与路由 %s 的路径及匹配条件重复", other.name()))
+			continue
+		}
+		if reason := hiddenBy(route, other); reason != "" {
+			errs = append(errs, fmt.Sprintf("被路由 %s 遮蔽，不会被匹配: %s", other.name(), reason))
+		}
+		if reason := hiddenBy(other, route); reason != "" {
+			errs = append(errs, fmt.Sprintf("保存后路由 %s 将不会被匹配: %s", other.name(), reason))
+		}
+	}
+	return
+}
+
+// pathSegments 路由在路由树中的路径片段，根路径为空
+func pathSegments(path string) []string {
+	if path == "/" {
+		return nil
+	}
+	segments := splitPath(path)
+	if len(segments) > 0 && segments[0] == "" {
+		segments = segments[1:]
+	}
+	return segments
+}
+
+func duplicated(a, b *RouteSpec) bool {
+	return a.Path == b.Path && a.Predicate.priority() == b.Predicate.priority() &&
+		covers(a.Predicate, b.Predicate) && covers(b.Predicate, a.Predicate)
+}
+
+// hiddenBy 路由v是否因路由b不会被匹配，返回原因
+func hiddenBy(v, b *RouteSpec) string {
+	sv, sb := pathSegments(v.Path), pathSegments(b.Path)
+	if v.Path == b.Path {
+		// 同一路径下按优先级、条件数量、路由标识排序，b满足v的全部条件且排在前面时v不会被匹配
+		if covers(b.Predicate, v.Predicate) && routeBefore(b, v) {
+			return "路径相同，其匹配条件包含本路由的全部请求且优先"
+		}
+		return ""
+	}
+	// 路由树按片段逐级查找，同一节点下只进入第一个匹配的子节点，子节点的路由匹配条件均不满足时回退到上级节点的路由，
+	// 不再尝试其他子节点，因此b的路径片段匹配任意值且排在前面时v不会被匹配。
+	// v的路径为b的上级路径时，b的匹配条件不满足的请求回退到v，v不会被完全遮蔽
+	for i := 0; i < len(sv) && i < len(sb); i++ {
+		if sv[i] == sb[i] {
+			continue
+		}
+		if segmentLess(sb[i], sv[i]) && segmentCovers(sb[i], sv[i]) {
+			return fmt.Sprintf("路径片段 %s 匹配任意值，优先于 %s", sb[i], sv[i])
+		}
+		return ""
+	}
+	return ""
+}
+
+// routeBefore 同一路径下路由a是否排在b前面，与sortRoutes一致，新增的路由排在最后
+func routeBefore(a, b *RouteSpec) bool {
+	pa, pb := a.Predicate.priority(), b.Predicate.priority()
+	if pa != pb {
+		return pa > pb
+	}
+	sa, sb := a.Predicate.Specificity(), b.Predicate.Specificity()
+	if sa != sb {
+		return sa > sb
+	}
+	if a.Key == "" || b.Key == "" {
+		return b.Key == ""
+	}
+	return a.Key < b.Key
+}
+
+// segmentCovers 路径片段a是否匹配b能匹配的全部值，只判断a为匹配任意值的正则表达式的情况
+func segmentCovers(a, b string) bool {
+	if len(a) < 2 || a[0] != '{' || a[len(a)-1] != '}' {
+		return false
+	}
+	reg, err := regexp.Compile(a[1 : len(a)-1])
+	if err != nil {
+		return false
+	}
+	for _, sample := range []string{"a", "Z", "0", "-", "_.~%"} {
+		if !reg.MatchString(sample) {
+			return false
+		}
+	}
+	return b == "*" || (strings.HasPrefix(b, "{") && strings.HasSuffix(b, "}"))
+}
+
+// covers 条件a是否满足条件b能匹配的全部请求，即b包含a的全部条件
+func covers(a, b *Predicate) bool {
+	if a == nil {
+		return true
+	}
+	if len(a.Methods) > 0 {
+		if b == nil || len(b.Methods) == 0 {
+			return false
+		}
+		for _, method := range b.Methods {
+			if !containsString(a.Methods, method) {
+				return false
+			}
+		}
+	}
+	var bHeaders, bQueries, bCookies []Condition
+	if b != nil {
+		bHeaders, bQueries, bCookies = b.Headers, b.Queries, b.Cookies
+	}
+	return coversConditions(a.Headers, bHeaders) && coversConditions(a.Queries, bQueries) && coversConditions(a.Cookies, bCookies)
+}
+
+func coversConditions(a, b []Condition) bool {
+	for _, ca := range a {
+		found := false
+		for _, cb := range b {
+			if ca.Name == cb.Name && (ca.Value == "" || ca.Value == cb.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Predicate) priority() int {
+	if p == nil {
+		return 0
+	}
+	return p.Priority
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"/", true},
+		{"/api/{\\d+}/orders", true},
+		{"/api/", true},
+		{"api", false},
+		{"/api/{[}", false},
+		{"/api//orders", false},
+		{"/api/a{b}", false},
+		{"/api/{\\d{3}}", false},
+		{"/*", false},
+		{"/{v\\d}/api", false},
+	}
+	for _, tt := range tests {
+		if err := ValidatePath(tt.path); (err == nil) != tt.ok {
+			t.Errorf("%s 校验结果错误: %v", tt.path, err)
+		}
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	others := []*RouteSpec{
+		{Key: "1", Path: "/api/orders"},
+		{Key: "2", Path: "/api/orders", Predicate: ParsePredicate("POST", "", "", "", 0)},
+		{Key: "3", Path: "/api/*"},
+		{Key: "4", Path: "/v2/orders", Predicate: ParsePredicate("", "", "", "", 10)},
+	}
+	tests := []struct {
+		name  string
+		route *RouteSpec
+		want  string
+	}{
+		{"正常", &RouteSpec{Path: "/api/orders", Predicate: ParsePredicate("GET", "x-version=2", "", "", 0)}, ""},
+		{"修改自身", &RouteSpec{Key: "1", Path: "/api/orders"}, ""},
+		{"重复", &RouteSpec{Path: "/api/orders", Predicate: ParsePredicate("post", "", "", "", 0)}, "重复"},
+		{"被优先级高的路由遮蔽", &RouteSpec{Path: "/v2/orders", Predicate: ParsePredicate("GET", "", "", "", 1)}, "遮蔽"},
+		{"遮蔽其他路由", &RouteSpec{Path: "/api/orders", Predicate: ParsePredicate("", "", "", "", 1)}, "将不会被匹配"},
+		{"正则匹配任意值优先于*", &RouteSpec{Path: "/api/{[^/]+}/detail"}, "将不会被匹配"},
+		{"正则只匹配部分值", &RouteSpec{Path: "/api/{^\\d+$}/detail"}, ""},
+		{"上级路径的路由", &RouteSpec{Path: "/v2", Predicate: ParsePredicate("", "", "", "", 0)}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateRoute(tt.route, others)
+			if tt.want == "" && len(errs) > 0 || tt.want != "" && (len(errs) == 0 || !strings.Contains(strings.Join(errs, ";"), tt.want)) {
+				t.Errorf("校验结果错误: %v", errs)
+			}
+		})
+	}
+}
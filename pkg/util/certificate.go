@@ -0,0 +1,93 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"github.com/tjfoc/gmsm/gmtls"
+	"github.com/tjfoc/gmsm/sm2"
+	"github.com/tjfoc/gmsm/x509"
+	"strings"
+)
+
+// CertificateInfo 证书的主要信息，时间为毫秒
+type CertificateInfo struct {
+	Subject            string   `json:"subject"`
+	Issuer             string   `json:"issuer"`
+	CommonName         string   `json:"commonName"`
+	DNSNames           []string `json:"dnsNames,omitempty"`
+	IPAddresses        []string `json:"ipAddresses,omitempty"`
+	SerialNumber       string   `json:"serialNumber"`
+	NotBefore          int64    `json:"notBefore"`
+	NotAfter           int64    `json:"notAfter"`
+	SignatureAlgorithm string   `json:"signatureAlgorithm"`
+	PublicKeyAlgorithm string   `json:"publicKeyAlgorithm"`
+	IsCA               bool     `json:"isCA"`
+	Fingerprint        string   `json:"fingerprint"` // DER的SHA-256摘要
+}
+
+// ParseCertificatePem 解析PEM中的全部证书(证书链)，支持国密SM2证书，忽略其他类型的PEM块
+func ParseCertificatePem(pemData []byte) (infos []*CertificateInfo, err error) {
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, e := x509.ParseCertificate(block.Bytes)
+		if e != nil {
+			return nil, e
+		}
+		infos = append(infos, newCertificateInfo(cert))
+	}
+	if len(infos) == 0 {
+		return nil, errors.New("未找到PEM格式的证书")
+	}
+	return
+}
+
+func newCertificateInfo(cert *x509.Certificate) *CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := &CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		CommonName:         cert.Subject.CommonName,
+		DNSNames:           cert.DNSNames,
+		SerialNumber:       strings.ToUpper(cert.SerialNumber.Text(16)),
+		NotBefore:          cert.NotBefore.UnixMilli(),
+		NotAfter:           cert.NotAfter.UnixMilli(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		Fingerprint:        hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.PublicKeyAlgorithm = "RSA"
+	case *sm2.PublicKey:
+		info.PublicKeyAlgorithm = "SM2"
+	case *ecdsa.PublicKey:
+		// gmsm解析的SM2公钥为SM2曲线上的ecdsa公钥
+		info.PublicKeyAlgorithm = "ECDSA"
+		if key.Curve == sm2.P256Sm2() {
+			info.PublicKeyAlgorithm = "SM2"
+		}
+	default:
+		info.PublicKeyAlgorithm = "Unknown"
+	}
+	return info
+}
+
+// CheckKeyPair 检查证书与私钥是否匹配
+func CheckKeyPair(certPem, keyPem []byte) error {
+	_, err := gmtls.X509KeyPair(certPem, keyPem)
+	return err
+}
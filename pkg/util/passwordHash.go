@@ -0,0 +1,99 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/tjfoc/gmsm/sm3"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	PasswordHashSm3    = "sm3"
+	PasswordHashBcrypt = "bcrypt"
+)
+
+// sm3Iterations PBKDF2-HMAC-SM3的迭代次数
+const sm3Iterations = 10000
+
+// HashPassword 计算密码摘要，algorithm为sm3(PBKDF2-HMAC-SM3，加盐)或bcrypt，
+// sm3格式为 sm3$迭代次数$盐$摘要，bcrypt为其标准格式
+func HashPassword(password, algorithm string) (string, error) {
+	switch algorithm {
+	case PasswordHashBcrypt:
+		b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		return string(b), err
+	case PasswordHashSm3, "":
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := pbkdf2.Key([]byte(password), salt, sm3Iterations, 32, sm3.New)
+		return fmt.Sprintf("%s$%d$%s$%s", PasswordHashSm3, sm3Iterations,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+	return "", fmt.Errorf("不支持的密码摘要算法: %s", algorithm)
+}
+
+// VerifyPassword 校验密码，根据摘要格式判断算法
+func VerifyPassword(hashed, password string) bool {
+	if strings.HasPrefix(hashed, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+	}
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 4 || parts[0] != PasswordHashSm3 {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	key := pbkdf2.Key([]byte(password), salt, iterations, len(expected), sm3.New)
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// RandomPassword 生成包含数字、大小写字母及特殊字符的随机密码，length不小于4
+func RandomPassword(length int) (string, error) {
+	if length < 4 {
+		return "", errors.New("密码长度不能小于4")
+	}
+	charsets := []string{"0123456789", "abcdefghijkmnopqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "~!@#$%^&*?_-"}
+	all := strings.Join(charsets, "")
+	b := make([]byte, length)
+	for i := range b {
+		charset := all
+		// 前4位分别取自每类字符，保证满足密码强度
+		if i < len(charsets) {
+			charset = charsets[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = charset[n.Int64()]
+	}
+	// 打乱顺序
+	for i := len(b) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	for _, algorithm := range []string{PasswordHashSm3, PasswordHashBcrypt} {
+		hashed, err := HashPassword("Passw0rd!", algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !VerifyPassword(hashed, "Passw0rd!") {
+			t.Errorf("%s: 密码校验失败", algorithm)
+		}
+		if VerifyPassword(hashed, "passw0rd!") {
+			t.Errorf("%s: 错误的密码应校验失败", algorithm)
+		}
+	}
+	if a, _ := HashPassword("Passw0rd!", PasswordHashSm3); !strings.HasPrefix(a, "sm3$") {
+		t.Errorf("sm3摘要格式错误: %s", a)
+	}
+	if VerifyPassword("", "") || VerifyPassword("sm3$x$y$z", "") {
+		t.Errorf("无效的摘要应校验失败")
+	}
+}
+
+func TestRandomPassword(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		password, err := RandomPassword(16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(password) != 16 || !PasswordStrengthCheck(16, 16, 4, password) {
+			t.Errorf("随机密码强度不足: %s", password)
+		}
+	}
+}
@@ -2,7 +2,11 @@ package util
 
 import snowflake "github.com/yockii/snowflake_ext"
 
+// MaxWorkerId 工作机器ID上限(不含)，集群节点数量不能超过该值
+const MaxWorkerId = 31
+
 var snowflakeWorker *snowflake.Worker
+var nodeId uint64
 
 func InitNode(workerId uint64) (err error) {
 	w, err := snowflake.NewSnowflake(workerId)
@@ -10,9 +14,15 @@ func InitNode(workerId uint64) (err error) {
 		return err
 	}
 	snowflakeWorker = w
+	nodeId = workerId
 	return nil
 }
 
+// NodeId 本节点的工作机器ID
+func NodeId() uint64 {
+	return nodeId
+}
+
 func SnowflakeId() uint64 {
 	return snowflakeWorker.NextId()
 }